@@ -2,18 +2,32 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	_ "time/tzdata" // schedules must not depend on the container having zoneinfo
 
 	"github.com/joho/godotenv"
 
 	"github.com/emanuelefumagalli/test-agent/internal/agent"
+	"github.com/emanuelefumagalli/test-agent/internal/config"
+	"github.com/emanuelefumagalli/test-agent/internal/metrics"
+	"github.com/emanuelefumagalli/test-agent/internal/notify"
 	"github.com/emanuelefumagalli/test-agent/internal/ollama"
 	"github.com/emanuelefumagalli/test-agent/internal/weather"
 )
 
 const (
 	// London Heathrow (wind check)
+	heathrowName      = "London Heathrow"
 	heathrowLatitude  = 51.47
 	heathrowLongitude = -0.4543
 
@@ -22,45 +36,617 @@ const (
 	twickenhamLongitude = -0.337
 )
 
+// Set at build time, e.g.
+// go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
 func main() {
 	_ = godotenv.Load()
-	ctx := context.Background()
-
-	ag := agent.New(agent.Config{
-		// Wind check at 10am UTC
-		WindLocation: "London Heathrow",
-		WindDays:     15,
-		WindHour:     10,
-		WindWeather: &weather.OpenMeteoClient{
-			Latitude:  heathrowLatitude,
-			Longitude: heathrowLongitude,
-		},
-
-		// Rain check at 7:30am London time
-		RainLocation: "Twickenham",
-		RainDays:     7,
-		RainHour:     7,
-		RainWeather: &weather.OpenMeteoClient{
-			Latitude:  twickenhamLatitude,
-			Longitude: twickenhamLongitude,
-		},
-
-		Ollama: &ollama.Client{
-			Host:  envOrDefault("OLLAMA_HOST", "http://127.0.0.1:11434"),
-			Model: envOrDefault("OLLAMA_MODEL", "llama3.1"),
-		},
-		TelegramToken:  os.Getenv("TELEGRAM_TOKEN"),
-		TelegramChatID: os.Getenv("TELEGRAM_CHAT_ID"),
+	showVersion := flag.Bool("version", false, "print version information and exit")
+	once := flag.Bool("once", envBool("RUN_ONCE"), "run each check once and exit non-zero if any failed")
+	dryRun := flag.Bool("dry-run", envBool("DRY_RUN"), "print notifications to stdout instead of sending them")
+	output := flag.String("output", envOrDefault("OUTPUT_FORMAT", "text"), "with --once, `format` of the run's report on stdout: text or json")
+	noNotify := flag.Bool("no-notify", envBool("NO_NOTIFY"), "skip sending notifications")
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "read settings from a YAML or JSON `file`; environment variables override it")
+	backfill := flag.String("backfill", "", "analyse observed winds over `START:END` (YYYY-MM-DD), print stats and exit; nothing is sent")
+	compare := flag.String("compare", "", "compare the coming week's easterly days with those at `PLACE`, print the comparison and exit; nothing is sent")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Printf("agent %s (commit %s, built %s)\n", version, commit, buildDate)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		slog.Error("invalid config", "err", err)
+		os.Exit(1)
+	}
+
+	logger := agent.NewLogger(cfg.LogFormat, cfg.LogLevel, os.Stderr)
+	slog.SetDefault(logger)
+	slog.Info("starting agent", "version", version, "commit", commit, "build_date", buildDate)
+	metrics.BuildInfo.WithLabelValues(version, commit, buildDate).Set(1)
+
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+	case "send-test":
+		if err := sendTest(ctx, logger); err != nil {
+			stop()
+			slog.Error("send-test failed", "err", err)
+			os.Exit(1)
+		}
+		return
+	case "doctor":
+		if !doctor(ctx, cfg, logger) {
+			stop()
+			os.Exit(1)
+		}
+		return
+	default:
+		slog.Error("unknown command", "command", cmd)
+		os.Exit(2)
+	}
+
+	// Shared by all checks so nearby locations don't refetch
+	cache := weather.NewCache(envDurationOrDefault("OPENMETEO_CACHE_TTL", weather.DefaultCacheTTL))
+	weatherTimeout := envDurationOrDefault("OPENMETEO_TIMEOUT", 0)
+	trace := envBool("HTTP_TRACE")
+	weatherHTTP := tracedClient(trace, metrics.UpstreamOpenMeteo, 0, logger)
+	owmHTTP := tracedClient(trace, metrics.UpstreamOpenWeatherMap, 0, logger)
+	ollamaHTTP := tracedClient(trace, metrics.UpstreamOllama, 15*time.Minute, logger) // as the ollama package's default client
+	userAgent := weather.DefaultUserAgent + "/" + version
+
+	provider := envOrDefault("WEATHER_PROVIDER", providerOpenMeteo)
+	if provider != providerOpenMeteo && provider != providerOpenWeatherMap {
+		slog.Error("invalid WEATHER_PROVIDER", "provider", provider, "want", []string{providerOpenMeteo, providerOpenWeatherMap})
+		os.Exit(1)
+	}
+
+	rainPlace, err := rainLocation(ctx, &weather.OpenMeteoClient{
+		HTTPClient:     weatherHTTP,
+		RequestTimeout: weatherTimeout,
+		UserAgent:      userAgent,
+		Logger:         logger,
+	})
+	if err != nil {
+		slog.Error("invalid LOCATION", "err", err)
+		os.Exit(1)
+	}
+
+	wind, rain := &cfg.Checks[0], &cfg.Checks[1]
+	wind.Name = heathrowName
+	wind.Weather = weatherSource(provider, &weather.OpenMeteoClient{
+		Latitude:       heathrowLatitude,
+		Longitude:      heathrowLongitude,
+		PastDays:       envIntOrDefault("PAST_DAYS", 0),
+		Models:         envList("OPENMETEO_MODELS"),
+		CellSelection:  os.Getenv("OPENMETEO_CELL_SELECTION"),
+		Timezone:       os.Getenv("OPENMETEO_TIMEZONE"),
+		DumpDir:        os.Getenv("OPENMETEO_DUMP_DIR"),
+		HTTPClient:     weatherHTTP,
+		Cache:          cache,
+		RequestTimeout: weatherTimeout,
+		UserAgent:      userAgent,
+		Logger:         logger,
+	}, owmHTTP)
+	rain.Name = rainPlace.Name
+	rain.Timezone = rainPlace.Timezone
+	rain.Weather = weatherSource(provider, &weather.OpenMeteoClient{
+		Latitude:       rainPlace.Latitude,
+		Longitude:      rainPlace.Longitude,
+		RainEnsemble:   envBool("RAIN_ENSEMBLE"),
+		Elevation:      envFloatOrDefault("RAIN_ELEVATION", 0),
+		Models:         envList("OPENMETEO_MODELS"),
+		CellSelection:  os.Getenv("OPENMETEO_CELL_SELECTION"),
+		Timezone:       os.Getenv("OPENMETEO_TIMEZONE"),
+		DumpDir:        os.Getenv("OPENMETEO_DUMP_DIR"),
+		HTTPClient:     weatherHTTP,
+		Cache:          cache,
+		RequestTimeout: weatherTimeout,
+		UserAgent:      userAgent,
+		Logger:         logger,
+	}, owmHTTP)
+
+	cfg.Ollama = ollamaFromEnv(ollamaHTTP, userAgent, logger)
+	cfg.Ollama.OnRequest = func(prompt string) {
+		logger.Debug("ollama request", "prompt", prompt)
+	}
+	cfg.Ollama.OnResponse = func(resp string, latency time.Duration) {
+		logger.Debug("ollama response", "response", resp, "latency", latency)
+	}
+
+	var notifiersByName map[string]notify.Notifier
+	cfg.Notifiers, notifiersByName, err = notifiersFromEnv(logger)
+	if err != nil {
+		slog.Error("invalid notifier config", "err", err)
+		os.Exit(1)
+	}
+	cfg.QuietNotifiers = quietNotifiersFromEnv(notifiersByName)
+	cfg.MessageTemplates = messageTemplatesFromEnv(notifiersByName)
+
+	cfg.RunOnce = *once
+	cfg.DryRun = *dryRun
+	cfg.OutputFormat = *output
+	cfg.DisableNotifications = *noNotify
+
+	ag, err := agent.New(cfg)
+	if err != nil {
+		slog.Error("invalid config", "err", err)
+		os.Exit(1)
+	}
+	// os.Exit skips deferred calls, so failures below close it themselves
+	defer closeAgent(ag)
+
+	if *backfill != "" {
+		if err := runBackfill(ctx, ag, *backfill); err != nil {
+			stop()
+			closeAgent(ag)
+			slog.Error("backfill failed", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *compare != "" {
+		geocodeClient := &weather.OpenMeteoClient{
+			HTTPClient:     weatherHTTP,
+			RequestTimeout: weatherTimeout,
+			UserAgent:      userAgent,
+			Logger:         logger,
+		}
+		source := func(p weather.Place) weather.Client {
+			return weatherSource(provider, &weather.OpenMeteoClient{
+				Latitude:       p.Latitude,
+				Longitude:      p.Longitude,
+				Models:         envList("OPENMETEO_MODELS"),
+				CellSelection:  os.Getenv("OPENMETEO_CELL_SELECTION"),
+				Timezone:       os.Getenv("OPENMETEO_TIMEZONE"),
+				DumpDir:        os.Getenv("OPENMETEO_DUMP_DIR"),
+				HTTPClient:     weatherHTTP,
+				Cache:          cache,
+				RequestTimeout: weatherTimeout,
+				UserAgent:      userAgent,
+				Logger:         logger,
+			}, owmHTTP)
+		}
+		if err := runCompare(ctx, ag, *compare, geocodeClient, source); err != nil {
+			stop()
+			closeAgent(ag)
+			slog.Error("compare failed", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	context.AfterFunc(ctx, func() {
+		slog.Info("shutting down, waiting for in-flight checks")
 	})
 
-	if err := ag.Run(ctx); err != nil {
-		log.Fatalf("agent failed: %v", err)
+	err = ag.Run(ctx)
+	if ctx.Err() != nil && (err == nil || errors.Is(err, context.Canceled)) {
+		slog.Info("shutdown complete")
+		return
+	}
+	if err != nil {
+		stop()
+		closeAgent(ag)
+		slog.Error("agent failed", "err", err)
+		os.Exit(1)
+	}
+}
+
+// closeAgent closes ag, logging a failure: the process is exiting anyway.
+func closeAgent(ag *agent.Agent) {
+	if err := ag.Close(); err != nil {
+		slog.Warn("close agent failed", "err", err)
+	}
+}
+
+// runBackfill prints the wind check's easterly and gust stats over the
+// observed winds of span, a "START:END" date range.
+func runBackfill(ctx context.Context, ag *agent.Agent, span string) error {
+	ranges, err := agent.ParseDateRanges(span)
+	if err != nil {
+		return err
+	}
+	if len(ranges) != 1 {
+		return fmt.Errorf("backfill needs a single START:END range, got %q", span)
+	}
+
+	report, err := ag.WindArchiveReport(ctx, heathrowName, ranges[0].Start, ranges[0].End)
+	if err != nil {
+		return err
+	}
+	n := len(report.Days)
+	if n == 0 {
+		return errors.New("no archive data for the range")
+	}
+
+	conditions := make(map[agent.FlyingConditions]int)
+	for _, d := range report.Days {
+		conditions[d.Conditions]++
+	}
+	pct := func(count int) float64 { return 100 * float64(count) / float64(n) }
+
+	fmt.Printf("%s, %s to %s (%d days observed)\n", report.Location,
+		report.Days[0].Date.Format("2006-01-02"), report.Days[n-1].Date.Format("2006-01-02"), n)
+	fmt.Printf("Easterly: %d (%.0f%%) | Westerly: %d (%.0f%%)\n",
+		report.EasterlyDays, pct(report.EasterlyDays), report.WesterlyDays, pct(report.WesterlyDays))
+	fmt.Printf("Gusty (> %.0f km/h): %d (%.0f%%)\n", report.GustThreshold, report.GustyDays, pct(report.GustyDays))
+	if report.WindSpeedAlert > 0 {
+		fmt.Printf("High wind (> %.0f km/h): %d (%.0f%%)\n", report.WindSpeedAlert, report.HighWindDays, pct(report.HighWindDays))
+	}
+	for _, c := range []agent.FlyingConditions{agent.OverheadSteady, agent.OverheadGusty, agent.AwaySteady, agent.AwayGusty} {
+		fmt.Printf("  %s: %d (%.0f%%)\n", c, conditions[c], pct(conditions[c]))
+	}
+	return nil
+}
+
+// runCompare prints how the wind check's coming week compares with that at
+// name, resolved by geocoding with client and fetched from source.
+func runCompare(ctx context.Context, ag *agent.Agent, name string, client *weather.OpenMeteoClient, source func(weather.Place) weather.Client) error {
+	geocoder := &weather.Geocoder{Client: client, CachePath: os.Getenv("GEOCODE_CACHE")}
+	place, err := geocoder.Resolve(ctx, name)
+	if err != nil {
+		return err
+	}
+	msg, err := ag.CompareWind(ctx, heathrowName, agent.Check{Name: place.Name, Weather: source(place)})
+	if err != nil {
+		return err
+	}
+	fmt.Println(msg.Render(notify.ASCIITable{}))
+	return nil
+}
+
+// sendTest sends a fixed message straight to the configured Telegram chat and
+// prints the Bot API's response, to check the token and chat ID.
+func sendTest(ctx context.Context, logger *slog.Logger) error {
+	token, chatIDs := os.Getenv("TELEGRAM_TOKEN"), envList("TELEGRAM_CHAT_ID")
+	if token == "" || len(chatIDs) == 0 {
+		return errors.New("TELEGRAM_TOKEN and TELEGRAM_CHAT_ID must be set")
+	}
+
+	var errs []error
+	for _, chatID := range chatIDs {
+		tg := &notify.Telegram{Token: token, ChatID: chatID, Logger: logger}
+		resp, err := tg.Test(ctx, "test message from test-agent")
+		if resp != "" {
+			fmt.Printf("chat %s: %s\n", chatID, resp)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("chat %s: %w", chatID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// doctor probes each service the agent depends on, printing a PASS, FAIL or
+// SKIP line for each, and reports whether none failed.
+func doctor(ctx context.Context, cfg agent.Config, logger *slog.Logger) bool {
+	userAgent := weather.DefaultUserAgent + "/" + version
+	openMeteo := &weather.OpenMeteoClient{
+		Latitude:  heathrowLatitude,
+		Longitude: heathrowLongitude,
+		UserAgent: userAgent,
+		Logger:    logger,
+	}
+
+	type probe struct {
+		name string
+		run  func(ctx context.Context) (detail string, err error)
+	}
+	probes := []probe{
+		{"open-meteo", func(ctx context.Context) (string, error) {
+			_, err := openMeteo.Fetch(ctx, 1)
+			return "", err
+		}},
+	}
+	if provider := envOrDefault("WEATHER_PROVIDER", providerOpenMeteo); provider != providerOpenMeteo {
+		probes = append(probes, probe{provider, func(ctx context.Context) (string, error) {
+			if provider != providerOpenWeatherMap {
+				return "", fmt.Errorf("unknown WEATHER_PROVIDER %q", provider)
+			}
+			_, err := weatherSource(provider, openMeteo, nil).Fetch(ctx, 1)
+			return "", err
+		}})
+	}
+	probes = append(probes,
+		probe{"ollama", func(ctx context.Context) (string, error) {
+			if cfg.DisableWindSummary && cfg.DisableRainSummary {
+				return "skipped, summaries disabled", errSkipped
+			}
+			c := ollamaFromEnv(nil, userAgent, logger)
+			return c.Model, c.CheckModel(ctx)
+		}},
+		probe{"telegram", func(ctx context.Context) (string, error) {
+			token := os.Getenv("TELEGRAM_TOKEN")
+			if token == "" {
+				return "skipped, TELEGRAM_TOKEN not set", errSkipped
+			}
+			tg := &notify.Telegram{Token: token, Logger: logger}
+			name, err := tg.GetMe(ctx)
+			return "@" + name, err
+		}},
+	)
+
+	ok := true
+	for _, p := range probes {
+		pctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		detail, err := p.run(pctx)
+		cancel()
+		switch {
+		case errors.Is(err, errSkipped):
+			fmt.Printf("SKIP %s: %s\n", p.name, detail)
+		case err != nil:
+			ok = false
+			fmt.Printf("FAIL %s: %v\n", p.name, err)
+		case detail != "":
+			fmt.Printf("PASS %s (%s)\n", p.name, detail)
+		default:
+			fmt.Printf("PASS %s\n", p.name)
+		}
+	}
+	return ok
+}
+
+// errSkipped marks a doctor probe that doesn't apply to the configuration.
+var errSkipped = errors.New("skipped")
+
+// ollamaFromEnv returns the Ollama client OLLAMA_HOST, OLLAMA_MODEL,
+// OLLAMA_API_KEY and OLLAMA_KEEP_ALIVE configure.
+func ollamaFromEnv(httpClient *http.Client, userAgent string, logger *slog.Logger) *ollama.Client {
+	return &ollama.Client{
+		Host:       envOrDefault("OLLAMA_HOST", "http://127.0.0.1:11434"),
+		Model:      envOrDefault("OLLAMA_MODEL", "llama3.1"),
+		APIKey:     os.Getenv("OLLAMA_API_KEY"),
+		KeepAlive:  os.Getenv("OLLAMA_KEEP_ALIVE"),
+		HTTPClient: httpClient,
+		UserAgent:  userAgent,
+		Logger:     logger,
+
+		BreakerFailures: envIntOrDefault("OLLAMA_BREAKER_FAILURES", 3),
+		BreakerCooldown: envDurationOrDefault("OLLAMA_BREAKER_COOLDOWN", ollama.DefaultBreakerCooldown),
 	}
 }
 
+// notifiersFromEnv enables each backend whose credentials are set, returning
+// them also by Name (telegram, twilio, email, webhook).
+func notifiersFromEnv(logger *slog.Logger) ([]notify.Notifier, map[string]notify.Notifier, error) {
+	var notifiers []notify.Notifier
+	byName := make(map[string]notify.Notifier)
+	add := func(n notify.Notifier) {
+		notifiers = append(notifiers, n)
+		byName[n.Name()] = n
+	}
+	if token, chatIDs := os.Getenv("TELEGRAM_TOKEN"), envList("TELEGRAM_CHAT_ID"); token != "" && len(chatIDs) > 0 {
+		add(&notify.Telegram{
+			Token:      token,
+			ChatIDs:    chatIDs,
+			ParseMode:  os.Getenv("TELEGRAM_PARSE_MODE"),
+			ChatRate:   envFloatOrDefault("TELEGRAM_CHAT_RATE", 0),
+			GlobalRate: envFloatOrDefault("TELEGRAM_GLOBAL_RATE", 0),
+			Logger:     logger,
+		})
+	}
+	if sid := os.Getenv("TWILIO_ACCOUNT_SID"); sid != "" {
+		add(&notify.Twilio{
+			AccountSID: sid,
+			AuthToken:  os.Getenv("TWILIO_AUTH_TOKEN"),
+			From:       os.Getenv("TWILIO_FROM"),
+			To:         os.Getenv("TWILIO_TO"),
+			Logger:     logger,
+		})
+	}
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		add(&notify.Email{
+			Host:     host,
+			Port:     envIntOrDefault("SMTP_PORT", 587),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+			To:       envList("SMTP_TO"),
+			Logger:   logger,
+		})
+	}
+	if url := os.Getenv("WEBHOOK_URL"); url != "" {
+		w, err := webhookFromEnv(url, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		add(w)
+	}
+	return notifiers, byName, nil
+}
+
+// webhookFromEnv builds the webhook notifier posting to url, with
+// WEBHOOK_HEADERS as comma-separated "Name: value" pairs.
+func webhookFromEnv(url string, logger *slog.Logger) (*notify.Webhook, error) {
+	w := &notify.Webhook{
+		URL:         url,
+		Method:      os.Getenv("WEBHOOK_METHOD"),
+		ContentType: os.Getenv("WEBHOOK_CONTENT_TYPE"),
+		Logger:      logger,
+	}
+	for _, h := range envList("WEBHOOK_HEADERS") {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			return nil, fmt.Errorf("WEBHOOK_HEADERS: %q is not \"Name: value\"", h)
+		}
+		if w.Headers == nil {
+			w.Headers = make(map[string]string)
+		}
+		w.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	if text := os.Getenv("WEBHOOK_BODY_TEMPLATE"); text != "" {
+		body, err := notify.ParseWebhookBody(text)
+		if err != nil {
+			return nil, fmt.Errorf("WEBHOOK_BODY_TEMPLATE: %w", err)
+		}
+		w.Body = body
+	}
+	return w, nil
+}
+
+// quietNotifiersFromEnv returns the notifiers QUIET_NOTIFIERS names, which
+// quiet hours apply to, or nil for all of them.
+func quietNotifiersFromEnv(byName map[string]notify.Notifier) []notify.Notifier {
+	var notifiers []notify.Notifier
+	for _, name := range envList("QUIET_NOTIFIERS") {
+		n, ok := byName[name]
+		if !ok {
+			// Not configured, so nothing to keep quiet
+			slog.Warn("QUIET_NOTIFIERS names a notifier that isn't enabled", "notifier", name)
+			continue
+		}
+		notifiers = append(notifiers, n)
+	}
+	if len(envList("QUIET_NOTIFIERS")) > 0 && notifiers == nil {
+		notifiers = []notify.Notifier{} // none of them enabled: applies to none
+	}
+	return notifiers
+}
+
+// messageTemplatesFromEnv returns the message templates set for enabled
+// notifiers, by <NAME>_MESSAGE_TEMPLATE, e.g. TELEGRAM_MESSAGE_TEMPLATE.
+func messageTemplatesFromEnv(byName map[string]notify.Notifier) map[notify.Notifier]string {
+	var templates map[notify.Notifier]string
+	for name, n := range byName {
+		text := os.Getenv(strings.ToUpper(name) + "_MESSAGE_TEMPLATE")
+		if text == "" {
+			continue
+		}
+		if templates == nil {
+			templates = make(map[notify.Notifier]string)
+		}
+		templates[n] = text
+	}
+	return templates
+}
+
 func envOrDefault(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
 	}
 	return fallback
 }
+
+func envDurationOrDefault(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		slog.Warn("invalid env var, using default", "key", key, "value", v, "default", fallback, "err", err)
+		return fallback
+	}
+	return d
+}
+
+func envIntOrDefault(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		slog.Warn("invalid env var, using default", "key", key, "value", v, "default", fallback, "err", err)
+		return fallback
+	}
+	return n
+}
+
+func envFloatOrDefault(key string, fallback float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		slog.Warn("invalid env var, using default", "key", key, "value", v, "default", fallback, "err", err)
+		return fallback
+	}
+	return f
+}
+
+// envList splits a comma-separated env var, dropping blank entries.
+func envList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// rainLocation returns the rain check's place: LOCATION resolved by
+// geocoding (cached in GEOCODE_CACHE across restarts), or Twickenham.
+func rainLocation(ctx context.Context, client *weather.OpenMeteoClient) (weather.Place, error) {
+	name := os.Getenv("LOCATION")
+	if name == "" {
+		return weather.Place{
+			Name:      "Twickenham",
+			Latitude:  twickenhamLatitude,
+			Longitude: twickenhamLongitude,
+			Timezone:  "Europe/London",
+		}, nil
+	}
+
+	geocoder := &weather.Geocoder{Client: client, CachePath: os.Getenv("GEOCODE_CACHE")}
+	place, err := geocoder.Resolve(ctx, name)
+	if err != nil {
+		return weather.Place{}, err
+	}
+	if place.Timezone == "" {
+		place.Timezone = "Europe/London"
+	}
+	slog.Info("resolved location", "location", name, "place", place.String(),
+		"latitude", place.Latitude, "longitude", place.Longitude, "timezone", place.Timezone)
+	return place, nil
+}
+
+// tracedClient returns an HTTP client for upstream that records connection
+// phase timings, or nil (the packages' default) when trace is off.
+func tracedClient(trace bool, upstream string, timeout time.Duration, logger *slog.Logger) *http.Client {
+	if !trace {
+		return nil
+	}
+	return &http.Client{Timeout: timeout, Transport: metrics.TraceTransport(upstream, nil, logger)}
+}
+
+// Values of WEATHER_PROVIDER.
+const (
+	providerOpenMeteo      = "open-meteo"
+	providerOpenWeatherMap = "openweathermap"
+)
+
+// weatherSource returns om, or with the openweathermap provider an
+// OpenWeatherMapClient for the same coordinates with om's other settings.
+func weatherSource(provider string, om *weather.OpenMeteoClient, owmHTTP *http.Client) weather.Client {
+	if provider != providerOpenWeatherMap {
+		return om
+	}
+	return &weather.OpenWeatherMapClient{
+		Latitude:       om.Latitude,
+		Longitude:      om.Longitude,
+		APIKey:         os.Getenv("OPENWEATHERMAP_API_KEY"),
+		HTTPClient:     owmHTTP,
+		Cache:          om.Cache,
+		RequestTimeout: om.RequestTimeout,
+		UserAgent:      om.UserAgent,
+		Logger:         om.Logger,
+	}
+}
+
+func envBool(key string) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	return err == nil && v
+}
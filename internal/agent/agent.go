@@ -1,79 +1,669 @@
 package agent
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
+	"unicode/utf8"
 
+	"github.com/emanuelefumagalli/test-agent/internal/metrics"
+	"github.com/emanuelefumagalli/test-agent/internal/notify"
 	"github.com/emanuelefumagalli/test-agent/internal/ollama"
 	"github.com/emanuelefumagalli/test-agent/internal/weather"
 )
 
-// Config wires together the dependencies and runtime options for the agent.
-type Config struct {
-	// Wind check (Heathrow)
-	WindLocation string
-	WindDays     int
-	WindWeather  *weather.OpenMeteoClient
-	WindHour     int // UTC
+// CheckType selects which forecast a check fetches and how it is analysed.
+type CheckType string
+
+const (
+	CheckWind CheckType = "wind"
+	CheckRain CheckType = "rain"
+)
 
-	// Rain check (Twickenham)
-	RainLocation string
-	RainDays     int
-	RainWeather  *weather.OpenMeteoClient
-	RainHour     int // London time
-	RainMinute   int
+// Check is a single scheduled forecast check for one location.
+type Check struct {
+	Name    string // location label, e.g. "London Heathrow"
+	Type    CheckType
+	Weather weather.Client // carries the coordinates
+	Days    int            // defaults to 15 for wind, 7 for rain, within the client's horizon
+
+	// Daily run time, in Timezone (an IANA name, default UTC)
+	Hour     int
+	Minute   int
+	Timezone string
+	// Cron, when set, replaces Hour and Minute with a five-field cron
+	// expression evaluated in Timezone, e.g. "0 0,12 * * *" or "30 7 * * 1-5".
+	Cron string
+
+	// RunOnStart also runs the check as soon as the agent starts
+	RunOnStart bool
+}
 
-	Ollama         *ollama.Client
-	TelegramToken  string
-	TelegramChatID string
+func (c Check) icon() string {
+	if c.Type == CheckRain {
+		return "🌧️"
+	}
+	return "🛫"
+}
+
+// Config wires together the dependencies and runtime options for the agent.
+type Config struct {
+	Checks []Check
+
+	// SchoolSchedule sets the rain check's school-run windows per weekday
+	// (default DefaultSchoolSchedule)
+	SchoolSchedule SchoolSchedule
+	// SchoolHolidays are date ranges with no school run. SchoolHolidayCalendarURL
+	// optionally adds the VEVENT ranges of an iCal feed, refetched daily.
+	SchoolHolidays           []DateRange
+	SchoolHolidayCalendarURL string
+
+	// Rain probability (%) at which a school-run window gets "maybe umbrella"
+	// (default 30) and "umbrella!" (default 70). Maybe must be below definite.
+	RainMaybeThreshold    int
+	RainDefiniteThreshold int
+	// Hourly amounts (mm) below which rain reads as "light drizzle" (default
+	// 0.5) and from which it is a "soaking downpour" (default 4)
+	RainLightMM float64
+	RainHeavyMM float64
+	// RainPrimaryMetric is what the rain table's school-run columns show:
+	// RainMetricProbability (default, "63%") or RainMetricMM, the heaviest
+	// hourly amount in the window ("3.0"). The ☔ marker still follows
+	// the probability thresholds either way.
+	RainPrimaryMetric string
+	// RainAggregation is how a school-run window's hourly probabilities become
+	// one: RainAggregateMax (default, the wettest hour), RainAggregateMean, or
+	// RainAggregateSustained, the highest probability held for
+	// RainSustainedHours consecutive hours (default 2), so a one-hour blip no
+	// longer escalates the whole window.
+	RainAggregation    string
+	RainSustainedHours int
+	// SnowThresholdCM is the hourly snowfall (cm) from which a school-run
+	// window reads as snow (❄️) rather than rain, provided snow is most of the
+	// precipitation (default 0.2). Without snowfall data windows count as rain.
+	SnowThresholdCM float64
+	// RainTodayHourly makes rain checks also fetch today's full hourly
+	// forecast where the source has one (Open-Meteo), so today's school-run
+	// verdicts see every hour of their windows, including any outside the
+	// 6-10am and 3-6pm detail of the daily fetch. If it fails, that detail
+	// is used.
+	RainTodayHourly bool
+	// RainyDayThreshold is what counts as a rainy day in the weekly summary
+	// (default 40% daily probability), independent of the school-run
+	// thresholds above.
+	RainyDayThreshold RainyDayThreshold
+	// ShowSparkline puts a one-line chart of each day's rain probability
+	// above the rain table, e.g. "Rain ▁▃█▅▂▁▁ Mon–Sun". SparklineRamp is its
+	// characters from 0% to 100%, at least two (default
+	// DefaultSparklineRamp).
+	ShowSparkline bool
+	SparklineRamp string
+	// ShowDryMorning adds a line naming the first school morning this week
+	// whose drop-off stays below RainMaybeThreshold, for cycling to school:
+	// "🚲 Driest school morning: Thursday, 15%".
+	ShowDryMorning bool
+
+	// GustThreshold marks wind-check days whose max gust exceeds it (km/h, default 40)
+	GustThreshold float64
+	// WindSpeedAlert, when > 0, marks wind-check days whose max wind speed
+	// exceeds it (km/h) with 💨 and counts them, regardless of direction.
+	WindSpeedAlert float64
+	// DirectionHysteresis, when > 0, smooths the easterly/westerly call: a
+	// day keeps the previous day's direction unless its wind is at least this
+	// many degrees inside the other half of the compass. Directions hovering
+	// around north or south then stop flip-flopping between E and W, at the
+	// cost of reporting a real change a day late when it starts near the
+	// boundary. Must be below 90.
+	DirectionHysteresis float64
+	// ShowOverheadScore adds an Ovh column to the wind table with each day's
+	// 0-100 score of how likely planes are overhead, from how squarely
+	// easterly, strong and steady the wind is (see overheadScore).
+	ShowOverheadScore bool
+	// OverheadAlert, when > 0, counts the days scoring at least this in the
+	// analysis ("Planes overhead likely: 3 days").
+	OverheadAlert int
+	// DominantMargin is how many days apart the easterly and westerly counts
+	// may be and still read as "Mixed / variable" rather than a dominant
+	// direction. The default 0 calls only an exact tie, as "Mixed".
+	DominantMargin int
+	// CompassResolution is how finely the wind table's Dir column names the
+	// direction: 2 (the default, E or W as used for flight paths), 8 (N, NE,
+	// ...) or 16 (N, NNE, ...). It is display only; what counts as easterly
+	// is unchanged.
+	CompassResolution int
+	// DirectionStyle is how the Dir column shows the direction: "letters"
+	// (the default, see CompassResolution), or one of eight arrows, pointing
+	// where the wind comes from ("arrows-from": a northerly is ↑) or where it
+	// blows to ("arrows-to", as on weather maps: a northerly is ↓). Arrows
+	// ignore CompassResolution.
+	DirectionStyle string
+
+	// Ollama writes the summary appended to each message; nil sends the
+	// analysis and table only. DisableWindSummary and DisableRainSummary skip
+	// the call for one check type.
+	Ollama             *ollama.Client
+	DisableWindSummary bool
+	DisableRainSummary bool
+	// OllamaTimeout bounds each summary call (default 5m). A slow model then
+	// costs only the summary: the message is sent without it.
+	OllamaTimeout time.Duration
+	// SummaryLanguage is the language the Ollama summary is written in
+	// (default "English"). Any other language adds a "Respond in ..."
+	// instruction to the end of the prompt, unless its template places
+	// {{.Language}} itself.
+	SummaryLanguage string
+	// SanitizeSummary strips the Ollama summary of Markdown that can break
+	// the message around it: code-fence lines (``` or ~~~) are dropped and
+	// other backticks become apostrophes, so a stray fence can't swallow or
+	// unbalance the table's code block. The model's text is free-form, so
+	// this is separate from any parse-mode escaping by a notifier.
+	SanitizeSummary bool
+
+	Notifiers []notify.Notifier
+	// FlushTimeout bounds the last-chance send of a message whose check was
+	// cancelled (e.g. by shutdown) after it was built (default 10s). The send
+	// runs on a context detached from the cancelled one.
+	FlushTimeout time.Duration
+	// QuietStart and QuietEnd, when they differ, set a daily window in
+	// QuietTimezone (default UTC) during which notifications are held, then
+	// sent when it ends. A window may cross midnight (22:00 to 07:00). Checks
+	// still run on schedule; only delivery waits. QuietNotifiers limits the
+	// window to those notifiers, compared by identity with Notifiers (default
+	// all). Ignored with RunOnce, which can't wait; held messages are lost if
+	// the agent stops first.
+	QuietStart     TimeOfDay
+	QuietEnd       TimeOfDay
+	QuietTimezone  string
+	QuietNotifiers []notify.Notifier
+	// MessageTemplates optionally renders each check's message for a
+	// notifier, keyed by identity with Notifiers, with a text/template over
+	// MessageData; in digest mode it renders the check's section. Notifiers
+	// without one get the built-in message. Weekly summaries are always sent
+	// as built.
+	MessageTemplates map[notify.Notifier]string
+
+	// Optional text/template overrides for the Ollama prompts; see PromptData
+	// for the available fields. Empty uses the built-in prompt.
+	WindPromptTemplate string
+	RainPromptTemplate string
+	// Optional text/template overrides for the line heading the wind analysis
+	// when no forecast day is easterly, and when at least three are; see
+	// OutlookData for the available fields. Empty uses the built-in wording.
+	NoEasterlyTemplate   string
+	ManyEasterlyTemplate string
+
+	// Markers flag days and windows in the tables and school-run lines; empty
+	// fields keep the DefaultMarkers emoji.
+	Markers Markers
+	// CompactTable narrows the wind and rain tables for phone screens: dates
+	// drop the weekday ("02 Jan"), or are the weekday alone ("Mon") when the
+	// table spans a week or less. TableWidth, when > 0, then drops the
+	// tables' last columns until their lines fit in that many characters.
+	CompactTable bool
+	TableWidth   int
+
+	// MetricsAddr, when set, serves Prometheus metrics on /metrics (e.g. ":9090").
+	MetricsAddr string
+	// HealthAddr, when set, serves /healthz and /readyz probes. May equal MetricsAddr.
+	HealthAddr string
+
+	// LogFormat selects "text" (default, human-friendly) or "json" logs, and
+	// LogLevel the minimum level (debug, info, warn, error; default info).
+	LogFormat string
+	LogLevel  string
+
+	// DryRun prints every notification to stdout instead of sending it; the
+	// rest of the pipeline runs normally.
+	DryRun bool
+
+	// RunOnce runs every check a single time, then Run returns (for cron).
+	RunOnce bool
+
+	// OutputFormat is "text" (default) or "json". With "json", which needs
+	// RunOnce, Run also writes every check's report, analysis and summary to
+	// Output (default os.Stdout) as a single RunOutput document.
+	// DisableNotifications skips sending, e.g. to use the agent purely as a
+	// data source.
+	OutputFormat         string
+	Output               io.Writer
+	DisableNotifications bool
+
+	// DigestMode holds per-check messages and sends one combined message per
+	// day, with their tables merged, once no check is running or queued to
+	// run later that day.
+	DigestMode bool
+
+	// WeeklySummaryCron, when set, also sends a look-ahead summary of the next
+	// seven days across all checks on this cron schedule (e.g. "0 18 * * 0"
+	// for Sunday 6pm), evaluated in WeeklySummaryTimezone (default UTC). It is
+	// always a separate message, even in DigestMode.
+	WeeklySummaryCron     string
+	WeeklySummaryTimezone string
+
+	// SkipImmediateRun disables every check's RunOnStart.
+	SkipImmediateRun bool
+	// StartupJitter, when > 0, delays each check's startup (or RunOnce) run
+	// by a random interval in [0, StartupJitter), so instances restarted
+	// together don't hit Open-Meteo in the same second.
+	StartupJitter time.Duration
+	// MaxScheduleSkew, when > 0, skips a scheduled run of a check or the
+	// weekly summary that fires more than this long after its slot, as when
+	// the host wakes from sleep, rather than sending a message as if on time.
+	// The check's next run is scheduled from when it fired.
+	MaxScheduleSkew time.Duration
+	// StateFile, when set, persists each check's last successful run. With
+	// MinRunInterval > 0, a startup run is skipped if the check succeeded
+	// less than MinRunInterval ago, so restarts don't resend messages.
+	StateFile      string
+	MinRunInterval time.Duration
+	// ShowForecastChanges heads each check's message with what materially
+	// changed since its previous run, e.g. "⚠️ Thu 22 Oct flipped to easterly
+	// since yesterday.": an upcoming day turning easterly, westerly or gusty,
+	// a school-run umbrella verdict, or a day's rain chance moving 30 points
+	// or more. The previous report is kept in StateFile, which it needs; the
+	// first run has nothing to compare and shows none.
+	ShowForecastChanges bool
+
+	// HistoryDB, when set, is the path of a SQLite database in which every
+	// fetched wind and rain forecast day is recorded with its fetch time, to
+	// chart how the forecast for a day drifts as it approaches. Close
+	// closes it.
+	HistoryDB string
+
+	// MaxStaleAge, when > 0, lets a check whose fetch fails fall back to its
+	// last successfully fetched forecast if that is at most this old. The
+	// message is sent marked stale, with the time it was fetched, and the
+	// check still counts as failed.
+	MaxStaleAge time.Duration
+
+	// FetchConcurrency bounds how many weather fetches run at once across
+	// all checks, the weekly summary and the /forecast endpoint (default 2),
+	// so many locations don't hit Open-Meteo's rate limits together. Others
+	// wait their turn.
+	FetchConcurrency int
+
+	// Clock drives scheduling (default the wall clock).
+	Clock Clock
 }
 
 // Agent coordinates weather checks.
 type Agent struct {
 	cfg Config
+	log *slog.Logger
+
+	// ready[i] is set once cfg.Checks[i] has completed a successful cycle (see /readyz)
+	ready []atomic.Bool
+	// status holds each check's last and next run (see Status)
+	status *statusBoard
+	// schedules[i] is cfg.Checks[i]'s parsed run schedule
+	schedules []*cronSpec
+	weekly    *cronSpec // nil when the weekly summary is off
+	weeklyLoc *time.Location
+
+	windPrompt *template.Template
+	rainPrompt *template.Template
+
+	noEasterly   *template.Template
+	manyEasterly *template.Template
+
+	holidayCal *holidayCalendar
+	state      *runState
+	history    *forecastHistory
+	lastGood   lastGood
+	quiet      *quietHours // nil without quiet hours
+
+	// msgTemplates[i] is cfg.Notifiers[i]'s message template; nil without one
+	msgTemplates []*template.Template
+
+	digest digest
+
+	// fetchSlots holds a token per weather fetch in flight (see acquireFetch)
+	fetchSlots chan struct{}
+
+	// outputs[i] collects cfg.Checks[i]'s result for the JSON output; nil
+	// with text output
+	outputs []CheckOutput
 }
 
-// New returns a fully constructed Agent.
-func New(cfg Config) *Agent {
-	if cfg.WindDays <= 0 {
-		cfg.WindDays = 15
+// New returns a fully constructed Agent, or an error if the config is invalid.
+func New(cfg Config) (*Agent, error) {
+	cfg.Checks = append([]Check(nil), cfg.Checks...)
+	schedules := make([]*cronSpec, len(cfg.Checks))
+	for i := range cfg.Checks {
+		chk := &cfg.Checks[i]
+		if chk.Weather == nil {
+			return nil, fmt.Errorf("check %q: no weather client", chk.Name)
+		}
+		if err := chk.Weather.Validate(); err != nil {
+			return nil, fmt.Errorf("check %q: %w", chk.Name, err)
+		}
+		maxDays, longRange := chk.Weather.Horizon()
+		if chk.Type == CheckWind {
+			maxDays = longRange
+		}
+		if chk.Days <= 0 {
+			chk.Days = 15
+			if chk.Type == CheckRain {
+				chk.Days = 7
+			}
+			chk.Days = min(chk.Days, maxDays)
+		}
+		if chk.Timezone == "" {
+			chk.Timezone = "UTC"
+		}
+
+		if chk.Days > maxDays {
+			return nil, fmt.Errorf("check %q: %d days exceeds the %d-day limit for %s checks", chk.Name, chk.Days, maxDays, chk.Type)
+		}
+
+		if chk.Cron == "" {
+			chk.Cron = dailyCron(chk.Hour, chk.Minute)
+		}
+		spec, err := parseCron(chk.Cron)
+		if err == nil {
+			_, err = spec.next(time.Now(), time.UTC)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("check %q: %w", chk.Name, err)
+		}
+		schedules[i] = spec
+	}
+	if cfg.SchoolSchedule == nil {
+		cfg.SchoolSchedule = DefaultSchoolSchedule()
+	}
+	if cfg.RainMaybeThreshold <= 0 {
+		cfg.RainMaybeThreshold = 30
+	}
+	if cfg.RainDefiniteThreshold <= 0 {
+		cfg.RainDefiniteThreshold = 70
+	}
+	if cfg.RainMaybeThreshold >= cfg.RainDefiniteThreshold {
+		return nil, fmt.Errorf("rain maybe threshold (%d%%) must be below definite threshold (%d%%)", cfg.RainMaybeThreshold, cfg.RainDefiniteThreshold)
+	}
+	if cfg.RainLightMM <= 0 {
+		cfg.RainLightMM = 0.5
+	}
+	if cfg.RainHeavyMM <= 0 {
+		cfg.RainHeavyMM = 4
+	}
+	switch cfg.RainPrimaryMetric {
+	case "":
+		cfg.RainPrimaryMetric = RainMetricProbability
+	case RainMetricProbability, RainMetricMM:
+	default:
+		return nil, fmt.Errorf("unknown rain primary metric %q (want %q or %q)", cfg.RainPrimaryMetric, RainMetricProbability, RainMetricMM)
+	}
+	switch cfg.RainAggregation {
+	case "":
+		cfg.RainAggregation = RainAggregateMax
+	case RainAggregateMax, RainAggregateMean, RainAggregateSustained:
+	default:
+		return nil, fmt.Errorf("unknown rain aggregation %q (want %q, %q or %q)", cfg.RainAggregation, RainAggregateMax, RainAggregateMean, RainAggregateSustained)
+	}
+	if cfg.RainSustainedHours <= 0 {
+		cfg.RainSustainedHours = 2
+	}
+	if cfg.RainLightMM >= cfg.RainHeavyMM {
+		return nil, fmt.Errorf("rain light amount (%gmm) must be below heavy amount (%gmm)", cfg.RainLightMM, cfg.RainHeavyMM)
+	}
+	if cfg.SnowThresholdCM <= 0 {
+		cfg.SnowThresholdCM = 0.2
 	}
-	if cfg.RainDays <= 0 {
-		cfg.RainDays = 7
+	if cfg.RainyDayThreshold.Prob < 0 || cfg.RainyDayThreshold.Prob > 100 || cfg.RainyDayThreshold.MM < 0 {
+		return nil, fmt.Errorf("rainy day threshold %d%%, %gmm out of range", cfg.RainyDayThreshold.Prob, cfg.RainyDayThreshold.MM)
 	}
-	if cfg.WindHour == 0 {
-		cfg.WindHour = 10
+	if cfg.RainyDayThreshold == (RainyDayThreshold{}) {
+		cfg.RainyDayThreshold.Prob = 40
 	}
-	if cfg.RainHour == 0 {
-		cfg.RainHour = 7
+	if cfg.GustThreshold <= 0 {
+		cfg.GustThreshold = 40
 	}
-	if cfg.RainMinute == 0 {
-		cfg.RainMinute = 30
+	if cfg.DirectionHysteresis < 0 || cfg.DirectionHysteresis >= 90 {
+		return nil, fmt.Errorf("direction hysteresis %g° out of range 0..90", cfg.DirectionHysteresis)
 	}
-	return &Agent{cfg: cfg}
+	if cfg.OverheadAlert < 0 || cfg.OverheadAlert > 100 {
+		return nil, fmt.Errorf("overhead alert %d out of range 0..100", cfg.OverheadAlert)
+	}
+	if cfg.DominantMargin < 0 {
+		return nil, fmt.Errorf("dominant margin %d days is negative", cfg.DominantMargin)
+	}
+	if cfg.ShowForecastChanges && cfg.StateFile == "" {
+		return nil, errors.New("forecast changes need a state file")
+	}
+	if utf8.RuneCountInString(cfg.SparklineRamp) == 1 {
+		return nil, fmt.Errorf("sparkline ramp %q: want at least two characters", cfg.SparklineRamp)
+	}
+	switch cfg.CompassResolution {
+	case 0:
+		cfg.CompassResolution = 2
+	case 2, 8, 16:
+	default:
+		return nil, fmt.Errorf("compass resolution %d: want 2, 8 or 16", cfg.CompassResolution)
+	}
+	switch cfg.DirectionStyle {
+	case "":
+		cfg.DirectionStyle = DirectionLetters
+	case DirectionLetters, DirectionArrowsFrom, DirectionArrowsTo:
+	default:
+		return nil, fmt.Errorf("direction style %q: want %q, %q or %q", cfg.DirectionStyle, DirectionLetters, DirectionArrowsFrom, DirectionArrowsTo)
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = realClock{}
+	}
+	cfg.Markers = cfg.Markers.withDefaults()
+	if cfg.TableWidth < 0 {
+		return nil, fmt.Errorf("table width %d must not be negative", cfg.TableWidth)
+	}
+	switch {
+	case cfg.FetchConcurrency == 0:
+		cfg.FetchConcurrency = defaultFetchConcurrency
+	case cfg.FetchConcurrency < 0:
+		return nil, fmt.Errorf("fetch concurrency %d is negative", cfg.FetchConcurrency)
+	}
+	if cfg.OllamaTimeout <= 0 {
+		cfg.OllamaTimeout = 5 * time.Minute
+	}
+	if cfg.FlushTimeout <= 0 {
+		cfg.FlushTimeout = 10 * time.Second
+	}
+	cfg.SummaryLanguage = strings.TrimSpace(cfg.SummaryLanguage)
+	if cfg.SummaryLanguage == "" {
+		cfg.SummaryLanguage = defaultSummaryLanguage
+	}
+
+	windPrompt, err := parsePrompt("wind", cfg.WindPromptTemplate, defaultWindPrompt)
+	if err != nil {
+		return nil, err
+	}
+	rainPrompt, err := parsePrompt("rain", cfg.RainPromptTemplate, defaultRainPrompt)
+	if err != nil {
+		return nil, err
+	}
+	noEasterly, err := parseOutlook("no easterly", cfg.NoEasterlyTemplate, defaultNoEasterly)
+	if err != nil {
+		return nil, err
+	}
+	manyEasterly, err := parseOutlook("many easterly", cfg.ManyEasterlyTemplate, defaultManyEasterly)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.OutputFormat {
+	case "":
+		cfg.OutputFormat = OutputText
+	case OutputText:
+	case OutputJSON:
+		if !cfg.RunOnce {
+			return nil, errors.New("json output needs RunOnce")
+		}
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want %q or %q)", cfg.OutputFormat, OutputText, OutputJSON)
+	}
+	if cfg.Output == nil {
+		cfg.Output = os.Stdout
+	}
+	var outputs []CheckOutput
+	if cfg.OutputFormat == OutputJSON {
+		outputs = make([]CheckOutput, len(cfg.Checks))
+	}
+
+	if cfg.QuietTimezone == "" {
+		cfg.QuietTimezone = "UTC"
+	}
+	var quiet *quietHours
+	if !cfg.RunOnce && !cfg.DisableNotifications {
+		// Before the dry-run wrapping, which QuietNotifiers can't match
+		if quiet, err = newQuietHours(cfg.QuietStart, cfg.QuietEnd, cfg.QuietTimezone, cfg.Notifiers, cfg.QuietNotifiers); err != nil {
+			return nil, err
+		}
+	}
+
+	// Also before the dry-run wrapping, for the same reason
+	msgTemplates, err := parseMessageTemplates(cfg.MessageTemplates, cfg.Notifiers)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DisableNotifications {
+		cfg.Notifiers = nil
+	} else if cfg.DryRun {
+		cfg.Notifiers = dryRunNotifiers(cfg.Notifiers)
+	}
+
+	var holidayCal *holidayCalendar
+	if cfg.SchoolHolidayCalendarURL != "" {
+		holidayCal = &holidayCalendar{url: cfg.SchoolHolidayCalendarURL, clock: cfg.Clock}
+	}
+
+	var (
+		weekly    *cronSpec
+		weeklyLoc *time.Location
+	)
+	if cfg.WeeklySummaryCron != "" {
+		if cfg.WeeklySummaryTimezone == "" {
+			cfg.WeeklySummaryTimezone = "UTC"
+		}
+		if weeklyLoc, err = time.LoadLocation(cfg.WeeklySummaryTimezone); err != nil {
+			return nil, fmt.Errorf("weekly summary: load timezone %q: %w", cfg.WeeklySummaryTimezone, err)
+		}
+		// As for the checks, a spec that never matches would silently never send
+		weekly, err = parseCron(cfg.WeeklySummaryCron)
+		if err == nil {
+			_, err = weekly.next(time.Now(), weeklyLoc)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("weekly summary: %w", err)
+		}
+	}
+
+	log := NewLogger(cfg.LogFormat, cfg.LogLevel, os.Stderr)
+
+	var state *runState
+	if cfg.StateFile != "" {
+		state, err = loadRunState(cfg.StateFile)
+		if err != nil {
+			// Losing the history only costs a possible duplicate message
+			log.Warn("ignoring unreadable state file", "path", cfg.StateFile, "err", err)
+		}
+	}
+
+	var history *forecastHistory
+	if cfg.HistoryDB != "" {
+		if history, err = openHistory(cfg.HistoryDB); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Agent{
+		cfg:        cfg,
+		holidayCal: holidayCal,
+		state:      state,
+		history:    history,
+		quiet:      quiet,
+		log:        log,
+		ready:      make([]atomic.Bool, len(cfg.Checks)),
+		status:     newStatusBoard(cfg.Checks),
+		schedules:  schedules,
+		weekly:     weekly,
+		weeklyLoc:  weeklyLoc,
+		windPrompt: windPrompt,
+		rainPrompt: rainPrompt,
+		outputs:    outputs,
+
+		msgTemplates: msgTemplates,
+		fetchSlots:   make(chan struct{}, cfg.FetchConcurrency),
+		noEasterly:   noEasterly,
+		manyEasterly: manyEasterly,
+	}, nil
+}
+
+// Close releases what New opened, the HistoryDB database. The agent must not
+// be used afterwards.
+func (a *Agent) Close() error {
+	if err := a.history.close(); err != nil {
+		return fmt.Errorf("close history database: %w", err)
+	}
+	return nil
 }
 
-// Run starts both wind and rain checks concurrently.
+// Run runs every configured check on its schedule until ctx is done. In
+// RunOnce mode it runs each check a single time and returns the joined errors
+// of any that failed.
 func (a *Agent) Run(ctx context.Context) error {
-	errCh := make(chan error, 2)
+	if a.cfg.RunOnce {
+		return a.runOnce(ctx)
+	}
 
-	// Wind check goroutine (10am UTC)
-	go func() {
-		errCh <- a.runWindCheck(ctx)
-	}()
+	sched, err := a.newScheduler()
+	if err != nil {
+		return err
+	}
 
-	// Rain check goroutine (7:30am London)
-	go func() {
-		errCh <- a.runRainCheck(ctx)
+	// The scheduler running every check, the optional weekly summary and
+	// quiet hours, and optional HTTP servers. On return, everything is
+	// cancelled and in-flight checks are allowed to finish.
+	loops := []func(context.Context) error{sched.run}
+	if a.weekly != nil {
+		loops = append(loops, a.runWeekly)
+	}
+	if a.quiet != nil {
+		loops = append(loops, a.runQuietHours)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	muxes := a.httpHandlers()
+	// Room for every goroutine's error, so none blocks once Run has returned
+	errCh := make(chan error, len(muxes)+len(loops))
+
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
 	}()
+	for addr, mux := range muxes {
+		wg.Go(func() {
+			if err := serveHTTP(ctx, addr, mux); err != nil {
+				errCh <- err
+			}
+		})
+	}
+	for _, loop := range loops {
+		wg.Go(func() {
+			errCh <- loop(ctx)
+		})
+	}
 
-	// Wait for either to fail or context cancel
+	// Wait for any to fail or context cancel
 	select {
 	case err := <-errCh:
 		return err
@@ -82,357 +672,994 @@ func (a *Agent) Run(ctx context.Context) error {
 	}
 }
 
-func (a *Agent) runWindCheck(ctx context.Context) error {
-	// Run immediately on startup
-	fmt.Println("🛫 Wind check: running now...")
-	a.doWindCheck(ctx)
-
-	for {
-		// Then sleep until next run (10am UTC)
-		now := time.Now().UTC()
-		next := time.Date(now.Year(), now.Month(), now.Day(), a.cfg.WindHour, 0, 0, 0, time.UTC)
-		if !now.Before(next) {
-			next = next.Add(24 * time.Hour)
-		}
-		fmt.Printf("🛫 Wind check: next run at %s\n", next.Format("Mon 02 Jan 15:04 UTC"))
-
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(time.Until(next)):
+func (a *Agent) runOnce(ctx context.Context) error {
+	errs := make([]error, len(a.cfg.Checks))
+	var wg sync.WaitGroup
+	for i := range a.cfg.Checks {
+		wg.Go(func() {
+			errs[i] = a.runCheckOnce(ctx, i)
+		})
+	}
+	wg.Wait()
+	if a.outputs != nil {
+		if err := a.writeOutput(errs); err != nil {
+			return err
 		}
-
-		a.doWindCheck(ctx)
 	}
+	return errors.Join(errs...)
 }
 
-func (a *Agent) doWindCheck(ctx context.Context) {
-	forecast, err := a.cfg.WindWeather.Fetch(ctx, a.cfg.WindDays)
-	if err != nil {
-		fmt.Printf("fetch wind forecast: %v\n", err)
-		return
+// runCheckOnce runs check i a single time, for RunOnce mode.
+func (a *Agent) runCheckOnce(ctx context.Context, i int) error {
+	chk := a.cfg.Checks[i]
+	if _, err := a.checkLocation(chk); err != nil {
+		return err
 	}
 
-	report := buildForecastTable(forecast)
-	analysis := buildEasterlyAnalysis(forecast)
+	if err := sleepCtx(ctx, a.cfg.Clock, a.startupDelay(chk)); err != nil {
+		return err
+	}
+	a.log.Info("running check", "check", chk.Type, "location", chk.Name, "trigger", "once")
+	return a.doCheck(ctx, i)
+}
 
-	fmt.Printf("\n🛫 %d-day %s wind forecast:\n%s%s\n", len(forecast), a.cfg.WindLocation, report, analysis)
+// checkLocation validates chk's type and loads the timezone it is scheduled in.
+func (a *Agent) checkLocation(chk Check) (*time.Location, error) {
+	if chk.Type != CheckWind && chk.Type != CheckRain {
+		return nil, fmt.Errorf("check %q: unknown type %q", chk.Name, chk.Type)
+	}
 
-	prompt := fmt.Sprintf(`%s wind forecast. Easterly wind = planes overhead (✈️).
+	// Fail loudly rather than silently scheduling in UTC
+	loc, err := time.LoadLocation(chk.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("check %q: load timezone %q: %w", chk.Name, chk.Timezone, err)
+	}
+	return loc, nil
+}
 
-%s
-%s
-Summarize briefly: how many easterly days and when does wind change direction?`, a.cfg.WindLocation, analysis, report)
+// startupDelay picks a random part of StartupJitter to delay chk's first run.
+func (a *Agent) startupDelay(chk Check) time.Duration {
+	if a.cfg.StartupJitter <= 0 {
+		return 0
+	}
+	delay := rand.N(a.cfg.StartupJitter)
+	a.log.Debug("delaying startup run", "check", chk.Type, "location", chk.Name, "delay", delay)
+	return delay
+}
 
-	summary, err := a.cfg.Ollama.Generate(ctx, prompt)
-	msg := analysis + "\n" + formatTelegramTable(report)
-	if err == nil {
-		msg += "\n" + summary
+// sleepCtx waits d on clock, returning early with ctx's error if it is
+// cancelled.
+func sleepCtx(ctx context.Context, clock Clock, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-clock.After(d):
+		return nil
 	}
-	a.sendTelegram(msg)
 }
 
-func (a *Agent) runRainCheck(ctx context.Context) error {
-	// Load London location, fallback to UTC if not available
-	london, err := time.LoadLocation("Europe/London")
+// doCheck runs one cycle of check i and records its outcome.
+func (a *Agent) doCheck(ctx context.Context, i int) (err error) {
+	chk := a.cfg.Checks[i]
+	metrics.ChecksTotal.WithLabelValues(chk.Name, string(chk.Type)).Inc()
+	a.status.start(i, a.cfg.Clock.Now())
+	defer func() { a.status.finish(i, a.cfg.Clock.Now(), err) }()
+
+	switch chk.Type {
+	case CheckWind:
+		err = a.doWindCheck(ctx, i)
+	case CheckRain:
+		err = a.doRainCheck(ctx, i)
+	}
 	if err != nil {
-		fmt.Printf("warning: could not load London location, using UTC: %v\n", err)
-		london = time.UTC
+		metrics.CheckFailuresTotal.WithLabelValues(chk.Name, string(chk.Type)).Inc()
+		return fmt.Errorf("%s %s check: %w", chk.Name, chk.Type, err)
 	}
 
-	for {
-		now := time.Now().In(london)
-		next := time.Date(now.Year(), now.Month(), now.Day(), a.cfg.RainHour, a.cfg.RainMinute, 0, 0, london)
-		if !now.Before(next) {
-			next = next.Add(24 * time.Hour)
-		}
-		fmt.Printf("🌧️ Rain check: next run at %s (London) / %s (UTC)\n", next.Format("Mon 02 Jan 15:04 MST"), next.UTC().Format("15:04 UTC"))
+	metrics.LastSuccess.WithLabelValues(chk.Name, string(chk.Type)).SetToCurrentTime()
+	a.ready[i].Store(true)
+	if err := a.state.record(chk.stateKey(), a.cfg.Clock.Now()); err != nil {
+		a.log.Warn("save run state failed", "err", err)
+	}
+	return nil
+}
+
+func (a *Agent) doWindCheck(ctx context.Context, i int) error {
+	chk := a.cfg.Checks[i]
 
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(time.Until(next)):
+	now := a.cfg.Clock.Now()
+	forecast, fetchErr := limitedFetch(ctx, a, chk.Weather.FetchLongRange, chk.Days)
+	var stale string
+	if fetchErr != nil {
+		last, ok := a.lastGood.staleWind(i, now, a.cfg.MaxStaleAge)
+		if ok {
+			forecast = fromToday(last.days, func(d weather.ForecastDay) time.Time { return d.Date }, now)
+		}
+		if len(forecast) == 0 {
+			return a.fetchFailed(ctx, i, fetchErr)
+		}
+		a.log.Warn("fetch forecast failed, using last good forecast", "check", chk.Type, "location", chk.Name, "fetched_at", last.fetchedAt.Format(time.RFC3339), "err", fetchErr)
+		stale = staleNote(last.fetchedAt, now, a.checkTimezone(chk))
+	} else {
+		a.lastGood.putWind(i, forecast, now)
+		if err := a.history.recordWind(ctx, chk.Name, now, forecast); err != nil {
+			a.log.Warn("record forecast history failed", "check", chk.Type, "location", chk.Name, "err", err)
 		}
+	}
 
-		fmt.Println("🌧️ Rain check: running now...")
-		a.doRainCheck(ctx)
+	// Past days are shown in the table for context but not analysed
+	opts := a.windOptions()
+	table := buildForecastTable(forecast, opts)
+	report := notify.ASCIITable{}.RenderTable(table)
+	upcoming := upcomingDays(forecast)
+	analysis := buildEasterlyAnalysis(upcoming, opts) + buildGustAnalysis(upcoming, opts.gust) +
+		buildWindSpeedAnalysis(upcoming, opts.speedAlert) + buildOverheadAnalysis(upcoming, opts.ovhAlert)
+
+	a.log.Info("wind forecast",
+		"check", chk.Type,
+		"location", chk.Name,
+		"days", len(upcoming),
+		"easterly_days", countEasterlyDays(upcoming, opts.hysteresis),
+		"gusty_days", countGustyDays(upcoming, opts.gust),
+		"high_wind_days", countHighWindDays(upcoming, opts.speedAlert),
+	)
+	a.log.Debug("wind forecast table", "location", chk.Name, "table", report)
+
+	wr := newWindReport(chk.Name, forecast, opts)
+	var changes string
+	if a.cfg.ShowForecastChanges && fetchErr == nil {
+		changes = a.forecastChanges(i, savedReport{At: now, Wind: &wr})
+	}
+
+	sec := section{text: stale + changes + analysis + "\n", table: table}
+	for _, d := range forecast {
+		sec.dates = append(sec.dates, d.Date)
+	}
+	summary, ok := a.summarize(ctx, chk, a.windPrompt, PromptData{
+		Location: chk.Name,
+		Days:     len(upcoming),
+		Analysis: analysis,
+		Table:    report,
+		Today:    upcoming[0].Date.Format("Mon 02 Jan"),
+	})
+	if ok {
+		sec.summary = summary
 	}
+	if a.outputs != nil {
+		a.outputs[i] = CheckOutput{Wind: &wr, Analysis: analysis, Summary: summary}
+	}
+	data := &MessageData{
+		Location: chk.Name,
+		Type:     chk.Type,
+		Stale:    stale != "",
+		Changes:  changes,
+		Analysis: analysis,
+		Summary:  summary,
+		Table:    report,
+		Wind:     &wr,
+	}
+	sec.data = data
+	if err := a.deliver(ctx, i, sec); err != nil {
+		return fmt.Errorf("deliver: %w", err)
+	}
+	if fetchErr != nil {
+		return fmt.Errorf("fetch forecast (sent last good forecast instead): %w", fetchErr)
+	}
+	return nil
 }
 
-func (a *Agent) doRainCheck(ctx context.Context) {
-	forecast, err := a.cfg.RainWeather.FetchRain(ctx, a.cfg.RainDays)
-	if err != nil {
-		fmt.Printf("fetch rain forecast: %v\n", err)
-		return
+func (a *Agent) doRainCheck(ctx context.Context, i int) error {
+	chk := a.cfg.Checks[i]
+
+	now := a.cfg.Clock.Now()
+	forecast, fetchErr := limitedFetch(ctx, a, chk.Weather.FetchRain, chk.Days)
+	var stale string
+	if fetchErr != nil {
+		last, ok := a.lastGood.staleRain(i, now, a.cfg.MaxStaleAge)
+		if ok {
+			forecast = fromToday(last.days, func(d weather.RainForecast) time.Time { return d.Date }, now)
+		}
+		if len(forecast) == 0 {
+			return a.fetchFailed(ctx, i, fetchErr)
+		}
+		a.log.Warn("fetch forecast failed, using last good forecast", "check", chk.Type, "location", chk.Name, "fetched_at", last.fetchedAt.Format(time.RFC3339), "err", fetchErr)
+		stale = staleNote(last.fetchedAt, now, a.checkTimezone(chk))
+	} else {
+		a.lastGood.putRain(i, forecast, now)
+		if err := a.history.recordRain(ctx, chk.Name, now, forecast); err != nil {
+			a.log.Warn("record forecast history failed", "check", chk.Type, "location", chk.Name, "err", err)
+		}
+	}
+
+	if a.cfg.RainTodayHourly && fetchErr == nil {
+		forecast = a.withTodayHourly(ctx, chk, forecast)
+	}
+
+	opts := a.rainOptions(ctx)
+	table := buildRainTable(forecast, opts)
+	report := notify.ASCIITable{}.RenderTable(table)
+	schoolRun := analyzeSchoolRun(forecast, opts)
+
+	a.log.Info("rain forecast",
+		"check", chk.Type,
+		"location", chk.Name,
+		"days", len(forecast),
+		"today", strings.ReplaceAll(schoolRun, "\n", "; "),
+	)
+	a.log.Debug("rain forecast table", "location", chk.Name, "table", report)
+
+	rr := newRainReport(chk.Name, forecast, opts)
+	var changes string
+	if a.cfg.ShowForecastChanges && fetchErr == nil {
+		changes = a.forecastChanges(i, savedReport{At: now, Rain: &rr})
 	}
 
-	report := buildRainTable(forecast)
-	schoolRun := analyzeSchoolRun(forecast)
+	text := stale + changes + schoolRun + "\n"
+	if opts.dryMorning {
+		text += dryMorningLine(forecast, opts)
+	}
+	if opts.sparkline {
+		text += sparklineLine(forecast, opts.sparklineRamp)
+	}
+	sec := section{text: text, table: table}
+	for _, d := range forecast {
+		sec.dates = append(sec.dates, d.Date)
+	}
+	summary, ok := a.summarize(ctx, chk, a.rainPrompt, PromptData{
+		Location: chk.Name,
+		Days:     len(forecast),
+		Analysis: schoolRun,
+		Schedule: a.cfg.SchoolSchedule.describe(),
+		Table:    report,
+		Today:    forecast[0].Date.Format("Mon 02 Jan"),
+	})
+	if ok {
+		sec.summary = summary
+	}
+	if a.outputs != nil {
+		a.outputs[i] = CheckOutput{Rain: &rr, Analysis: schoolRun, Summary: summary}
+	}
+	data := &MessageData{
+		Location: chk.Name,
+		Type:     chk.Type,
+		Stale:    stale != "",
+		Changes:  changes,
+		Analysis: schoolRun,
+		Summary:  summary,
+		Table:    report,
+		Rain:     &rr,
+	}
+	sec.data = data
+	if err := a.deliver(ctx, i, sec); err != nil {
+		return fmt.Errorf("deliver: %w", err)
+	}
+	if fetchErr != nil {
+		return fmt.Errorf("fetch forecast (sent last good forecast instead): %w", fetchErr)
+	}
+	return nil
+}
 
-	fmt.Printf("\n🌧️ %d-day %s rain forecast:\n%s%s\n", len(forecast), a.cfg.RainLocation, report, schoolRun)
+// fetchFailed reports check i's data as unavailable (to the digest) and
+// returns the fetch error.
+func (a *Agent) fetchFailed(ctx context.Context, i int, err error) error {
+	chk := a.cfg.Checks[i]
+	a.log.Error("fetch forecast failed", "check", chk.Type, "location", chk.Name, "err", err)
+	if err := a.deliver(ctx, i, section{}); err != nil {
+		a.log.Error("send digest failed", "err", err)
+	}
+	return fmt.Errorf("fetch forecast: %w", err)
+}
 
-	prompt := fmt.Sprintf(`%s 7-day rain forecast for school runs.
-Drop-off: 8-9am (weekdays)
-Pickup: 17-18 (Mon/Tue/Thu/Fri) or 15:15-16 (Wednesday early finish)
-Weekend: no school
+// checkTimezone is chk's timezone, UTC if it doesn't load.
+func (a *Agent) checkTimezone(chk Check) *time.Location {
+	if loc, err := a.checkLocation(chk); err == nil {
+		return loc
+	}
+	return time.UTC
+}
 
-TODAY: %s
+// summarize asks Ollama to summarise the forecast for chk. It returns false
+// when summaries are disabled for the check type or the summary could not be
+// produced; the message is then sent without one.
+func (a *Agent) summarize(ctx context.Context, chk Check, tmpl *template.Template, data PromptData) (string, bool) {
+	if a.cfg.Ollama == nil ||
+		(chk.Type == CheckWind && a.cfg.DisableWindSummary) ||
+		(chk.Type == CheckRain && a.cfg.DisableRainSummary) {
+		return "", false
+	}
 
-%s
-Brief friendly summary: umbrella needed today? Which days this week look rainy?`, a.cfg.RainLocation, schoolRun, report)
+	data.Language = a.cfg.SummaryLanguage
+	prompt, err := renderPrompt(tmpl, data)
+	if err != nil {
+		a.log.Error("build prompt failed", "location", chk.Name, "err", err)
+		return "", false
+	}
+	prompt += languageInstruction(tmpl, a.cfg.SummaryLanguage)
 
-	summary, err := a.cfg.Ollama.Generate(ctx, prompt)
-	msg := schoolRun + "\n" + formatTelegramTable(report)
-	if err == nil {
-		msg += "\n" + summary
+	genCtx, cancel := context.WithTimeout(ctx, a.cfg.OllamaTimeout)
+	defer cancel()
+	summary, err := a.cfg.Ollama.Generate(genCtx, prompt)
+	if err != nil {
+		switch {
+		case errors.Is(err, ollama.ErrCircuitOpen):
+			a.log.Info("ollama circuit breaker open, sending without summary", "location", chk.Name)
+		case ctx.Err() == nil && errors.Is(genCtx.Err(), context.DeadlineExceeded):
+			a.log.Warn("ollama summary timed out, sending without it", "location", chk.Name, "timeout", a.cfg.OllamaTimeout)
+		default:
+			a.log.Warn("ollama summary unavailable", "location", chk.Name, "err", err)
+		}
+		return "", false
+	}
+	if a.cfg.SanitizeSummary {
+		summary = sanitizeSummary(summary)
+		if summary == "" {
+			a.log.Warn("ollama summary empty once sanitized, sending without it", "location", chk.Name)
+			return "", false
+		}
 	}
-	a.sendTelegram(msg)
+	return summary, true
 }
 
-func (a *Agent) sendTelegram(msg string) {
-	if a.cfg.TelegramToken == "" || a.cfg.TelegramChatID == "" {
-		return
+// dryRunNotifiers wraps each notifier so it prints instead of sending. With
+// no notifiers configured, messages are still printed once.
+func dryRunNotifiers(notifiers []notify.Notifier) []notify.Notifier {
+	if len(notifiers) == 0 {
+		return []notify.Notifier{&notify.DryRun{}}
 	}
-	if err := sendTelegramMessage(a.cfg.TelegramToken, a.cfg.TelegramChatID, msg); err != nil {
-		fmt.Printf("Telegram failed: %v\n", err)
+	out := make([]notify.Notifier, 0, len(notifiers))
+	for _, n := range notifiers {
+		out = append(out, &notify.DryRun{Target: n})
 	}
+	return out
 }
 
-func buildRainTable(days []weather.RainForecast) string {
-	var b strings.Builder
-	b.WriteString("Date       | Drop | Pick\n")
-	b.WriteString("-----------+------+------\n")
-	for _, day := range days {
-		weekday := day.Date.Weekday()
+// notify sends msg to every configured notifier, each rendering its tables
+// in its own format, attempting all of them even if some fail. Notifiers in
+// their quiet hours hold it instead.
+func (a *Agent) notify(ctx context.Context, msg notify.Message) error {
+	return a.notifyEach(ctx, func(int) notify.Message { return msg })
+}
 
-		// Skip weekends
-		if weekday == time.Saturday || weekday == time.Sunday {
-			b.WriteString(fmt.Sprintf("%s |  --  |  --\n", day.Date.Format("Mon 02 Jan")))
+// notifyEach is notify with msgFor(i) as notifier i's message.
+func (a *Agent) notifyEach(ctx context.Context, msgFor func(i int) notify.Message) error {
+	now := a.cfg.Clock.Now()
+	var errs []error
+	for i, n := range a.cfg.Notifiers {
+		msg := msgFor(i)
+		if a.quiet.hold(i, msg, now) {
+			a.log.Info("notification held for quiet hours", "until", a.quiet.nextEnd(now).Format(time.RFC3339))
 			continue
 		}
+		if err := a.send(ctx, n, msg); err != nil {
+			a.log.Error("notify failed", "err", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// send delivers msg through n, flushing it if ctx is cancelled mid-send.
+func (a *Agent) send(ctx context.Context, n notify.Notifier, msg notify.Message) error {
+	err := notify.Send(ctx, n, msg)
+	if err != nil && ctx.Err() != nil {
+		err = a.flush(ctx, n, msg)
+	}
+	return err
+}
 
-		dropProb := getHourProb(day, 8, 9)
-		pickProb := getPickupProb(day, weekday)
+// flush makes a best-effort send of msg once ctx is cancelled, typically on
+// shutdown mid-check, so a message already built isn't lost. It runs on a
+// context detached from ctx and bounded by Config.FlushTimeout.
+func (a *Agent) flush(ctx context.Context, n notify.Notifier, msg notify.Message) error {
+	flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), a.cfg.FlushTimeout)
+	defer cancel()
+	if err := notify.Send(flushCtx, n, msg); err != nil {
+		a.log.Warn("flush on cancel failed, message dropped", "timeout", a.cfg.FlushTimeout, "err", err)
+		return fmt.Errorf("flush: %w", err)
+	}
+	a.log.Info("flushed message on cancel")
+	return nil
+}
 
-		dropStr := fmt.Sprintf("%3d%%", dropProb)
-		if dropProb >= 30 {
-			dropStr = fmt.Sprintf("%2d%%☔", dropProb)
+func buildRainTable(days []weather.RainForecast, opts rainOptions) notify.Table {
+	// The ensemble spread column only appears when the forecast has one
+	spread := slices.ContainsFunc(days, func(d weather.RainForecast) bool { return d.HasSpread })
+
+	// One column per school-run window, e.g. " Drop " and " Pick "
+	cols := opts.schedule.columns()
+	layout := dateLayout(len(days), opts.compact)
+	t := notify.Table{Header: []string{dateHeader(layout)}}
+	noSchool := " -- "
+	for _, k := range cols {
+		if opts.primary == RainMetricMM {
+			t.Header = append(t.Header, " "+k.column()+" mm ")
+			noSchool = "  --   "
+		} else {
+			t.Header = append(t.Header, " "+k.column()+" ")
 		}
-		pickStr := fmt.Sprintf("%3d%%", pickProb)
-		if pickProb >= 30 {
-			pickStr = fmt.Sprintf("%2d%%☔", pickProb)
+	}
+	t.Header = append(t.Header, "  mm")
+	last := len(t.Header) - 1
+	if spread {
+		t.Header[last] += " "
+		t.Header = append(t.Header, "  ±mm")
+	}
+	for _, day := range days {
+		amount := "  --"
+		if day.HasPrecipMM {
+			amount = fmt.Sprintf("%4.1f", day.PrecipMM)
 		}
 
-		b.WriteString(fmt.Sprintf("%s | %s | %s\n",
-			day.Date.Format("Mon 02 Jan"),
-			dropStr,
-			pickStr,
-		))
+		row := []string{day.Date.Format(layout) + " "}
+		sd, school := opts.schedule[day.Date.Weekday()]
+		school = school && !inRanges(opts.holidays, day.Date)
+		for _, k := range cols {
+			// Skip non-school days
+			cell := noSchool
+			if school {
+				var hw *HourWindow
+				if w := sd.window(k); w != nil {
+					hw = &w.HourWindow
+				}
+				cell = rainCell(day, hw, opts)
+			}
+			row = append(row, " "+cell+" ")
+		}
+		row = append(row, " "+amount)
+		if spread {
+			row[last] += " "
+			row = append(row, " "+spreadCell(day, opts.markers))
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	if slices.ContainsFunc(days, lowConfidence) {
+		t.Footnotes = append(t.Footnotes, opts.markers.LowConfidence+" ensemble members disagree, low confidence")
 	}
-	return b.String()
+	if opts.compact {
+		t = t.Fit(opts.width)
+	}
+	return t
 }
 
-func getHourProb(day weather.RainForecast, startHour, endHour int) int {
-	if len(day.MorningRainProb) == 0 {
-		return day.PrecipProb
+// rainCell formats the probability for one window, or "--" when there is none.
+func rainCell(day weather.RainForecast, w *HourWindow, opts rainOptions) string {
+	if opts.primary == RainMetricMM {
+		return rainCellMM(day, w, opts)
 	}
-	// MorningRainProb covers hours 6,7,8,9,10 (indices 0,1,2,3,4)
-	maxProb := 0
-	for i := startHour - 6; i <= endHour-6 && i < len(day.MorningRainProb); i++ {
-		if i >= 0 && day.MorningRainProb[i] > maxProb {
-			maxProb = day.MorningRainProb[i]
-		}
+	if w == nil {
+		return " -- "
 	}
-	if maxProb == 0 {
-		return day.PrecipProb
+	prob := windowProb(day, *w, opts)
+	if prob >= opts.maybe {
+		if _, snow := windowSnow(day, *w, opts); snow {
+			return fmt.Sprintf("%2d%%%s", prob, opts.markers.Snow)
+		}
+		return fmt.Sprintf("%2d%%%s", prob, opts.markers.Rain)
 	}
-	return maxProb
+	return fmt.Sprintf("%3d%%", prob)
 }
 
-func getPickupProb(day weather.RainForecast, weekday time.Weekday) int {
-	// AfternoonProb covers hours 15,16,17,18 (indices 0,1,2,3)
-	if len(day.AfternoonProb) == 0 {
-		return day.PrecipProb
+// spreadCell shows the ensemble spread of the daily total, marked low
+// confidence when the members disagree: the spread is at least 1mm and
+// exceeds the total itself.
+func spreadCell(day weather.RainForecast, m Markers) string {
+	if !day.HasSpread {
+		return "  --"
 	}
-
-	var maxProb int
-	if weekday == time.Wednesday {
-		// Wednesday: 15:15-16:00 (indices 0,1)
-		for i := 0; i <= 1 && i < len(day.AfternoonProb); i++ {
-			if day.AfternoonProb[i] > maxProb {
-				maxProb = day.AfternoonProb[i]
-			}
-		}
-	} else {
-		// Other days: 17:00-18:00 (indices 2,3)
-		for i := 2; i <= 3 && i < len(day.AfternoonProb); i++ {
-			if day.AfternoonProb[i] > maxProb {
-				maxProb = day.AfternoonProb[i]
-			}
-		}
+	if lowConfidence(day) {
+		return fmt.Sprintf("%4.1f%s", day.PrecipSpreadMM, m.LowConfidence)
 	}
+	return fmt.Sprintf("%4.1f", day.PrecipSpreadMM)
+}
 
-	if maxProb == 0 {
-		return day.PrecipProb
+func lowConfidence(day weather.RainForecast) bool {
+	return day.HasSpread && day.PrecipSpreadMM >= 1 && day.PrecipSpreadMM > day.PrecipMM
+}
+
+// rainCellMM is rainCell showing the window's heaviest hourly amount, marked
+// like rainCell when the probability reaches "maybe umbrella".
+func rainCellMM(day weather.RainForecast, w *HourWindow, opts rainOptions) string {
+	if w == nil {
+		return "  --   "
+	}
+	mm, ok := windowMM(day, *w)
+	if !ok {
+		return "  --   " // no amounts in the forecast
+	}
+	marker := "  "
+	if windowProb(day, *w, opts) >= opts.maybe {
+		marker = opts.markers.Rain
+		if _, snow := windowSnow(day, *w, opts); snow {
+			marker = opts.markers.Snow
+		}
 	}
-	return maxProb
+	return fmt.Sprintf("%5.1f%s", mm, marker)
 }
 
-func analyzeSchoolRun(days []weather.RainForecast) string {
+func analyzeSchoolRun(days []weather.RainForecast, opts rainOptions) string {
 	if len(days) == 0 {
 		return "No forecast data"
 	}
 	today := days[0]
 	weekday := today.Date.Weekday()
 
-	// Weekend - no school
-	if weekday == time.Saturday || weekday == time.Sunday {
-		return "📅 Weekend - no school!"
+	sd, ok := opts.schedule[weekday]
+	if !ok {
+		// Weekend - no school
+		if weekday == time.Saturday || weekday == time.Sunday {
+			return "📅 Weekend - no school!"
+		}
+		return "📅 No school today!"
+	}
+	if inRanges(opts.holidays, today.Date) {
+		return "📅 School holiday - no school run!"
+	}
+	windows := sd.windows()
+	if len(windows) == 0 {
+		return "📅 No school run today"
 	}
 
-	dropProb := getHourProb(today, 8, 9)
-	pickProb := getPickupProb(today, weekday)
+	var lines []string
+	for _, w := range windows {
+		lines = append(lines, windowVerdict(today, w, opts))
+	}
+	return strings.Join(lines, "\n")
+}
 
-	// Pickup time info
-	pickTime := "17-18"
-	if weekday == time.Wednesday {
-		pickTime = "15:15-16"
+// windowVerdict phrases the rain risk for one school-run window, adding the
+// expected intensity when the forecast carries amounts. Snow gets its own
+// wording.
+func windowVerdict(day weather.RainForecast, w SchoolWindow, opts rainOptions) string {
+	name, hw := w.verdictName(), w.HourWindow
+	prob := windowProb(day, hw, opts)
+	if cm, snow := windowSnow(day, hw, opts); snow && prob >= opts.maybe {
+		if prob >= opts.definite {
+			return fmt.Sprintf("%s %s (%s): %d%% - Snow! Boots and coats (%.1fcm/h)", opts.markers.Snow, name, w.title(), prob, cm)
+		}
+		return fmt.Sprintf("%s %s (%s): %d%% - Maybe snow (%.1fcm/h)", opts.markers.MaybeSnow, name, w.title(), prob, cm)
 	}
 
-	var result strings.Builder
+	amount := ""
+	if mm, ok := windowMM(day, hw); ok && prob >= opts.maybe {
+		amount = fmt.Sprintf(" (%s, %.1fmm/h)", intensity(mm, opts), mm)
+	}
+	// Rain starting or clearing mid-window; steady wet or dry adds nothing
+	trend := ""
+	if t := rainTransition(day, hw, opts); t != nil {
+		trend = ", " + t.phrase()
+	}
 
-	// Drop-off analysis
-	if dropProb >= 70 {
-		result.WriteString(fmt.Sprintf("☔ DROP-OFF (8-9am): %d%% - Umbrella!\n", dropProb))
-	} else if dropProb >= 30 {
-		result.WriteString(fmt.Sprintf("🌦️ DROP-OFF (8-9am): %d%% - Maybe umbrella\n", dropProb))
-	} else {
-		result.WriteString(fmt.Sprintf("☀️ DROP-OFF (8-9am): %d%%\n", dropProb))
+	if prob >= opts.definite {
+		return fmt.Sprintf("%s %s (%s): %d%% - Umbrella!%s%s", opts.markers.Rain, name, w.title(), prob, amount, trend)
+	} else if prob >= opts.maybe {
+		return fmt.Sprintf("%s %s (%s): %d%% - Maybe umbrella%s%s", opts.markers.MaybeRain, name, w.title(), prob, amount, trend)
 	}
+	return fmt.Sprintf("%s %s (%s): %d%%%s", opts.markers.Dry, name, w.title(), prob, trend)
+}
 
-	// Pickup analysis
-	if pickProb >= 70 {
-		result.WriteString(fmt.Sprintf("☔ PICKUP (%s): %d%% - Umbrella!", pickTime, pickProb))
-	} else if pickProb >= 30 {
-		result.WriteString(fmt.Sprintf("🌦️ PICKUP (%s): %d%% - Maybe umbrella", pickTime, pickProb))
-	} else {
-		result.WriteString(fmt.Sprintf("☀️ PICKUP (%s): %d%%", pickTime, pickProb))
+// upcomingDays drops the leading past days from a forecast.
+func upcomingDays(days []weather.ForecastDay) []weather.ForecastDay {
+	for i, d := range days {
+		if !d.Past {
+			return days[i:]
+		}
 	}
+	return days[len(days):]
+}
 
-	return result.String()
+// windOptions carries the settings the wind table and analysis depend on.
+type windOptions struct {
+	gust       float64 // km/h above which a day is gusty
+	speedAlert float64 // km/h above which a day is high-wind; 0 disables
+	hysteresis float64 // degrees, see Config.DirectionHysteresis
+	margin     int     // days, see Config.DominantMargin
+	ovhColumn  bool    // see Config.ShowOverheadScore
+	ovhAlert   int     // score, see Config.OverheadAlert
+	compass    int     // points shown in the Dir column, see Config.CompassResolution
+	dirStyle   string  // see Config.DirectionStyle
+	compact    bool    // see Config.CompactTable
+	width      int     // see Config.TableWidth
+
+	// Outlook line templates; nil leaves the line out
+	noEasterly   *template.Template
+	manyEasterly *template.Template
+
+	markers Markers
 }
 
-// formatTelegramTable wraps the table in Markdown code block for Telegram
-func formatTelegramTable(table string) string {
-	return "```\n" + table + "```"
+func (a *Agent) windOptions() windOptions {
+	return windOptions{
+		gust:       a.cfg.GustThreshold,
+		speedAlert: a.cfg.WindSpeedAlert,
+		hysteresis: a.cfg.DirectionHysteresis,
+		margin:     a.cfg.DominantMargin,
+		ovhColumn:  a.cfg.ShowOverheadScore,
+		ovhAlert:   a.cfg.OverheadAlert,
+		compass:    a.cfg.CompassResolution,
+		dirStyle:   a.cfg.DirectionStyle,
+		compact:    a.cfg.CompactTable,
+		width:      a.cfg.TableWidth,
+
+		noEasterly:   a.noEasterly,
+		manyEasterly: a.manyEasterly,
+
+		markers: a.cfg.Markers,
+	}
 }
 
-func buildForecastTable(days []weather.ForecastDay) string {
-	var b strings.Builder
-	b.WriteString("Date       | Wind | Dir | East\n")
-	b.WriteString("-----------+------+-----+-----\n")
-	for _, day := range days {
+// buildForecastTable renders the wind table. Past and long-range days are
+// marked "*" and "~" after the date, with footnotes. The wind column gets a
+// high-wind marker slot only when the speed alert is set. The Dir column is
+// E or W unless opts asks for a finer compass. An Ovh column, when enabled,
+// follows East. A compact table has shorter dates and fits opts.width.
+func buildForecastTable(days []weather.ForecastDay, opts windOptions) notify.Table {
+	layout := dateLayout(len(days), opts.compact)
+	t := notify.Table{Header: []string{dateHeader(layout), " Wind ", " Gust   ", " Dir ", " East"}}
+	if opts.speedAlert > 0 {
+		t.Header[1] = " Wind   "
+	}
+	if opts.ovhColumn {
+		t.Header = append(t.Header, " Ovh")
+	}
+	past, longRange := false, false
+	easterly := easterlyDays(days, opts.hysteresis)
+	for i, day := range days {
+		dateMarker := " "
+		switch {
+		case day.Past:
+			dateMarker = "*"
+			past = true
+		case day.LongRange:
+			dateMarker = "~"
+			longRange = true
+		}
 		eastMarker := "   "
-		if isEasterly(day.WindDirMean) {
-			eastMarker = " ✈️"
+		switch classifyDay(easterly[i], isGusty(day, opts.gust)) {
+		case OverheadSteady:
+			eastMarker = " " + opts.markers.Easterly
+		case OverheadGusty:
+			eastMarker = " " + opts.markers.GoAround // go-arounds likely
 		}
-		b.WriteString(fmt.Sprintf("%s | %4.0f | %-3s |%s\n",
-			day.Date.Format("Mon 02 Jan"),
-			day.WindSpeedMax,
-			degToCompass(day.WindDirMean),
+		gustMarker := "   "
+		if isGusty(day, opts.gust) {
+			gustMarker = " " + opts.markers.Gusty
+		}
+		windMarker := ""
+		if opts.speedAlert > 0 {
+			windMarker = "   "
+			if isHighWind(day, opts.speedAlert) {
+				windMarker = " " + opts.markers.HighWind
+			}
+		}
+		row := []string{
+			day.Date.Format(layout) + dateMarker,
+			fmt.Sprintf(" %4.0f%s ", day.WindSpeedMax, windMarker),
+			fmt.Sprintf(" %4.0f%s ", day.WindGustMax, gustMarker),
+			fmt.Sprintf(" %-3s ", direction(day.WindDirMean, easterly[i], opts)),
 			eastMarker,
-		))
+		}
+		if opts.ovhColumn {
+			row = append(row, fmt.Sprintf(" %3d", overheadScore(day)))
+		}
+		t.Rows = append(t.Rows, row)
 	}
-	return b.String()
+	if past {
+		t.Footnotes = append(t.Footnotes, "* past day, for context")
+	}
+	if longRange {
+		t.Footnotes = append(t.Footnotes, "~ ensemble outlook, low confidence")
+	}
+	if opts.compact {
+		t = t.Fit(opts.width)
+	}
+	return t
 }
 
-// degToCompass converts degrees to E or W (what matters for flight paths)
-func degToCompass(deg float64) string {
-	deg = float64(int(deg+360) % 360)
-	// East: 0-180, West: 180-360
-	if deg > 0 && deg < 180 {
+// dateLayout is the time layout of a table's date column for n days: the
+// weekday alone in a compact table of a week or less, where no weekday
+// repeats.
+func dateLayout(n int, compact bool) string {
+	switch {
+	case !compact:
+		return "Mon 02 Jan"
+	case n <= 7:
+		return "Mon"
+	default:
+		return "02 Jan"
+	}
+}
+
+// dateHeader heads a date column in layout, padded over the marker slot
+// that follows each date.
+func dateHeader(layout string) string {
+	return fmt.Sprintf("%-*s", len(layout)+1, "Date")
+}
+
+// compass names the direction that matters for flight paths: E or W
+func compass(easterly bool) string {
+	if easterly {
 		return "E"
 	}
 	return "W"
 }
 
+// Values of Config.DirectionStyle.
+const (
+	DirectionLetters    = "letters"
+	DirectionArrowsFrom = "arrows-from"
+	DirectionArrowsTo   = "arrows-to"
+)
+
+// direction labels a day's wind for the Dir column in opts' style. Letters
+// on a 2-point compass are compass(easterly), so the table agrees with the
+// flight-path call.
+func direction(deg float64, easterly bool, opts windOptions) string {
+	switch opts.dirStyle {
+	case DirectionArrowsFrom:
+		return arrow(deg, false)
+	case DirectionArrowsTo:
+		return arrow(deg, true)
+	}
+	switch opts.compass {
+	case 8:
+		return compass8(deg)
+	case 16:
+		return compass16(deg)
+	default:
+		return compass(easterly)
+	}
+}
+
+var compassPoints = [16]string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// compass16 names the nearest of the 16 compass points to deg, each
+// covering 22.5°: 0° and 11° are N, 12° is NNE.
+func compass16(deg float64) string {
+	deg = math.Mod(math.Mod(deg, 360)+360, 360)
+	return compassPoints[int(math.Round(deg/22.5))%16]
+}
+
+// compass8 names the nearest of the 8 principal compass points to deg,
+// each covering 45°.
+func compass8(deg float64) string {
+	deg = math.Mod(math.Mod(deg, 360)+360, 360)
+	return compassPoints[int(math.Round(deg/45))%8*2]
+}
+
+// arrows point north, northeast, ... northwest, clockwise.
+var arrows = [8]string{"↑", "↗", "→", "↘", "↓", "↙", "←", "↖"}
+
+// arrow points to the nearest of the 8 compass points to deg, the direction
+// the wind comes from, or with downwind the direction it blows to.
+func arrow(deg float64, downwind bool) string {
+	if downwind {
+		deg += 180
+	}
+	deg = math.Mod(math.Mod(deg, 360)+360, 360)
+	return arrows[int(math.Round(deg/45))%8]
+}
+
 // isEasterly returns true if wind is from the east
 func isEasterly(deg float64) bool {
 	deg = float64(int(deg+360) % 360)
 	return deg > 0 && deg < 180
 }
 
+// easterlyDays classifies each day as easterly or not. With hysteresis > 0 a
+// day only flips from the previous day's direction when its wind is at least
+// hysteresis degrees inside the other half of the compass.
+func easterlyDays(days []weather.ForecastDay, hysteresis float64) []bool {
+	out := make([]bool, len(days))
+	for i, d := range days {
+		east := isEasterly(d.WindDirMean)
+		if i > 0 && hysteresis > 0 && east != out[i-1] && !insideHalf(d.WindDirMean, east, hysteresis) {
+			east = out[i-1]
+		}
+		out[i] = east
+	}
+	return out
+}
+
+// insideHalf reports whether deg lies in the easterly (0-180) or westerly
+// (180-360) half of the compass with at least margin degrees to spare.
+func insideHalf(deg float64, easterly bool, margin float64) bool {
+	deg = math.Mod(math.Mod(deg, 360)+360, 360)
+	if !easterly {
+		deg = math.Mod(deg+180, 360) // rotate the westerly half onto 0-180
+	}
+	return deg >= margin && deg <= 180-margin
+}
+
 // countEasterlyDays counts how many days have easterly winds
-func countEasterlyDays(days []weather.ForecastDay) int {
+func countEasterlyDays(days []weather.ForecastDay, hysteresis float64) int {
 	count := 0
-	for _, d := range days {
-		if isEasterly(d.WindDirMean) {
+	for _, east := range easterlyDays(days, hysteresis) {
+		if east {
 			count++
 		}
 	}
 	return count
 }
 
-// buildEasterlyAnalysis creates a simple summary with dominant direction
-func buildEasterlyAnalysis(days []weather.ForecastDay) string {
-	eastCount := countEasterlyDays(days)
-	westCount := len(days) - eastCount
+// isGusty returns true if the day's max gust exceeds the threshold (km/h)
+func isGusty(day weather.ForecastDay, threshold float64) bool {
+	return day.WindGustMax > threshold
+}
 
-	var dominant string
-	if eastCount > westCount {
-		dominant = "E ✈️"
-	} else if westCount > eastCount {
-		dominant = "W"
-	} else {
-		dominant = "Mixed"
+// countGustyDays counts how many days have gusts above the threshold
+func countGustyDays(days []weather.ForecastDay, threshold float64) int {
+	count := 0
+	for _, d := range days {
+		if isGusty(d, threshold) {
+			count++
+		}
 	}
+	return count
+}
 
-	return fmt.Sprintf("Dominant: %s | East: %d days | West: %d days\n", dominant, eastCount, westCount)
+// buildGustAnalysis creates a one-line summary of gusty days
+func buildGustAnalysis(days []weather.ForecastDay, threshold float64) string {
+	return fmt.Sprintf("Gusty: %d days (gusts > %.0f km/h)\n", countGustyDays(days, threshold), threshold)
 }
 
-// TelegramMessage is the payload for Telegram API
-type TelegramMessage struct {
-	ChatID    string `json:"chat_id"`
-	Text      string `json:"text"`
-	ParseMode string `json:"parse_mode"`
+// isHighWind returns true if the day's max wind speed exceeds alert (km/h);
+// never when alert is 0 (disabled)
+func isHighWind(day weather.ForecastDay, alert float64) bool {
+	return alert > 0 && day.WindSpeedMax > alert
 }
 
-func sendTelegramMessage(token, chatID, message string) error {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", token)
+// countHighWindDays counts how many days have wind speeds above alert
+func countHighWindDays(days []weather.ForecastDay, alert float64) int {
+	count := 0
+	for _, d := range days {
+		if isHighWind(d, alert) {
+			count++
+		}
+	}
+	return count
+}
 
-	msg := TelegramMessage{
-		ChatID:    chatID,
-		Text:      message,
-		ParseMode: "Markdown",
+// buildWindSpeedAnalysis creates a one-line summary of high-wind days, or
+// nothing when the alert is disabled
+func buildWindSpeedAnalysis(days []weather.ForecastDay, alert float64) string {
+	if alert <= 0 {
+		return ""
 	}
+	return fmt.Sprintf("High wind: %d days (wind > %.0f km/h)\n", countHighWindDays(days, alert), alert)
+}
 
-	jsonData, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("failed to marshal telegram message: %w", err)
+// buildEasterlyAnalysis creates a simple summary with dominant direction and
+// flying conditions
+func buildEasterlyAnalysis(days []weather.ForecastDay, opts windOptions) string {
+	easterly := easterlyDays(days, opts.hysteresis)
+	eastCount := countEasterlyDays(days, opts.hysteresis)
+	westCount := len(days) - eastCount
+
+	var dominant string
+	switch diff := eastCount - westCount; {
+	case opts.margin > 0 && diff >= -opts.margin && diff <= opts.margin:
+		// Ties included
+		dominant = "Mixed / variable"
+	case diff == 0:
+		dominant = "Mixed"
+	case diff > 0:
+		dominant = "E " + opts.markers.Easterly
+	default:
+		dominant = "W"
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create telegram request: %w", err)
+	counts := make(map[FlyingConditions]int)
+	for i, d := range days {
+		counts[classifyDay(easterly[i], isGusty(d, opts.gust))]++
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	data := OutlookData{Days: len(days), East: eastCount, West: westCount}
+	var outlook string
+	switch {
+	case len(days) > 0 && eastCount == 0:
+		outlook = renderOutlook(opts.noEasterly, data)
+	case eastCount >= manyEasterlyDays:
+		outlook = renderOutlook(opts.manyEasterly, data)
+	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send telegram message: %w", err)
+	return outlook +
+		fmt.Sprintf("Dominant: %s | East: %d days | West: %d days\n", dominant, eastCount, westCount) +
+		buildStreakAnalysis(days, opts.hysteresis) +
+		fmt.Sprintf("Overhead: %d steady, %d gusty (go-arounds likely) | Away: %d steady, %d gusty\n",
+			counts[OverheadSteady], counts[OverheadGusty], counts[AwaySteady], counts[AwayGusty])
+}
+
+// renderOutlook executes tmpl as one analysis line. The templates are checked
+// in New, so a failure here only drops the line.
+func renderOutlook(tmpl *template.Template, data OutlookData) string {
+	if tmpl == nil {
+		return ""
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Printf("warning: close telegram response body: %v\n", cerr)
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
+
+// directionStreak returns the direction of the first day's wind, how many
+// consecutive days keep it, and the first day it changes (ok false when it
+// holds for the whole forecast).
+func directionStreak(days []weather.ForecastDay, hysteresis float64) (easterly bool, streak int, change time.Time, ok bool) {
+	if len(days) == 0 {
+		return false, 0, time.Time{}, false
+	}
+	flags := easterlyDays(days, hysteresis)
+	easterly = flags[0]
+	for i, d := range days {
+		if flags[i] != easterly {
+			return easterly, streak, d.Date, true
 		}
-	}()
+		streak++
+	}
+	return easterly, streak, time.Time{}, false
+}
+
+// buildStreakAnalysis creates a one-line summary of when the wind next flips,
+// e.g. "Now: easterly through Thu 22 Oct (3 days), then westerly"
+func buildStreakAnalysis(days []weather.ForecastDay, hysteresis float64) string {
+	easterly, streak, change, ok := directionStreak(days, hysteresis)
+	if streak == 0 {
+		return ""
+	}
+	now, next := "westerly", "easterly"
+	if easterly {
+		now, next = next, now
+	}
+	if !ok {
+		return fmt.Sprintf("Now: %s for all %d days, no change in sight\n", now, streak)
+	}
+	last := days[streak-1].Date
+	return fmt.Sprintf("Now: %s through %s (%s), then %s from %s\n",
+		now, last.Format("Mon 02 Jan"), countDays(streak, "day"), next, change.Format("Mon 02 Jan"))
+}
+
+// FlyingConditions combines wind direction and gusts into what they mean for
+// Heathrow approaches: easterlies bring arrivals overhead.
+type FlyingConditions int
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("telegram API returned status %d: %s", resp.StatusCode, string(body))
+const (
+	OverheadSteady FlyingConditions = iota // easterly, calm: planes overhead, steady approach
+	OverheadGusty                          // easterly, gusty: go-arounds likely
+	AwaySteady                             // westerly, calm
+	AwayGusty                              // westerly, gusty
+)
+
+func (c FlyingConditions) String() string {
+	switch c {
+	case OverheadSteady:
+		return "planes overhead, steady approach"
+	case OverheadGusty:
+		return "planes overhead, go-arounds likely"
+	case AwaySteady:
+		return "planes away, steady"
+	case AwayGusty:
+		return "planes away, gusty"
 	}
+	return fmt.Sprintf("FlyingConditions(%d)", int(c))
+}
 
-	return nil
+// classifyDay returns the flying conditions for a day with the given
+// direction (see easterlyDays) and gustiness (see isGusty).
+func classifyDay(easterly, gusty bool) FlyingConditions {
+	switch {
+	case easterly && gusty:
+		return OverheadGusty
+	case easterly:
+		return OverheadSteady
+	case gusty:
+		return AwayGusty
+	}
+	return AwaySteady
 }
@@ -0,0 +1,373 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/notify"
+	"github.com/emanuelefumagalli/test-agent/internal/ollama"
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+// fakeWeather is a weather.Client serving canned forecasts, or err. fetch,
+// when set, is called at the start of every fetch.
+type fakeWeather struct {
+	wind  []weather.ForecastDay
+	rain  []weather.RainForecast
+	err   error
+	fetch func(ctx context.Context)
+}
+
+func (w *fakeWeather) Fetch(ctx context.Context, days int) ([]weather.ForecastDay, error) {
+	if w.fetch != nil {
+		w.fetch(ctx)
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	return w.wind[:min(days, len(w.wind))], nil
+}
+
+func (w *fakeWeather) FetchLongRange(ctx context.Context, days int) ([]weather.ForecastDay, error) {
+	return w.Fetch(ctx, days)
+}
+
+func (w *fakeWeather) FetchRain(ctx context.Context, days int) ([]weather.RainForecast, error) {
+	if w.fetch != nil {
+		w.fetch(ctx)
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	return w.rain[:min(days, len(w.rain))], nil
+}
+
+func (w *fakeWeather) Validate() error { return nil }
+
+func (w *fakeWeather) Horizon() (forecast, longRange int) {
+	return weather.MaxForecastDays, weather.MaxLongRangeDays
+}
+
+// newTestAgent returns an agent for cfg, with notifications off unless cfg
+// has notifiers and, when cfg has no checks, a wind check on a fakeWeather.
+func newTestAgent(t *testing.T, cfg Config) *Agent {
+	t.Helper()
+	if len(cfg.Checks) == 0 {
+		cfg.Checks = []Check{{Name: "Heathrow", Type: CheckWind, Weather: &fakeWeather{}}}
+	}
+	cfg.DisableNotifications = len(cfg.Notifiers) == 0
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// recordingNotifier keeps every message it is sent.
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (*recordingNotifier) Name() string { return "recording" }
+
+func (n *recordingNotifier) Notify(ctx context.Context, message string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+func (n *recordingNotifier) sent() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return slices.Clone(n.messages)
+}
+
+// windDays returns a forecast from 16 October 2026 with one day per
+// direction, each with gusts of gust km/h.
+func windDays(gust float64, dirs ...float64) []weather.ForecastDay {
+	days := make([]weather.ForecastDay, len(dirs))
+	for i, dir := range dirs {
+		days[i] = weather.ForecastDay{
+			Date:         time.Date(2026, 10, 16+i, 0, 0, 0, 0, time.UTC),
+			WindSpeedMax: 15,
+			WindGustMax:  gust,
+			WindDirMean:  dir,
+		}
+	}
+	return days
+}
+
+func TestClassifyDay(t *testing.T) {
+	const threshold = 40
+	tests := []struct {
+		dir, gust float64
+		want      FlyingConditions
+	}{
+		{90, 20, OverheadSteady},
+		{90, 39.9, OverheadSteady},
+		{90, 40, OverheadSteady}, // at the threshold isn't gusty
+		{90, 40.1, OverheadGusty},
+		{1, 41, OverheadGusty},
+		{179, 20, OverheadSteady},
+		{0, 20, AwaySteady}, // due north and south aren't easterly
+		{180, 20, AwaySteady},
+		{270, 40, AwaySteady},
+		{270, 40.1, AwayGusty},
+		{359, 60, AwayGusty},
+	}
+	for _, tt := range tests {
+		day := windDays(tt.gust, tt.dir)[0]
+		if got := classifyDay(isEasterly(tt.dir), isGusty(day, threshold)); got != tt.want {
+			t.Errorf("%g° gusting %g km/h: %s, want %s", tt.dir, tt.gust, got, tt.want)
+		}
+	}
+}
+
+func TestEasterlyAnalysisCountsFlyingConditions(t *testing.T) {
+	a := newTestAgent(t, Config{GustThreshold: 30})
+	days := append(windDays(30, 90, 90, 270), windDays(30.5, 90, 270, 270)...)
+	got := buildEasterlyAnalysis(days, a.windOptions())
+	want := "Overhead: 2 steady, 1 gusty (go-arounds likely) | Away: 1 steady, 2 gusty\n"
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("analysis =\n%s\nwant it to end with\n%s", got, want)
+	}
+}
+
+func TestWindSpeedAlert(t *testing.T) {
+	tests := []struct {
+		speed, alert float64
+		want         bool
+	}{
+		{30, 0, false}, // disabled
+		{29.9, 30, false},
+		{30, 30, false}, // exactly at the alert doesn't exceed it
+		{30.1, 30, true},
+		{55, 30, true},
+	}
+	for _, tt := range tests {
+		day := weather.ForecastDay{WindSpeedMax: tt.speed}
+		if got := isHighWind(day, tt.alert); got != tt.want {
+			t.Errorf("isHighWind(%g, alert %g) = %v, want %v", tt.speed, tt.alert, got, tt.want)
+		}
+	}
+}
+
+func TestWindSpeedAnalysisAndMarker(t *testing.T) {
+	days := windDays(20, 90, 90, 90, 90)
+	for i, speed := range []float64{29, 30, 31, 45} {
+		days[i].WindSpeedMax = speed
+	}
+
+	if got := buildWindSpeedAnalysis(days, 0); got != "" {
+		t.Errorf("disabled analysis = %q, want none", got)
+	}
+	if got, want := buildWindSpeedAnalysis(days, 30), "High wind: 2 days (wind > 30 km/h)\n"; got != want {
+		t.Errorf("analysis = %q, want %q", got, want)
+	}
+
+	opts := newTestAgent(t, Config{WindSpeedAlert: 30}).windOptions()
+	table := buildForecastTable(days, opts)
+	for i, want := range []bool{false, false, true, true} {
+		if got := strings.Contains(table.Rows[i][1], opts.markers.HighWind); got != want {
+			t.Errorf("row %d wind cell %q: marked %v, want %v", i, table.Rows[i][1], got, want)
+		}
+	}
+}
+
+func TestSlowSummarySendsWithout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Sleep past the timeout, as a model still loading would
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	rec := &recordingNotifier{}
+	a := newTestAgent(t, Config{
+		Checks:        []Check{{Name: "Heathrow", Type: CheckWind, Weather: &fakeWeather{wind: windDays(20, 90, 90, 270)}}},
+		Ollama:        &ollama.Client{Host: srv.URL},
+		OllamaTimeout: 50 * time.Millisecond,
+		Notifiers:     []notify.Notifier{rec},
+		RunOnce:       true,
+	})
+
+	start := time.Now()
+	if err := a.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("run took %s, want the summary abandoned after the timeout", elapsed)
+	}
+	sent := rec.sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(sent))
+	}
+	if !strings.Contains(sent[0], "Dominant: E") || !strings.Contains(sent[0], "| Wind |") {
+		t.Errorf("message lacks the analysis and table:\n%s", sent[0])
+	}
+}
+
+func TestDominantMargin(t *testing.T) {
+	const east, west = 90, 270
+	tests := []struct {
+		name   string
+		margin int
+		dirs   []float64
+		want   string
+	}{
+		{"tie", 0, []float64{east, west, east, west}, "Dominant: Mixed |"},
+		{"tie with a margin", 2, []float64{east, west, east, west}, "Dominant: Mixed / variable |"},
+		{"one apart without a margin", 0, []float64{east, east, west}, "Dominant: E ✈️ |"},
+		{"within the margin", 1, []float64{east, east, west}, "Dominant: Mixed / variable |"},
+		{"at the margin", 2, []float64{west, west, west, east}, "Dominant: Mixed / variable |"},
+		{"past the margin", 2, []float64{west, west, west, west, east}, "Dominant: W |"},
+		{"clear majority", 1, []float64{east, east, east, east, west}, "Dominant: E ✈️ |"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := newTestAgent(t, Config{DominantMargin: tt.margin}).windOptions()
+			if got := buildEasterlyAnalysis(windDays(20, tt.dirs...), opts); !strings.Contains(got, tt.want) {
+				t.Errorf("analysis =\n%s\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompass16AllSectors(t *testing.T) {
+	want := []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+	for i, name := range want {
+		center := float64(i) * 22.5
+		// Each sector spans 11.25° either side of its point
+		for _, deg := range []float64{center - 11.2, center, center + 11.2, center + 360, center - 360} {
+			if got := compass16(deg); got != name {
+				t.Errorf("compass16(%g) = %s, want %s", deg, got, name)
+			}
+		}
+	}
+	for _, tt := range []struct {
+		deg  float64
+		want string
+	}{{11.24, "N"}, {11.26, "NNE"}, {348.74, "NNW"}, {348.76, "N"}, {-0.1, "N"}, {720, "N"}} {
+		if got := compass16(tt.deg); got != tt.want {
+			t.Errorf("compass16(%g) = %s, want %s", tt.deg, got, tt.want)
+		}
+	}
+}
+
+func TestCompass8AllSectors(t *testing.T) {
+	want := []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+	for i, name := range want {
+		center := float64(i) * 45
+		for _, deg := range []float64{center - 22.4, center, center + 22.4} {
+			if got := compass8(deg); got != name {
+				t.Errorf("compass8(%g) = %s, want %s", deg, got, name)
+			}
+		}
+	}
+}
+
+func TestCompassResolutionKeepsFlightPathCall(t *testing.T) {
+	days := windDays(20, 5, 175, 185, 355)
+	for _, res := range []int{2, 8, 16} {
+		opts := newTestAgent(t, Config{CompassResolution: res}).windOptions()
+		if got := countEasterlyDays(days, opts.hysteresis); got != 2 {
+			t.Errorf("resolution %d: %d easterly days, want 2", res, got)
+		}
+		table := buildForecastTable(days, opts)
+		for i, want := range map[int][]string{
+			2:  {"E", "E", "W", "W"},
+			8:  {"N", "S", "S", "N"},
+			16: {"N", "S", "S", "N"},
+		}[res] {
+			if got := strings.TrimSpace(table.Rows[i][3]); got != want {
+				t.Errorf("resolution %d, row %d: Dir %q, want %q", res, i, got, want)
+			}
+		}
+	}
+}
+
+func TestArrow(t *testing.T) {
+	tests := []struct {
+		deg      float64
+		from, to string
+	}{
+		{0, "↑", "↓"},
+		{45, "↗", "↙"},
+		{90, "→", "←"}, // an easterly blows west
+		{135, "↘", "↖"},
+		{180, "↓", "↑"},
+		{225, "↙", "↗"},
+		{270, "←", "→"},
+		{315, "↖", "↘"},
+		{22.4, "↑", "↓"},
+		{22.6, "↗", "↙"},
+		{359, "↑", "↓"},
+		{-90, "←", "→"},
+		{450, "→", "←"},
+	}
+	for _, tt := range tests {
+		if got := arrow(tt.deg, false); got != tt.from {
+			t.Errorf("arrow(%g, from) = %s, want %s", tt.deg, got, tt.from)
+		}
+		if got := arrow(tt.deg, true); got != tt.to {
+			t.Errorf("arrow(%g, to) = %s, want %s", tt.deg, got, tt.to)
+		}
+	}
+}
+
+func TestDirectionStyle(t *testing.T) {
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{"", "E"},
+		{DirectionLetters, "E"},
+		{DirectionArrowsFrom, "→"},
+		{DirectionArrowsTo, "←"},
+	}
+	for _, tt := range tests {
+		opts := newTestAgent(t, Config{DirectionStyle: tt.style}).windOptions()
+		if got := direction(90, true, opts); got != tt.want {
+			t.Errorf("style %q: direction(90) = %s, want %s", tt.style, got, tt.want)
+		}
+	}
+}
+
+func TestRunReturnsOnCancel(t *testing.T) {
+	// The scheduler, weekly summary and quiet hours loops all run
+	a := newTestAgent(t, Config{
+		Checks:            []Check{{Name: "Heathrow", Type: CheckWind, Weather: &fakeWeather{wind: windDays(20, 90)}, Hour: 8}},
+		Notifiers:         []notify.Notifier{&recordingNotifier{}},
+		QuietStart:        TimeOfDay{22, 0},
+		QuietEnd:          TimeOfDay{7, 0},
+		WeeklySummaryCron: "0 18 * * 0",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- a.Run(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// changeProbPoints is how far a day's rain probability must move between runs
+// to be called out (see Config.ShowForecastChanges).
+const changeProbPoints = 30
+
+// maxChanges caps the changes listed at the top of a message.
+const maxChanges = 5
+
+// forecastChanges compares cur with check i's previous report in the state
+// file and saves cur as the next baseline. It returns the lines calling out
+// the material changes, or "" on the first run or when nothing changed.
+func (a *Agent) forecastChanges(i int, cur savedReport) string {
+	chk := a.cfg.Checks[i]
+	prev, ok := a.state.report(chk.stateKey())
+	if err := a.state.putReport(chk.stateKey(), cur); err != nil {
+		a.log.Warn("save report for forecast changes failed", "location", chk.Name, "err", err)
+	}
+	if !ok {
+		return ""
+	}
+
+	var changes []string
+	switch {
+	case cur.Wind != nil && prev.Wind != nil:
+		changes = windChanges(*prev.Wind, *cur.Wind)
+	case cur.Rain != nil && prev.Rain != nil:
+		changes = rainChanges(*prev.Rain, *cur.Rain)
+	}
+	a.log.Debug("forecast changes", "location", chk.Name, "since", prev.At.Format(time.RFC3339), "changes", len(changes))
+	return buildChanges(changes, since(prev.At, cur.At, a.checkTimezone(chk)))
+}
+
+// windChanges lists the upcoming days, up to the long-range outlook, that
+// flipped between easterly and westerly, or into or out of gusts.
+func windChanges(prev, cur WindReport) []string {
+	before := make(map[string]WindDay, len(prev.Days))
+	for _, d := range prev.Days {
+		before[d.Date.Format(time.DateOnly)] = d
+	}
+	var out []string
+	for _, d := range cur.Days {
+		p, ok := before[d.Date.Format(time.DateOnly)]
+		if !ok || d.Past || d.LongRange {
+			continue
+		}
+		day := d.Date.Format("Mon 02 Jan")
+		switch {
+		case d.Easterly && !p.Easterly:
+			out = append(out, day+" flipped to easterly")
+		case !d.Easterly && p.Easterly:
+			out = append(out, day+" flipped to westerly")
+		}
+		switch {
+		case d.Gusty && !p.Gusty:
+			out = append(out, fmt.Sprintf("%s now gusty (%.0f km/h)", day, d.WindGust))
+		case !d.Gusty && p.Gusty:
+			out = append(out, day+" no longer gusty")
+		}
+	}
+	return out
+}
+
+// rainChanges lists the days whose school-run umbrella verdict changed, or
+// otherwise whose rain probability moved by changeProbPoints or more.
+func rainChanges(prev, cur RainReport) []string {
+	before := make(map[string]RainDay, len(prev.Days))
+	for _, d := range prev.Days {
+		before[d.Date.Format(time.DateOnly)] = d
+	}
+	var out []string
+	for _, d := range cur.Days {
+		p, ok := before[d.Date.Format(time.DateOnly)]
+		if !ok {
+			continue
+		}
+		day := d.Date.Format("Mon 02 Jan")
+		var verdicts []string
+		for _, w := range d.Windows {
+			j := slices.IndexFunc(p.Windows, func(pw RainWindow) bool { return pw.Name == w.Name && pw.Pickup == w.Pickup })
+			if j < 0 || p.Windows[j].Umbrella == w.Umbrella {
+				continue
+			}
+			name := "drop-off"
+			if w.Pickup {
+				name = "pickup"
+			}
+			if w.Name != "" {
+				name += " (" + w.Name + ")"
+			}
+			verdicts = append(verdicts, fmt.Sprintf("%s umbrella now %s (was %s)", name, w.Umbrella, p.Windows[j].Umbrella))
+		}
+		switch delta := d.PrecipProb - p.PrecipProb; {
+		case len(verdicts) > 0:
+			out = append(out, day+" "+strings.Join(verdicts, ", "))
+		case delta >= changeProbPoints:
+			out = append(out, fmt.Sprintf("%s rain chance up from %d%% to %d%%", day, p.PrecipProb, d.PrecipProb))
+		case delta <= -changeProbPoints:
+			out = append(out, fmt.Sprintf("%s rain chance down from %d%% to %d%%", day, p.PrecipProb, d.PrecipProb))
+		}
+	}
+	return out
+}
+
+// buildChanges formats up to maxChanges changes, one per line, followed by a
+// blank line; "" without any.
+func buildChanges(changes []string, since string) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, c := range changes[:min(len(changes), maxChanges)] {
+		fmt.Fprintf(&b, "⚠️ %s %s.\n", c, since)
+	}
+	if n := len(changes) - maxChanges; n > 0 {
+		fmt.Fprintf(&b, "⚠️ …and %d more.\n", n)
+	}
+	return b.String() + "\n"
+}
+
+// since describes when the previous report was made, relative to now.
+func since(prev, now time.Time, loc *time.Location) string {
+	prev, now = prev.In(loc), now.In(loc)
+	switch prev.Format(time.DateOnly) {
+	case now.Format(time.DateOnly):
+		return "since earlier today"
+	case now.AddDate(0, 0, -1).Format(time.DateOnly):
+		return "since yesterday"
+	default:
+		return "since " + prev.Format("Mon 02 Jan")
+	}
+}
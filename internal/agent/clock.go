@@ -0,0 +1,19 @@
+package agent
+
+import "time"
+
+// Clock is the agent's source of time for scheduling: when checks and the
+// weekly summary next run, and the day the digest collects for. Tests can
+// substitute a fake to step through schedules deterministically.
+type Clock interface {
+	Now() time.Time
+	// After waits for d to elapse and then sends the current time, like
+	// time.After.
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the wall clock.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that stands still until waited on: After moves it
+// forward by the wait, plus late, and fires at once. Past limit calls (0 for
+// no limit) After instead calls stop and never fires, so a test can end a
+// loop that waits on it.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	late  time.Duration
+	calls int
+	limit int
+	stop  func()
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if c.limit > 0 && c.calls > c.limit {
+		c.stop()
+		return nil
+	}
+	c.now = c.now.Add(max(d, 0) + c.late)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestSchedulerNextRunFollowsClock(t *testing.T) {
+	london := mustLoadLocation(t, "Europe/London")
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{
+			name: "before the hour runs today",
+			now:  time.Date(2026, 6, 10, 7, 59, 0, 0, london),
+			want: time.Date(2026, 6, 10, 8, 0, 0, 0, london),
+		},
+		{
+			name: "at the hour rolls to tomorrow",
+			now:  time.Date(2026, 6, 10, 8, 0, 0, 0, london),
+			want: time.Date(2026, 6, 11, 8, 0, 0, 0, london),
+		},
+		{
+			name: "past the hour rolls to tomorrow",
+			now:  time.Date(2026, 6, 10, 8, 1, 0, 0, london),
+			want: time.Date(2026, 6, 11, 8, 0, 0, 0, london),
+		},
+		{
+			name: "past the hour on the last day of the month",
+			now:  time.Date(2026, 6, 30, 21, 0, 0, 0, london),
+			want: time.Date(2026, 7, 1, 8, 0, 0, 0, london),
+		},
+		{
+			name: "into BST keeps 08:00 local",
+			now:  time.Date(2026, 3, 28, 9, 0, 0, 0, london),
+			want: time.Date(2026, 3, 29, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "into GMT keeps 08:00 local",
+			now:  time.Date(2026, 10, 24, 9, 0, 0, 0, london),
+			want: time.Date(2026, 10, 25, 8, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(Config{
+				Checks:               []Check{{Name: "Twickenham", Type: CheckRain, Weather: &fakeWeather{}, Hour: 8, Timezone: "Europe/London"}},
+				Clock:                &fakeClock{now: tt.now},
+				DisableNotifications: true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := a.newScheduler(); err != nil {
+				t.Fatal(err)
+			}
+			if got := a.Status()[0].NextRun; !got.Equal(tt.want) {
+				t.Errorf("next run = %s, want %s", got, tt.want.In(london))
+			}
+		})
+	}
+}
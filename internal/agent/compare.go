@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/notify"
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+// compareDays is how many days, from today, CompareWind compares.
+const compareDays = 7
+
+// CompareWind fetches the coming week's wind for the wind check named check
+// and for other, which needs only Name and Weather, and returns a message
+// with their easterly days side by side and which spot has the better odds
+// of planes overhead. When one fetch fails the other is shown alone, noting
+// the failure; it is an error only when both fail. Nothing is sent to
+// notifiers.
+func (a *Agent) CompareWind(ctx context.Context, check string, other Check) (notify.Message, error) {
+	i, err := a.checkIndex(check, CheckWind)
+	if err != nil {
+		return notify.Message{}, err
+	}
+	spots := []Check{a.cfg.Checks[i], other}
+
+	opts := a.windOptions()
+	forecasts := make([][]weather.ForecastDay, len(spots))
+	var errs []error
+	for j, chk := range spots {
+		days, err := limitedFetch(ctx, a, chk.Weather.Fetch, compareDays)
+		if err != nil {
+			a.log.Warn("compare: fetch forecast failed", "location", chk.Name, "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", chk.Name, err))
+			continue
+		}
+		upcoming := upcomingDays(days)
+		forecasts[j] = upcoming[:min(len(upcoming), compareDays)]
+	}
+	if len(errs) == len(spots) {
+		return notify.Message{}, errors.Join(errs...)
+	}
+
+	msg := notify.Text(fmt.Sprintf("🛫 %s vs %s\n", spots[0].Name, spots[1].Name))
+	msg = msg.Text(compareVerdict(spots, forecasts, opts) + "\n")
+	return msg.Table(buildCompareTable(spots, forecasts, opts)), nil
+}
+
+// compareVerdict counts each spot's easterly days and names the one with
+// more, or notes the spot whose forecast is missing.
+func compareVerdict(spots []Check, forecasts [][]weather.ForecastDay, opts windOptions) string {
+	for j, days := range forecasts {
+		if days == nil {
+			k := 1 - j
+			return fmt.Sprintf("%s: %s | ⚠️ %s wind data unavailable",
+				spots[k].Name, countDays(countEasterlyDays(forecasts[k], opts.hysteresis), "easterly day"), spots[j].Name)
+		}
+	}
+
+	a, b := countEasterlyDays(forecasts[0], opts.hysteresis), countEasterlyDays(forecasts[1], opts.hysteresis)
+	counts := fmt.Sprintf("%s: %s vs %s: %d", spots[0].Name, countDays(a, "easterly day"), spots[1].Name, b)
+	switch {
+	case a > b:
+		return counts + "\n" + opts.markers.Easterly + " Better odds: " + spots[0].Name
+	case b > a:
+		return counts + "\n" + opts.markers.Easterly + " Better odds: " + spots[1].Name
+	default:
+		return counts + "\nLevel: no spot has the better odds"
+	}
+}
+
+// buildCompareTable renders each day's direction per spot, with the wind
+// table's easterly and go-around markers. Dates follow the first spot with a
+// forecast; a spot without one gets no column.
+func buildCompareTable(spots []Check, forecasts [][]weather.ForecastDay, opts windOptions) notify.Table {
+	var dates []time.Time
+	for _, days := range forecasts {
+		if days != nil {
+			for _, d := range days {
+				dates = append(dates, d.Date)
+			}
+			break
+		}
+	}
+
+	layout := dateLayout(len(dates), opts.compact)
+	t := notify.Table{Header: []string{dateHeader(layout)}}
+	cells := make(map[string][]string, len(dates))
+	for j, days := range forecasts {
+		if days == nil {
+			continue
+		}
+		t.Header = append(t.Header, " "+spots[j].Name)
+		byDate := make(map[string]string, len(days))
+		for k, east := range easterlyDays(days, opts.hysteresis) {
+			marker := ""
+			switch classifyDay(east, isGusty(days[k], opts.gust)) {
+			case OverheadSteady:
+				marker = " " + opts.markers.Easterly
+			case OverheadGusty:
+				marker = " " + opts.markers.GoAround
+			}
+			byDate[days[k].Date.Format(time.DateOnly)] = " " + compass(east) + marker
+		}
+		for _, d := range dates {
+			cell, ok := byDate[d.Format(time.DateOnly)]
+			if !ok {
+				cell = " --"
+			}
+			cells[d.Format(time.DateOnly)] = append(cells[d.Format(time.DateOnly)], cell)
+		}
+	}
+	for _, d := range dates {
+		t.Rows = append(t.Rows, append([]string{d.Format(layout) + " "}, cells[d.Format(time.DateOnly)]...))
+	}
+	// Space out every spot column but the last, which ends the line
+	for _, row := range append([][]string{t.Header}, t.Rows...) {
+		for c := 1; c < len(row)-1; c++ {
+			row[c] += " "
+		}
+	}
+	if opts.compact {
+		t = t.Fit(opts.width)
+	}
+	return t
+}
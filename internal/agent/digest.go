@@ -0,0 +1,257 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/notify"
+)
+
+// digest collects the day's per-check sections so they can be sent as a
+// single daily message once no more checks are due to report.
+type digest struct {
+	mu       sync.Mutex
+	day      time.Time       // when the first of sections was added
+	sections map[int]section // by check index
+}
+
+// section is a check's message, kept in parts so the digest can merge the
+// checks' tables, and the data message templates render it from. data is
+// nil when the fetch failed.
+type section struct {
+	text    string       // notes and analysis, shown above the table
+	table   notify.Table // one row per date in dates
+	dates   []time.Time
+	summary string
+	data    *MessageData
+}
+
+// message is the section as sent on its own; empty when the fetch failed.
+func (s section) message() notify.Message {
+	if s.data == nil {
+		return notify.Message{}
+	}
+	msg := notify.Text(s.text).Table(s.table)
+	if s.summary != "" {
+		msg = msg.Text("\n" + s.summary)
+	}
+	return msg
+}
+
+// dailyDigest is one day's sections, ready to send.
+type dailyDigest struct {
+	day      time.Time
+	sections map[int]section
+}
+
+// add records check i's section for the day of now and returns the digests
+// ready to send: a previous day's sections, which some check never
+// completed, and this day's once complete reports no more are due.
+func (d *digest) add(i int, s section, now time.Time, complete func(map[int]section) bool) []dailyDigest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var ready []dailyDigest
+	if d.sections != nil && !sameDay(d.day, now.In(d.day.Location())) {
+		ready = append(ready, dailyDigest{d.day, d.sections})
+		d.sections = nil
+	}
+	if d.sections == nil {
+		d.day = now
+		d.sections = make(map[int]section)
+	}
+	d.sections[i] = s
+
+	if complete(d.sections) {
+		ready = append(ready, dailyDigest{d.day, d.sections})
+		d.sections = nil
+	}
+	return ready
+}
+
+// digestComplete reports whether every check missing from sections is done
+// for the day of now: not running and with no run queued later that day.
+// With RunOnce every check reports, so it waits for all of them.
+func (a *Agent) digestComplete(sections map[int]section, now time.Time) bool {
+	for j, st := range a.Status() {
+		if _, ok := sections[j]; ok {
+			continue
+		}
+		if a.cfg.RunOnce || st.Running || (!st.NextRun.IsZero() && sameDay(now, st.NextRun.In(now.Location()))) {
+			return false
+		}
+	}
+	return true
+}
+
+// deliver sends check i's section, or in digest mode holds it until no more
+// checks are due to report that day. A section without data marks the
+// check's data as unavailable. Notifiers with a message template get it
+// rendered from data instead.
+func (a *Agent) deliver(ctx context.Context, i int, s section) error {
+	msg := s.message()
+	if !a.cfg.DigestMode {
+		if msg.IsEmpty() {
+			return nil
+		}
+		chk := a.cfg.Checks[i]
+		msg = notify.Text(fmt.Sprintf("%s %s\n", chk.icon(), chk.Name)).Append(msg)
+		if s.data != nil {
+			s.data.Message = msg.Render(notify.ASCIITable{})
+		}
+		return a.notifyEach(ctx, func(n int) notify.Message { return a.messageFor(n, msg, s.data) })
+	}
+
+	if s.data != nil {
+		s.data.Message = msg.Render(notify.ASCIITable{})
+	}
+	now := a.cfg.Clock.Now()
+	ready := a.digest.add(i, s, now, func(sections map[int]section) bool {
+		return a.digestComplete(sections, now)
+	})
+	var errs []error
+	for _, d := range ready {
+		if err := a.notifyEach(ctx, func(n int) notify.Message { return a.formatDigest(d.day, d.sections, n) }); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// formatDigest combines the sections, in check order, under a single date
+// header, as notifier n receives it. The sections' tables are merged into
+// one, after their notes, so each date is listed once; sections a template
+// renders keep their own layout. Checks that didn't run are left out, and
+// the message carries the sections' data, in the same order, leaving out
+// checks whose fetch failed.
+func (a *Agent) formatDigest(day time.Time, sections map[int]section, n int) notify.Message {
+	msg := notify.Text(fmt.Sprintf("📋 Daily digest – %s\n", day.Format("Mon 02 Jan")))
+	templated := n < len(a.msgTemplates) && a.msgTemplates[n] != nil
+
+	var data []*MessageData
+	var tables []section
+	for i, chk := range a.cfg.Checks {
+		s, ok := sections[i]
+		if !ok {
+			continue
+		}
+		msg = msg.Text(fmt.Sprintf("\n%s %s %s\n", chk.icon(), chk.Name, chk.Type))
+		switch {
+		case s.data == nil:
+			msg = msg.Text(fmt.Sprintf("⚠️ %s data unavailable\n", chk.Type))
+			continue
+		case templated:
+			msg = msg.Append(a.messageFor(n, s.message(), s.data).TrimRight()).Text("\n")
+		default:
+			text := strings.TrimRight(s.text, "\n")
+			if s.summary != "" {
+				text += "\n" + s.summary
+			}
+			msg = msg.Text(strings.TrimRight(text, "\n") + "\n")
+			tables = append(tables, s)
+		}
+		data = append(data, s.data)
+	}
+	if len(tables) > 0 {
+		msg = msg.Text("\n").Table(a.mergeTables(tables))
+	}
+
+	return msg.TrimRight().WithData(data)
+}
+
+// mergeTables joins the sections' tables side by side on their dates, under
+// one Date column; a section without a date leaves its cells blank.
+func (a *Agent) mergeTables(sections []section) notify.Table {
+	var dates []time.Time
+	seen := make(map[string]bool)
+	for _, s := range sections {
+		for _, d := range s.dates {
+			if key := d.Format(time.DateOnly); !seen[key] {
+				seen[key] = true
+				dates = append(dates, d)
+			}
+		}
+	}
+	slices.SortFunc(dates, func(x, y time.Time) int { return x.Compare(y) })
+
+	layout := dateLayout(len(dates), a.cfg.CompactTable)
+	t := notify.Table{Header: []string{dateHeader(layout)}}
+	cells := make(map[string][]string, len(dates))
+	markers := make(map[string]string, len(dates))
+	for k, s := range sections {
+		if len(s.table.Header) == 0 {
+			continue
+		}
+		header := slices.Clone(s.table.Header[1:])
+		byDate := make(map[string][]string, len(s.dates))
+		for r, d := range s.dates {
+			if r >= len(s.table.Rows) || len(s.table.Rows[r]) == 0 {
+				continue
+			}
+			row := s.table.Rows[r]
+			key := d.Format(time.DateOnly)
+			byDate[key] = slices.Clone(row[1:])
+			// The date cell ends in a one-character marker, e.g. * for past days
+			if m := row[0][len(row[0])-1:]; m != " " && markers[key] == "" {
+				markers[key] = m
+			}
+		}
+		for _, d := range dates {
+			key := d.Format(time.DateOnly)
+			row := byDate[key]
+			for len(row) < len(header) {
+				row = append(row, "")
+			}
+			row = row[:len(header)]
+			// Space out every table but the last, whose last column ends the line
+			if k < len(sections)-1 && len(row) > 0 {
+				row[len(row)-1] += " "
+			}
+			cells[key] = append(cells[key], row...)
+		}
+		if k < len(sections)-1 && len(header) > 0 {
+			header[len(header)-1] += " "
+		}
+		t.Header = append(t.Header, header...)
+		for _, f := range s.table.Footnotes {
+			if !slices.Contains(t.Footnotes, f) {
+				t.Footnotes = append(t.Footnotes, f)
+			}
+		}
+	}
+	for _, d := range dates {
+		key := d.Format(time.DateOnly)
+		marker := markers[key]
+		if marker == "" {
+			marker = " "
+		}
+		t.Rows = append(t.Rows, append([]string{d.Format(layout) + marker}, cells[key]...))
+	}
+	if a.cfg.CompactTable {
+		t = t.Fit(a.cfg.TableWidth)
+	}
+	return t
+}
+
+// messageFor is notifier n's message: its template rendered from data, or
+// def without a template or data, or when rendering fails. It carries data
+// for backends that build their own payload.
+func (a *Agent) messageFor(n int, def notify.Message, data *MessageData) notify.Message {
+	if data == nil {
+		return def
+	}
+	if n >= len(a.msgTemplates) || a.msgTemplates[n] == nil {
+		return def.WithData(data)
+	}
+	var b strings.Builder
+	if err := a.msgTemplates[n].Execute(&b, data); err != nil {
+		a.log.Error("render message template failed, sending the default message", "location", data.Location, "err", err)
+		return def.WithData(data)
+	}
+	return notify.Text(b.String()).WithData(data)
+}
@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+// dryMorning finds the first school morning within the next week, today
+// included, whose drop-off windows all stay below the "maybe umbrella"
+// threshold. It returns the day and its highest drop-off probability, and
+// false when every morning is wet.
+func dryMorning(days []weather.RainForecast, opts rainOptions) (weather.RainForecast, int, bool) {
+	for _, d := range days[:min(len(days), weeklyDays)] {
+		sd, ok := opts.schedule[d.Date.Weekday()]
+		if !ok || inRanges(opts.holidays, d.Date) {
+			continue
+		}
+		prob, found := 0, false
+		for _, w := range sd.windows() {
+			if !w.Pickup {
+				prob, found = max(prob, windowProb(d, w.HourWindow, opts)), true
+			}
+		}
+		if found && prob < opts.maybe {
+			return d, prob, true
+		}
+	}
+	return weather.RainForecast{}, 0, false
+}
+
+// dryMorningLine is the rain message's line for dryMorning, e.g.
+// "🚲 Driest school morning: Thursday, 15%".
+func dryMorningLine(days []weather.RainForecast, opts rainOptions) string {
+	d, prob, ok := dryMorning(days, opts)
+	if !ok {
+		return opts.markers.Cycle + " No dry school mornings this week\n"
+	}
+	return fmt.Sprintf("%s Driest school morning: %s, %d%%\n", opts.markers.Cycle, d.Date.Format("Monday"), prob)
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"context"
+	"slices"
+
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+// defaultFetchConcurrency is Config.FetchConcurrency's default.
+const defaultFetchConcurrency = 2
+
+// acquireFetch waits for one of the Config.FetchConcurrency fetch slots and
+// returns the function that frees it, or ctx's error if it is cancelled
+// while waiting.
+func (a *Agent) acquireFetch(ctx context.Context) (release func(), err error) {
+	select {
+	case a.fetchSlots <- struct{}{}:
+		return func() { <-a.fetchSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// limitedFetch calls fetch(ctx, days) in a fetch slot.
+func limitedFetch[T any](ctx context.Context, a *Agent, fetch func(context.Context, int) ([]T, error), days int) ([]T, error) {
+	release, err := a.acquireFetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return fetch(ctx, days)
+}
+
+// withTodayHourly replaces today's hourly detail in forecast with the full day
+// from chk's source (see Config.RainTodayHourly). forecast is returned as is
+// when the source has no such fetch or it fails.
+func (a *Agent) withTodayHourly(ctx context.Context, chk Check, forecast []weather.RainForecast) []weather.RainForecast {
+	f, ok := chk.Weather.(weather.TodayHourlyFetcher)
+	if !ok || len(forecast) == 0 {
+		return forecast
+	}
+	release, err := a.acquireFetch(ctx)
+	if err != nil {
+		return forecast
+	}
+	hours, err := f.FetchTodayHourly(ctx)
+	release()
+	if err != nil {
+		a.log.Warn("fetch today's hourly rain failed, using the daily forecast's", "location", chk.Name, "err", err)
+		return forecast
+	}
+	// forecast is shared with lastGood
+	out := slices.Clone(forecast)
+	out[0] = out[0].WithHourly(hours)
+	return out
+}
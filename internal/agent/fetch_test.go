@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// inFlight counts concurrent fetches and the most seen at once.
+type inFlight struct {
+	mu       sync.Mutex
+	now, max int
+}
+
+// fetch holds a slot for d, or until ctx is done.
+func (f *inFlight) fetch(ctx context.Context, d time.Duration) {
+	f.mu.Lock()
+	f.now++
+	f.max = max(f.max, f.now)
+	f.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+
+	f.mu.Lock()
+	f.now--
+	f.mu.Unlock()
+}
+
+// checks returns n wind checks whose fetches run through f for d.
+func (f *inFlight) checks(n int, d time.Duration) []Check {
+	checks := make([]Check, n)
+	for i := range checks {
+		src := &fakeWeather{wind: windDays(20, 90, 270), fetch: func(ctx context.Context) { f.fetch(ctx, d) }}
+		checks[i] = Check{Name: fmt.Sprintf("Spot %d", i), Type: CheckWind, Weather: src}
+	}
+	return checks
+}
+
+func TestFetchConcurrency(t *testing.T) {
+	for _, limit := range []int{1, 2, 4} {
+		t.Run(fmt.Sprintf("limit %d", limit), func(t *testing.T) {
+			var f inFlight
+			a := newTestAgent(t, Config{Checks: f.checks(6, 20*time.Millisecond), FetchConcurrency: limit, RunOnce: true})
+			if err := a.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if f.max != limit {
+				t.Errorf("%d fetches in flight at once, want %d", f.max, limit)
+			}
+		})
+	}
+}
+
+func TestFetchConcurrencyCancel(t *testing.T) {
+	var f inFlight
+	a := newTestAgent(t, Config{Checks: f.checks(3, time.Minute), FetchConcurrency: 1, RunOnce: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := a.Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	// The checks waiting for the slot give up too, rather than fetch in turn
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("run took %s after the cancel", elapsed)
+	}
+	if f.max != 1 {
+		t.Errorf("%d fetches in flight at once, want 1", f.max)
+	}
+}
@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite" // pure Go, so the binary still builds without cgo
+
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+// historySchema keeps one row per fetch, target day and location, so the
+// forecast for a given day can be followed as it approaches (forecast drift).
+// Times are stored as text: fetched_at in RFC 3339 UTC, target_date as
+// YYYY-MM-DD in the location's timezone.
+const historySchema = `
+CREATE TABLE IF NOT EXISTS wind_forecasts (
+	fetched_at     TEXT NOT NULL,
+	target_date    TEXT NOT NULL,
+	location       TEXT NOT NULL,
+	wind_speed_max REAL NOT NULL,
+	wind_gust_max  REAL NOT NULL,
+	wind_dir_mean  REAL NOT NULL,
+	long_range     INTEGER NOT NULL,
+	past           INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS wind_forecasts_key ON wind_forecasts (fetched_at, target_date, location);
+
+CREATE TABLE IF NOT EXISTS rain_forecasts (
+	fetched_at  TEXT NOT NULL,
+	target_date TEXT NOT NULL,
+	location    TEXT NOT NULL,
+	precip_prob INTEGER NOT NULL,
+	precip_mm   REAL,
+	snowfall_cm REAL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS rain_forecasts_key ON rain_forecasts (fetched_at, target_date, location);
+`
+
+const upsertWind = `
+INSERT INTO wind_forecasts (fetched_at, target_date, location, wind_speed_max, wind_gust_max, wind_dir_mean, long_range, past)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (fetched_at, target_date, location) DO UPDATE SET
+	wind_speed_max = excluded.wind_speed_max,
+	wind_gust_max  = excluded.wind_gust_max,
+	wind_dir_mean  = excluded.wind_dir_mean,
+	long_range     = excluded.long_range,
+	past           = excluded.past`
+
+const upsertRain = `
+INSERT INTO rain_forecasts (fetched_at, target_date, location, precip_prob, precip_mm, snowfall_cm)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (fetched_at, target_date, location) DO UPDATE SET
+	precip_prob = excluded.precip_prob,
+	precip_mm   = excluded.precip_mm,
+	snowfall_cm = excluded.snowfall_cm`
+
+// forecastHistory records every fetched forecast in SQLite. A nil
+// *forecastHistory (no database configured) records nothing.
+type forecastHistory struct {
+	db *sql.DB
+}
+
+// openHistory opens (creating if needed) the database at path.
+func openHistory(path string) (*forecastHistory, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open history database: %w", err)
+	}
+	// One writer at a time; SQLite would otherwise report "database is locked"
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(historySchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("create history schema in %s: %w", path, err)
+	}
+	return &forecastHistory{db: db}, nil
+}
+
+func (h *forecastHistory) close() error {
+	if h == nil {
+		return nil
+	}
+	return h.db.Close()
+}
+
+// recordWind upserts one row per day, all in one transaction.
+func (h *forecastHistory) recordWind(ctx context.Context, location string, fetchedAt time.Time, days []weather.ForecastDay) error {
+	if h == nil {
+		return nil
+	}
+	return h.upsert(ctx, upsertWind, len(days), func(i int) []any {
+		d := days[i]
+		return []any{historyTime(fetchedAt), d.Date.Format("2006-01-02"), location,
+			d.WindSpeedMax, d.WindGustMax, d.WindDirMean, d.LongRange, d.Past}
+	})
+}
+
+// recordRain upserts one row per day, all in one transaction. Amounts the
+// API omitted are stored as NULL.
+func (h *forecastHistory) recordRain(ctx context.Context, location string, fetchedAt time.Time, days []weather.RainForecast) error {
+	if h == nil {
+		return nil
+	}
+	return h.upsert(ctx, upsertRain, len(days), func(i int) []any {
+		d := days[i]
+		precip := sql.NullFloat64{Float64: d.PrecipMM, Valid: d.HasPrecipMM}
+		snow := sql.NullFloat64{Float64: d.SnowfallCM, Valid: d.HasSnowfall}
+		return []any{historyTime(fetchedAt), d.Date.Format("2006-01-02"), location,
+			d.PrecipProb, precip, snow}
+	})
+}
+
+// upsert runs query once per row with the arguments args(i) returns.
+func (h *forecastHistory) upsert(ctx context.Context, query string, rows int, args func(i int) []any) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("record history: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // no-op after Commit
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("record history: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for i := range rows {
+		if _, err := stmt.ExecContext(ctx, args(i)...); err != nil {
+			return fmt.Errorf("record history: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("record history: %w", err)
+	}
+	return nil
+}
+
+func historyTime(t time.Time) string {
+	return t.UTC().Truncate(time.Second).Format(time.RFC3339)
+}
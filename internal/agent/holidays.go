@@ -0,0 +1,215 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// holidayCalendarTTL is how long a fetched iCal feed is reused before refetching.
+const holidayCalendarTTL = 24 * time.Hour
+
+// DateRange is an inclusive range of calendar dates; times of day are ignored.
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (r DateRange) contains(d time.Time) bool {
+	day := dateOnly(d)
+	return !day.Before(dateOnly(r.Start)) && !day.After(dateOnly(r.End))
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func inRanges(ranges []DateRange, d time.Time) bool {
+	for _, r := range ranges {
+		if r.contains(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDateRanges parses a comma-separated list of "YYYY-MM-DD" dates or
+// "YYYY-MM-DD:YYYY-MM-DD" inclusive ranges.
+func ParseDateRanges(s string) ([]DateRange, error) {
+	var out []DateRange
+	for part := range strings.SplitSeq(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		startStr, endStr, isRange := strings.Cut(part, ":")
+		if !isRange {
+			endStr = startStr
+		}
+		start, err := time.Parse("2006-01-02", startStr)
+		if err != nil {
+			return nil, fmt.Errorf("parse date range %q: %w", part, err)
+		}
+		end, err := time.Parse("2006-01-02", endStr)
+		if err != nil {
+			return nil, fmt.Errorf("parse date range %q: %w", part, err)
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("date range %q ends before it starts", part)
+		}
+		out = append(out, DateRange{Start: start, End: end})
+	}
+	return out, nil
+}
+
+// holidayCalendar fetches and caches the date ranges of an iCal feed.
+type holidayCalendar struct {
+	url   string
+	clock Clock // times the cache (see Config.Clock)
+
+	mu      sync.Mutex
+	ranges  []DateRange
+	fetched time.Time
+}
+
+// get returns the cached ranges, refetching once they are older than
+// holidayCalendarTTL. If a refetch fails, the previous ranges are returned
+// along with the error.
+func (c *holidayCalendar) get(ctx context.Context) ([]DateRange, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	if !c.fetched.IsZero() && now.Sub(c.fetched) < holidayCalendarTTL {
+		return c.ranges, nil
+	}
+
+	ranges, err := fetchICalRanges(ctx, c.url)
+	if err != nil {
+		return c.ranges, err
+	}
+	c.ranges = ranges
+	c.fetched = now
+	return ranges, nil
+}
+
+func fetchICalRanges(ctx context.Context, url string) ([]DateRange, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build calendar request: %w", err)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch calendar: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calendar returned %s", resp.Status)
+	}
+
+	return parseICal(resp.Body)
+}
+
+// parseICal extracts the date span of each VEVENT. All-day DTEND values are
+// exclusive per RFC 5545, so the range ends the day before.
+func parseICal(r io.Reader) ([]DateRange, error) {
+	// Unfold continuation lines (RFC 5545 3.1)
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read calendar: %w", err)
+	}
+
+	var (
+		out                []DateRange
+		inEvent            bool
+		start, end         time.Time
+		endIsDate, hasDate bool
+	)
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		params := ""
+		if i := strings.Index(name, ";"); i >= 0 {
+			name, params = name[:i], name[i:]
+		}
+
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				inEvent, hasDate, endIsDate = true, false, false
+				start, end = time.Time{}, time.Time{}
+			}
+		case "DTSTART":
+			if inEvent {
+				if t, err := parseICalDate(value); err == nil {
+					start, hasDate = t, true
+				}
+			}
+		case "DTEND":
+			if inEvent {
+				if t, err := parseICalDate(value); err == nil {
+					end = t
+					endIsDate = strings.Contains(strings.ToUpper(params), "VALUE=DATE") || len(value) == 8
+				}
+			}
+		case "END":
+			if strings.EqualFold(value, "VEVENT") && inEvent {
+				inEvent = false
+				if !hasDate {
+					continue
+				}
+				rangeEnd := start
+				if !end.IsZero() {
+					rangeEnd = end
+					if endIsDate && end.After(start) {
+						rangeEnd = end.AddDate(0, 0, -1)
+					}
+				}
+				out = append(out, DateRange{Start: start, End: rangeEnd})
+			}
+		}
+	}
+	return out, nil
+}
+
+// parseICalDate reads the date part of a DATE or DATE-TIME value.
+func parseICalDate(v string) (time.Time, error) {
+	if len(v) < 8 {
+		return time.Time{}, fmt.Errorf("invalid iCal date %q", v)
+	}
+	return time.Parse("20060102", v[:8])
+}
+
+// schoolHolidays returns the static holiday ranges plus any from the iCal feed.
+func (a *Agent) schoolHolidays(ctx context.Context) []DateRange {
+	ranges := a.cfg.SchoolHolidays
+	if a.holidayCal == nil {
+		return ranges
+	}
+	fetched, err := a.holidayCal.get(ctx)
+	if err != nil {
+		a.log.Warn("school holiday calendar unavailable", "url", a.holidayCal.url, "err", err)
+	}
+	return append(append([]DateRange(nil), ranges...), fetched...)
+}
@@ -0,0 +1,23 @@
+package agent
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// NewLogger returns a logger writing to w. Format "json" selects the JSON
+// handler for log pipelines; anything else uses the human-friendly text
+// handler. Level is one of debug, info, warn or error (default info).
+func NewLogger(format, level string, w io.Writer) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	if strings.EqualFold(format, "json") {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markers are the symbols that flag days and windows in the rain and wind
+// tables and the school-run lines. Empty fields take the DefaultMarkers
+// emoji; ASCIIMarkers swaps them all for text, for backends that render
+// emoji poorly or for screen readers.
+type Markers struct {
+	Rain      string // umbrella needed: rain table cells and "Umbrella!"
+	MaybeRain string // "Maybe umbrella"
+	Dry       string // a school-run window below the maybe threshold
+	Snow      string // snow in rain table cells and "Snow!"
+	MaybeSnow string // "Maybe snow"
+	Cycle     string // the dry school morning (see Config.ShowDryMorning)
+
+	Easterly string // steady easterly day, planes overhead
+	GoAround string // gusty easterly day, go-arounds likely
+	Gusty    string // gusts above the threshold
+	HighWind string // wind speed above the alert
+
+	LowConfidence string // ensemble members disagree on the rain total
+}
+
+// DefaultMarkers returns the emoji marker set.
+func DefaultMarkers() Markers {
+	return Markers{
+		Rain:      "☔",
+		MaybeRain: "🌦️",
+		Dry:       "☀️",
+		Snow:      "❄️",
+		MaybeSnow: "🌨️",
+		Cycle:     "🚲",
+
+		Easterly: "✈️",
+		GoAround: "🔄",
+		Gusty:    "⚠️",
+		HighWind: "💨",
+
+		LowConfidence: "❓",
+	}
+}
+
+// ASCIIMarkers returns a plain-text marker set.
+func ASCIIMarkers() Markers {
+	return Markers{
+		Rain:      "[RAIN]",
+		MaybeRain: "[RAIN?]",
+		Dry:       "[DRY]",
+		Snow:      "[SNOW]",
+		MaybeSnow: "[SNOW?]",
+		Cycle:     "[BIKE]",
+
+		Easterly: "[E]",
+		GoAround: "[GA]",
+		Gusty:    "[G]",
+		HighWind: "[W]",
+
+		LowConfidence: "[?]",
+	}
+}
+
+// withDefaults fills m's empty markers from DefaultMarkers.
+func (m Markers) withDefaults() Markers {
+	d := DefaultMarkers()
+	for _, f := range m.fields() {
+		if *f.value == "" {
+			*f.value = *d.field(f.name)
+		}
+	}
+	return m
+}
+
+type markerField struct {
+	name  string
+	value *string
+}
+
+// fields lists m's markers by the names ParseMarkers accepts.
+func (m *Markers) fields() []markerField {
+	return []markerField{
+		{"rain", &m.Rain},
+		{"maybe-rain", &m.MaybeRain},
+		{"dry", &m.Dry},
+		{"snow", &m.Snow},
+		{"maybe-snow", &m.MaybeSnow},
+		{"cycle", &m.Cycle},
+		{"easterly", &m.Easterly},
+		{"go-around", &m.GoAround},
+		{"gusty", &m.Gusty},
+		{"high-wind", &m.HighWind},
+		{"low-confidence", &m.LowConfidence},
+	}
+}
+
+func (m *Markers) field(name string) *string {
+	for _, f := range m.fields() {
+		if f.name == name {
+			return f.value
+		}
+	}
+	return nil
+}
+
+// ParseMarkers parses a marker set: "emoji" (the default) or "ascii",
+// optionally followed by comma-separated name=value overrides, e.g.
+// "ascii,rain=[WET]" or just "dry=🌤️". Names are rain, maybe-rain, dry,
+// snow, maybe-snow, cycle, easterly, go-around, gusty, high-wind and
+// low-confidence.
+func ParseMarkers(s string) (Markers, error) {
+	m := DefaultMarkers()
+	for i, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			switch {
+			case i > 0:
+				return Markers{}, fmt.Errorf("marker override %q: want name=value", part)
+			case part == "emoji":
+			case part == "ascii":
+				m = ASCIIMarkers()
+			default:
+				return Markers{}, fmt.Errorf("unknown marker set %q (want \"emoji\" or \"ascii\")", part)
+			}
+			continue
+		}
+		f := m.field(strings.ToLower(strings.TrimSpace(name)))
+		if f == nil {
+			return Markers{}, fmt.Errorf("unknown marker %q", name)
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return Markers{}, fmt.Errorf("marker %q is empty", name)
+		}
+		*f = value
+	}
+	return m, nil
+}
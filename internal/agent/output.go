@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Values for Config.OutputFormat.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// RunOutput is the document a RunOnce run writes with OutputFormat "json":
+// every check's result, in check order.
+type RunOutput struct {
+	Checks []CheckOutput
+}
+
+// CheckOutput is one check's result in a RunOutput. Wind or Rain is set to
+// match Type, unless the check failed before its forecast was analysed, in
+// which case only Error is.
+type CheckOutput struct {
+	Name     string
+	Type     CheckType
+	Wind     *WindReport
+	Rain     *RainReport
+	Analysis string // the analysis lines heading the text message
+	Summary  string // the Ollama summary; empty when disabled or unavailable
+	Error    string // why the check failed; empty on success
+}
+
+// writeOutput writes the collected check results, with the errors runOnce
+// got for each check, as a single JSON document.
+func (a *Agent) writeOutput(errs []error) error {
+	out := RunOutput{Checks: make([]CheckOutput, len(a.cfg.Checks))}
+	for i, chk := range a.cfg.Checks {
+		co := a.outputs[i]
+		co.Name, co.Type = chk.Name, chk.Type
+		if errs[i] != nil {
+			co.Error = errs[i].Error()
+		}
+		out.Checks[i] = co
+	}
+
+	enc := json.NewEncoder(a.cfg.Output)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	return nil
+}
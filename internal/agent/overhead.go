@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+const (
+	// overheadFullSpeed is the wind speed (km/h) from which an easterly
+	// counts in full. Heathrow keeps westerly operations with a light
+	// tailwind, and light winds are variable anyway.
+	overheadFullSpeed = 15
+	// overheadSteadyGustFactor is the gust-to-speed ratio up to which a wind
+	// counts as steady; gustier winds wander.
+	overheadSteadyGustFactor = 1.5
+)
+
+// overheadScore rates how likely planes are overhead on day, 0-100, as
+//
+//	100 × centre × strength × steadiness
+//
+// where centre is sin(direction) in the easterly half of the compass and 0
+// in the westerly half (1 due east, 0.71 from NE or SE, 0 from N or S),
+// strength is speed / 15 km/h capped at 1, and steadiness is 1.5 × speed /
+// gust capped at 1 (a gust factor up to 1.5 is steady, 3 halves the score).
+// A steady 20 km/h due easterly scores 100, the same from NNE about 38, and
+// any westerly 0.
+func overheadScore(day weather.ForecastDay) int {
+	centre := math.Sin(day.WindDirMean * math.Pi / 180)
+	if !isEasterly(day.WindDirMean) || centre <= 0 {
+		return 0
+	}
+	strength := min(day.WindSpeedMax/overheadFullSpeed, 1)
+	steadiness := 1.0
+	if day.WindGustMax > 0 {
+		steadiness = min(overheadSteadyGustFactor*day.WindSpeedMax/day.WindGustMax, 1)
+	}
+	return int(math.Round(100 * centre * max(strength, 0) * max(steadiness, 0)))
+}
+
+// countOverheadDays counts the days scoring at least alert; none when alert
+// is 0 (disabled).
+func countOverheadDays(days []weather.ForecastDay, alert int) int {
+	if alert <= 0 {
+		return 0
+	}
+	count := 0
+	for _, d := range days {
+		if overheadScore(d) >= alert {
+			count++
+		}
+	}
+	return count
+}
+
+// buildOverheadAnalysis creates a one-line summary of the days planes are
+// likely overhead, or nothing when the alert is disabled.
+func buildOverheadAnalysis(days []weather.ForecastDay, alert int) string {
+	if alert <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("Planes overhead likely: %d days (score ≥ %d)\n", countOverheadDays(days, alert), alert)
+}
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+func TestOverheadScore(t *testing.T) {
+	tests := []struct {
+		name             string
+		dir, speed, gust float64
+		want             int
+	}{
+		{"centre of the arc", 90, 20, 25, 100},
+		{"centre, light wind", 90, 7.5, 10, 50},
+		{"centre, gusty", 90, 20, 60, 50},
+		{"NE", 45, 20, 25, 71},
+		{"SE", 135, 20, 25, 71},
+		{"NNE", 22.5, 20, 25, 38},
+		{"edge of the arc", 10, 20, 25, 17},
+		{"other edge of the arc", 170, 20, 25, 17},
+		{"due north", 0, 20, 25, 0},
+		{"due south", 180, 20, 25, 0},
+		{"westerly", 270, 20, 25, 0},
+		{"south-westerly", 225, 40, 45, 0},
+		{"calm", 90, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		day := weather.ForecastDay{WindDirMean: tt.dir, WindSpeedMax: tt.speed, WindGustMax: tt.gust}
+		if got := overheadScore(day); got != tt.want {
+			t.Errorf("%s (%g° at %g gusting %g): score %d, want %d", tt.name, tt.dir, tt.speed, tt.gust, got, tt.want)
+		}
+	}
+}
+
+func TestOverheadAnalysis(t *testing.T) {
+	days := windDays(25, 90, 45, 10, 270) // scores 100, 71, 17, 0 at 20 km/h
+	for i := range days {
+		days[i].WindSpeedMax = 20
+	}
+	tests := []struct {
+		alert int
+		want  string
+	}{
+		{0, ""},
+		{71, "Planes overhead likely: 2 days (score ≥ 71)\n"},
+		{72, "Planes overhead likely: 1 days (score ≥ 72)\n"},
+		{101, "Planes overhead likely: 0 days (score ≥ 101)\n"},
+	}
+	for _, tt := range tests {
+		if got := buildOverheadAnalysis(days, tt.alert); got != tt.want {
+			t.Errorf("alert %d: %q, want %q", tt.alert, got, tt.want)
+		}
+	}
+}
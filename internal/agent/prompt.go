@@ -0,0 +1,219 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/emanuelefumagalli/test-agent/internal/notify"
+)
+
+// PromptData is the input to the wind and rain prompt templates.
+type PromptData struct {
+	Location string // check name, e.g. "London Heathrow"
+	Days     int    // number of forecast days in Table
+	Analysis string // easterly/gust summary (wind) or today's school-run verdict (rain)
+	Schedule string // school-run windows per weekday (rain only)
+	Table    string // plain-text forecast table
+	Today    string // first forecast date, e.g. "Mon 02 Jan"
+	Language string // Config.SummaryLanguage, e.g. "Italian"
+}
+
+// defaultSummaryLanguage is the prompts' own language; it needs no instruction.
+const defaultSummaryLanguage = "English"
+
+// languageInstruction is appended to a prompt rendered from tmpl so the model
+// answers in language, however the template is worded. English prompts need
+// none, nor do templates that use {{.Language}} themselves.
+func languageInstruction(tmpl *template.Template, language string) string {
+	if strings.EqualFold(language, defaultSummaryLanguage) || usesField(tmpl, "Language") {
+		return ""
+	}
+	return fmt.Sprintf("\nRespond in %s.", language)
+}
+
+// usesField reports whether any of tmpl's templates refers to the named field
+// of the data it is executed with, as .Name or $.Name.
+func usesField(tmpl *template.Template, name string) bool {
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil && nodeUsesField(t.Tree.Root, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeUsesField(node parse.Node, name string) bool {
+	var children []parse.Node
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		return n.Ident[0] == name
+	case *parse.VariableNode:
+		return len(n.Ident) > 1 && n.Ident[0] == "$" && n.Ident[1] == name
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		children = n.Nodes
+	case *parse.ActionNode:
+		children = []parse.Node{n.Pipe}
+	case *parse.IfNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.RangeNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.WithNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.TemplateNode:
+		children = []parse.Node{n.Pipe}
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			children = append(children, cmd)
+		}
+	case *parse.CommandNode:
+		children = n.Args
+	case *parse.ChainNode:
+		children = []parse.Node{n.Node}
+	}
+	for _, c := range children {
+		if nodeUsesField(c, name) {
+			return true
+		}
+	}
+	return false
+}
+
+const defaultWindPrompt = `{{.Location}} wind forecast. Easterly wind = planes overhead (✈️).
+
+{{.Analysis}}
+{{.Table}}
+Summarize briefly: how many easterly days and when does wind change direction?`
+
+const defaultRainPrompt = `{{.Location}} {{.Days}}-day rain forecast for school runs.
+{{.Schedule}}
+
+TODAY: {{.Analysis}}
+
+{{.Table}}
+Brief friendly summary: umbrella needed today? Which days this week look rainy?`
+
+// OutlookData is the input to the wind outlook templates.
+type OutlookData struct {
+	Days int // number of forecast days analysed
+	East int // easterly days among them
+	West int // westerly days among them
+}
+
+// manyEasterlyDays is how many easterly days in the forecast earn the
+// Config.ManyEasterlyTemplate line.
+const manyEasterlyDays = 3
+
+const defaultNoEasterly = `No easterly days in the next {{.Days}} — planes landing from the west all period.`
+
+const defaultManyEasterly = `🎉 {{.East}} easterly days in the next {{.Days}} — planes overhead, get the camera ready!`
+
+// parseOutlook parses text as an outlook template, falling back to def when
+// empty, and dry-runs it like parsePrompt.
+func parseOutlook(name, text, def string) (*template.Template, error) {
+	return parseTemplate(name, name+" template", text, def, OutlookData{})
+}
+
+// parsePrompt parses text as a prompt template, falling back to def when empty.
+// The template is dry-run against empty data so unknown fields are caught early.
+func parsePrompt(name, text, def string) (*template.Template, error) {
+	return parseTemplate(name, name+" prompt template", text, def, PromptData{})
+}
+
+// parseTemplate parses text, or def when text is blank, as the template name
+// and dry-runs it against data. what names the template in errors.
+func parseTemplate(name, what, text, def string, data any) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		text = def
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", what, err)
+	}
+	if err := tmpl.Execute(io.Discard, data); err != nil {
+		return nil, fmt.Errorf("check %s: %w", what, err)
+	}
+	return tmpl, nil
+}
+
+// MessageData is the input to a notifier's message template (see
+// Config.MessageTemplates).
+type MessageData struct {
+	Location string    // check name, e.g. "London Heathrow"
+	Type     CheckType // "wind" or "rain"
+	Stale    bool      // built from the last good forecast after a failed fetch
+	Changes  string    // forecast changes since the last run (see Config.ShowForecastChanges)
+	Analysis string    // easterly/gust summary (wind) or today's school-run verdict (rain)
+	Summary  string    // the Ollama summary; empty without one
+	Table    string    // plain-text forecast table
+	// Message is the default message as plain text: with its heading, or
+	// as its section in digest mode
+	Message string
+	// The structured report for the check's type; nil for the other
+	Wind *WindReport
+	Rain *RainReport
+}
+
+// parseMessageTemplates parses each notifier's message template, returning
+// them by index into notifiers, nil for those without one. Like parsePrompt,
+// each is dry-run against empty data.
+func parseMessageTemplates(texts map[notify.Notifier]string, notifiers []notify.Notifier) ([]*template.Template, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	out := make([]*template.Template, len(notifiers))
+	found := 0
+	for i, n := range notifiers {
+		text, ok := texts[n]
+		if !ok {
+			continue
+		}
+		found++
+		name := fmt.Sprintf("notifier %d message", i)
+		tmpl, err := parseTemplate(name, name+" template", text, "", MessageData{Wind: &WindReport{}, Rain: &RainReport{}})
+		if err != nil {
+			return nil, err
+		}
+		out[i] = tmpl
+	}
+	if found < len(texts) {
+		return nil, errors.New("message template for a notifier not in Notifiers")
+	}
+	return out, nil
+}
+
+// renderPrompt executes tmpl with data.
+func renderPrompt(tmpl *template.Template, data PromptData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("render %s prompt: %w", tmpl.Name(), err)
+	}
+	return b.String(), nil
+}
+
+// backticks matches a run of backticks, which sanitizeSummary replaces whole
+// so "```" becomes a single apostrophe.
+var backticks = regexp.MustCompile("`+")
+
+// sanitizeSummary drops summary's code-fence lines and turns its remaining
+// backticks into apostrophes (see Config.SanitizeSummary).
+func sanitizeSummary(summary string) string {
+	var lines []string
+	for _, line := range strings.Split(summary, "\n") {
+		if t := strings.TrimSpace(line); strings.HasPrefix(t, "```") || strings.HasPrefix(t, "~~~") {
+			continue
+		}
+		lines = append(lines, backticks.ReplaceAllString(line, "'"))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
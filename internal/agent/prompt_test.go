@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emanuelefumagalli/test-agent/internal/notify"
+	"github.com/emanuelefumagalli/test-agent/internal/ollama"
+)
+
+func TestSanitizeSummary(t *testing.T) {
+	tests := []struct {
+		name, summary, want string
+	}{
+		{"plain", "Easterly tomorrow, so expect arrivals overhead.", "Easterly tomorrow, so expect arrivals overhead."},
+		{"inline code", "Gusts hit `40 km/h` on Friday.", "Gusts hit '40 km/h' on Friday."},
+		{"inline triple backticks", "A fence ``` mid-line closes the table.", "A fence ' mid-line closes the table."},
+		{"long backtick run", "Runs of ```````` collapse.", "Runs of ' collapse."},
+		{"fenced block", "Summary:\n```\n| Day | Wind |\n```\nDone.", "Summary:\n| Day | Wind |\nDone."},
+		{"fence with language", "```markdown\nEasterly on Friday.\n```", "Easterly on Friday."},
+		{"indented fence", "Note:\n   ```\nEasterly.\n   ```", "Note:\nEasterly."},
+		{"tilde fence", "~~~\nEasterly.\n~~~~", "Easterly."},
+		{"unclosed fence", "Easterly.\n```", "Easterly."},
+		{"windows line endings", "```\r\nEasterly.\r\n```\r\n", "Easterly."},
+		{"nothing but fences", "```\n```\n~~~", ""},
+		{"tildes mid-line are kept", "Roughly ~~~ 20 km/h.", "Roughly ~~~ 20 km/h."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeSummary(tt.summary); got != tt.want {
+				t.Errorf("sanitizeSummary(%q) = %q, want %q", tt.summary, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeSummaryKeepsTableFenced(t *testing.T) {
+	const summary = "```\nEasterly on Friday, see `the table`.\n```\n~~~"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"response": summary})
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		sanitize bool
+		want     string
+	}{
+		{false, summary},
+		{true, "Easterly on Friday, see 'the table'."},
+	}
+	for _, tt := range tests {
+		rec := &recordingNotifier{}
+		a := newTestAgent(t, Config{
+			Checks:          []Check{{Name: "Heathrow", Type: CheckWind, Weather: &fakeWeather{wind: windDays(20, 90, 90, 270)}}},
+			Ollama:          &ollama.Client{Host: srv.URL},
+			SanitizeSummary: tt.sanitize,
+			Notifiers:       []notify.Notifier{rec},
+			RunOnce:         true,
+		})
+		if err := a.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		sent := rec.sent()
+		if len(sent) != 1 {
+			t.Fatalf("sent %d messages, want 1", len(sent))
+		}
+		if !strings.HasSuffix(sent[0], "```\n"+tt.want) {
+			t.Errorf("SanitizeSummary %v: message does not end with the table then %q:\n%s", tt.sanitize, tt.want, sent[0])
+		}
+		// Only the table's own fence pair is left once sanitized
+		if n := strings.Count(sent[0], "```"); tt.sanitize && n != 2 {
+			t.Errorf("SanitizeSummary %v: %d fences, want 2:\n%s", tt.sanitize, n, sent[0])
+		}
+	}
+}
+
+func TestLanguageInstruction(t *testing.T) {
+	tests := []struct {
+		name, template, language, want string
+	}{
+		{"default template", "", "Italian", "\nRespond in Italian."},
+		{"English", "", "english", ""},
+		{"template places the language", "{{.Table}}\nAnswer in {{.Language}}.", "Italian", ""},
+		{"language in a condition", `{{if eq .Language "Italian"}}Ciao.{{end}}{{.Table}}`, "Italian", ""},
+		{"language from inside with", "{{with .Table}}{{.}} in {{$.Language}}{{end}}", "Italian", ""},
+		{"language only in the text", "Language: none. {{.Table}}", "Italian", "\nRespond in Italian."},
+	}
+	for _, tt := range tests {
+		tmpl, err := parsePrompt("wind", tt.template, defaultWindPrompt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := languageInstruction(tmpl, tt.language); got != tt.want {
+			t.Errorf("%s: instruction %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/notify"
+)
+
+// TimeOfDay is a wall-clock time, e.g. {22, 30} for 22:30.
+type TimeOfDay struct {
+	Hour   int
+	Minute int
+}
+
+// ParseTimeOfDay parses "HH:MM" (24-hour).
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return TimeOfDay{}, fmt.Errorf("parse time of day %q: want HH:MM", s)
+	}
+	return TimeOfDay{Hour: t.Hour(), Minute: t.Minute()}, nil
+}
+
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", t.Hour, t.Minute)
+}
+
+func (t TimeOfDay) valid() bool {
+	return t.Hour >= 0 && t.Hour < 24 && t.Minute >= 0 && t.Minute < 60
+}
+
+// on returns t on the calendar day of d, in d's location.
+func (t TimeOfDay) on(d time.Time) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), t.Hour, t.Minute, 0, 0, d.Location())
+}
+
+// quietHours holds notifications during a daily window (see
+// Config.QuietStart) and hands them back when it ends. A nil *quietHours
+// holds nothing.
+type quietHours struct {
+	start, end TimeOfDay
+	loc        *time.Location
+	applies    []bool // per Config.Notifiers
+
+	mu   sync.Mutex
+	held []heldMessage
+}
+
+type heldMessage struct {
+	notifier int // index into Config.Notifiers
+	msg      notify.Message
+}
+
+// newQuietHours returns nil when start and end are equal (no window).
+// applies lists the notifiers the window covers, nil meaning all.
+func newQuietHours(start, end TimeOfDay, timezone string, notifiers, applies []notify.Notifier) (*quietHours, error) {
+	if start == end {
+		return nil, nil
+	}
+	if !start.valid() || !end.valid() {
+		return nil, fmt.Errorf("quiet hours %s-%s: invalid time of day", start, end)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("quiet hours: load timezone %q: %w", timezone, err)
+	}
+	q := &quietHours{start: start, end: end, loc: loc, applies: make([]bool, len(notifiers))}
+	for i, n := range notifiers {
+		q.applies[i] = applies == nil
+		for _, a := range applies {
+			if a == n {
+				q.applies[i] = true
+			}
+		}
+	}
+	return q, nil
+}
+
+// active reports whether now falls in the window, which crosses midnight
+// when start is after end.
+func (q *quietHours) active(now time.Time) bool {
+	if q == nil {
+		return false
+	}
+	now = now.In(q.loc)
+	start, end := q.start.on(now), q.end.on(now)
+	if q.start.Hour*60+q.start.Minute < q.end.Hour*60+q.end.Minute {
+		return !now.Before(start) && now.Before(end)
+	}
+	return !now.Before(start) || now.Before(end)
+}
+
+// nextEnd returns the first end of the window after now.
+func (q *quietHours) nextEnd(now time.Time) time.Time {
+	now = now.In(q.loc)
+	end := q.end.on(now)
+	if !end.After(now) {
+		end = q.end.on(now.AddDate(0, 0, 1))
+	}
+	return end
+}
+
+// hold keeps msg for notifier i if the window covers it and is active at
+// now, reporting whether it did.
+func (q *quietHours) hold(i int, msg notify.Message, now time.Time) bool {
+	if !q.active(now) || !q.applies[i] {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.held = append(q.held, heldMessage{notifier: i, msg: msg})
+	return true
+}
+
+// take returns and clears the held messages, oldest first.
+func (q *quietHours) take() []heldMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	held := q.held
+	q.held = nil
+	return held
+}
+
+// runQuietHours sends the held notifications at the end of each quiet
+// window until ctx is done. Messages still held then are dropped.
+func (a *Agent) runQuietHours(ctx context.Context) error {
+	for {
+		now := a.cfg.Clock.Now()
+		select {
+		case <-ctx.Done():
+			if held := a.quiet.take(); len(held) > 0 {
+				a.log.Warn("dropping notifications held for quiet hours", "count", len(held))
+			}
+			return ctx.Err()
+		case <-a.cfg.Clock.After(a.quiet.nextEnd(now).Sub(now)):
+		}
+
+		held := a.quiet.take()
+		if len(held) > 0 {
+			a.log.Info("quiet hours over, sending held notifications", "count", len(held))
+		}
+		for _, h := range held {
+			if err := a.send(ctx, a.cfg.Notifiers[h.notifier], h.msg); err != nil {
+				a.log.Error("notify failed", "err", err)
+			}
+		}
+	}
+}
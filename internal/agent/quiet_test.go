@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/notify"
+)
+
+func TestQuietHoursActive(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2026, 10, 16, hour, minute, 0, 0, time.UTC) }
+	tests := []struct {
+		name       string
+		start, end TimeOfDay
+		now        time.Time
+		want       bool
+	}{
+		{"daytime, before", TimeOfDay{12, 0}, TimeOfDay{14, 0}, at(11, 59), false},
+		{"daytime, at the start", TimeOfDay{12, 0}, TimeOfDay{14, 0}, at(12, 0), true},
+		{"daytime, inside", TimeOfDay{12, 0}, TimeOfDay{14, 0}, at(13, 30), true},
+		{"daytime, at the end", TimeOfDay{12, 0}, TimeOfDay{14, 0}, at(14, 0), false},
+		{"overnight, evening before", TimeOfDay{22, 30}, TimeOfDay{7, 0}, at(22, 29), false},
+		{"overnight, late evening", TimeOfDay{22, 30}, TimeOfDay{7, 0}, at(23, 0), true},
+		{"overnight, midnight", TimeOfDay{22, 30}, TimeOfDay{7, 0}, at(0, 0), true},
+		{"overnight, early morning", TimeOfDay{22, 30}, TimeOfDay{7, 0}, at(6, 59), true},
+		{"overnight, at the end", TimeOfDay{22, 30}, TimeOfDay{7, 0}, at(7, 0), false},
+		{"overnight, midday", TimeOfDay{22, 30}, TimeOfDay{7, 0}, at(12, 0), false},
+	}
+	for _, tt := range tests {
+		q, err := newQuietHours(tt.start, tt.end, "UTC", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := q.active(tt.now); got != tt.want {
+			t.Errorf("%s: active at %s = %v, want %v", tt.name, tt.now.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestQuietHoursNextEnd(t *testing.T) {
+	q, err := newQuietHours(TimeOfDay{22, 0}, TimeOfDay{7, 0}, "Europe/London", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	london := mustLoadLocation(t, "Europe/London")
+	tests := []struct {
+		now, want time.Time
+	}{
+		{time.Date(2026, 10, 16, 23, 0, 0, 0, london), time.Date(2026, 10, 17, 7, 0, 0, 0, london)},
+		{time.Date(2026, 10, 17, 1, 0, 0, 0, london), time.Date(2026, 10, 17, 7, 0, 0, 0, london)},
+		// The clocks go back overnight; the window still ends at 07:00 local
+		{time.Date(2026, 10, 24, 23, 0, 0, 0, london), time.Date(2026, 10, 25, 7, 0, 0, 0, london)},
+	}
+	for _, tt := range tests {
+		if got := q.nextEnd(tt.now); !got.Equal(tt.want) {
+			t.Errorf("nextEnd(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestQuietHoursHoldNotifications(t *testing.T) {
+	tests := []struct {
+		name           string
+		now            time.Time
+		quietOnlyFirst bool
+		wantSent       [2]int // per notifier
+	}{
+		{name: "outside the window", now: time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC), wantSent: [2]int{1, 1}},
+		{name: "inside the window", now: time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC), wantSent: [2]int{0, 0}},
+		{name: "inside the window after midnight", now: time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC), wantSent: [2]int{0, 0}},
+		{name: "inside, for one notifier", now: time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC), quietOnlyFirst: true, wantSent: [2]int{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recs := []*recordingNotifier{{}, {}}
+			cfg := Config{
+				Checks:     []Check{{Name: "Heathrow", Type: CheckWind, Weather: &fakeWeather{wind: windDays(20, 90, 270)}}},
+				Clock:      &fakeClock{now: tt.now},
+				Notifiers:  []notify.Notifier{recs[0], recs[1]},
+				QuietStart: TimeOfDay{22, 0},
+				QuietEnd:   TimeOfDay{7, 0},
+			}
+			if tt.quietOnlyFirst {
+				cfg.QuietNotifiers = []notify.Notifier{recs[0]}
+			}
+			a := newTestAgent(t, cfg)
+			if err := a.doCheck(context.Background(), 0); err != nil {
+				t.Fatal(err)
+			}
+			for i, rec := range recs {
+				if got := len(rec.sent()); got != tt.wantSent[i] {
+					t.Errorf("notifier %d sent %d messages, want %d", i, got, tt.wantSent[i])
+				}
+			}
+			// The rest wait for the window to end
+			if held := len(a.quiet.take()); held != 2-tt.wantSent[0]-tt.wantSent[1] {
+				t.Errorf("%d messages held, want %d", held, 2-tt.wantSent[0]-tt.wantSent[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+// WindReport is a wind check's forecast and analysis as data, for callers
+// that render it themselves. Counts cover upcoming days only.
+type WindReport struct {
+	Location      string
+	GustThreshold float64 // km/h
+	// WindSpeedAlert is Config.WindSpeedAlert (km/h); 0 when disabled
+	WindSpeedAlert float64
+	Days           []WindDay
+	EasterlyDays   int
+	WesterlyDays   int
+	GustyDays      int
+	HighWindDays   int
+
+	// OverheadAlert is Config.OverheadAlert (0 when disabled), and
+	// OverheadDays the days scoring at least it
+	OverheadAlert int
+	OverheadDays  int
+}
+
+// WindDay is one day of a WindReport.
+type WindDay struct {
+	Date      time.Time
+	WindSpeed float64 // max, km/h
+	WindGust  float64 // max, km/h
+	WindDir   float64 // dominant, degrees (0 = North)
+	Direction string  // "E" or "W", smoothed per Config.DirectionHysteresis
+	Easterly  bool
+	Gusty     bool // gust above GustThreshold
+	HighWind  bool // wind speed above WindSpeedAlert
+	Overhead  int  // how likely planes are overhead, 0-100 (see Config.ShowOverheadScore)
+	// Conditions combines Easterly and Gusty, e.g. OverheadGusty
+	Conditions FlyingConditions
+	LongRange  bool // ensemble outlook beyond 16 days; low confidence
+	Past       bool // before today; not included in the counts
+}
+
+// Umbrella is the verdict for a school-run window.
+type Umbrella string
+
+const (
+	UmbrellaNone     Umbrella = "none"
+	UmbrellaMaybe    Umbrella = "maybe"
+	UmbrellaDefinite Umbrella = "definite"
+)
+
+// RainReport is a rain check's forecast and school-run analysis as data, for
+// callers that render it themselves.
+type RainReport struct {
+	Location string
+	Days     []RainDay
+}
+
+// RainDay is one day of a RainReport.
+type RainDay struct {
+	Date        time.Time
+	PrecipProb  int     // daily max %
+	PrecipMM    float64 // daily total
+	HasPrecipMM bool
+	// PrecipSpreadMM is the ensemble spread (standard deviation) of PrecipMM,
+	// valid when HasSpread; LowConfidence marks members that disagree.
+	PrecipSpreadMM float64
+	HasSpread      bool
+	LowConfidence  bool
+	// Rainy is whether the day reaches Config.RainyDayThreshold
+	Rainy bool
+
+	// School is false on no-school weekdays and holidays (Holiday set),
+	// in which case DropOff and Pickup are nil.
+	School  bool
+	Holiday bool
+	DropOff *RainWindow
+	Pickup  *RainWindow
+	// Windows has every school-run window, drop-offs first; DropOff and
+	// Pickup are the first of each kind (see SchoolDay.Windows)
+	Windows []RainWindow
+}
+
+// RainWindow is the rain risk for one school-run window.
+type RainWindow struct {
+	Name      string // SchoolWindow.Name; empty for an unnamed window
+	Pickup    bool
+	Label     string
+	Prob      int     // max hourly %
+	MM        float64 // heaviest hourly amount; valid when HasMM
+	HasMM     bool
+	Intensity string // e.g. "steady rain"; empty when HasMM is false
+	Umbrella  Umbrella
+	Snow      bool    // snow rather than rain (see Config.SnowThresholdCM)
+	SnowCM    float64 // heaviest hourly snowfall when Snow
+	// Transition is where rain starts or clears within the window; nil when
+	// it is steadily wet or dry.
+	Transition *RainTransition
+}
+
+// WindReport fetches the forecast for the wind check named check and returns
+// it with the easterly and gust analysis. Nothing is sent to notifiers.
+func (a *Agent) WindReport(ctx context.Context, check string) (WindReport, error) {
+	i, err := a.checkIndex(check, CheckWind)
+	if err != nil {
+		return WindReport{}, err
+	}
+	chk := a.cfg.Checks[i]
+
+	forecast, err := limitedFetch(ctx, a, chk.Weather.FetchLongRange, chk.Days)
+	if err != nil {
+		return WindReport{}, fmt.Errorf("fetch forecast: %w", err)
+	}
+	return newWindReport(chk.Name, forecast, a.windOptions()), nil
+}
+
+// WindArchiveReport is WindReport over observed winds from start to end
+// inclusive, for checking the easterly and gust analysis against what actually
+// happened. Every day counts; none are marked Past. Nothing is sent to
+// notifiers.
+func (a *Agent) WindArchiveReport(ctx context.Context, check string, start, end time.Time) (WindReport, error) {
+	i, err := a.checkIndex(check, CheckWind)
+	if err != nil {
+		return WindReport{}, err
+	}
+	chk := a.cfg.Checks[i]
+
+	archive, ok := chk.Weather.(weather.ArchiveFetcher)
+	if !ok {
+		return WindReport{}, fmt.Errorf("check %q: weather source has no archive", check)
+	}
+	release, err := a.acquireFetch(ctx)
+	if err != nil {
+		return WindReport{}, err
+	}
+	days, err := archive.FetchArchive(ctx, start, end)
+	release()
+	if err != nil {
+		return WindReport{}, fmt.Errorf("fetch archive: %w", err)
+	}
+	return newWindReport(chk.Name, days, a.windOptions()), nil
+}
+
+// RainReport fetches the forecast for the rain check named check and returns
+// it with the school-run analysis for every day. Nothing is sent to notifiers.
+func (a *Agent) RainReport(ctx context.Context, check string) (RainReport, error) {
+	i, err := a.checkIndex(check, CheckRain)
+	if err != nil {
+		return RainReport{}, err
+	}
+	chk := a.cfg.Checks[i]
+
+	forecast, err := limitedFetch(ctx, a, chk.Weather.FetchRain, chk.Days)
+	if err != nil {
+		return RainReport{}, fmt.Errorf("fetch forecast: %w", err)
+	}
+	return newRainReport(chk.Name, forecast, a.rainOptions(ctx)), nil
+}
+
+// checkIndex finds the configured check with the given name and type.
+func (a *Agent) checkIndex(name string, typ CheckType) (int, error) {
+	for i, chk := range a.cfg.Checks {
+		if chk.Name == name && chk.Type == typ {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no %s check named %q", typ, name)
+}
+
+func newWindReport(location string, days []weather.ForecastDay, opts windOptions) WindReport {
+	upcoming := upcomingDays(days)
+	r := WindReport{
+		Location:       location,
+		GustThreshold:  opts.gust,
+		WindSpeedAlert: opts.speedAlert,
+		Days:           make([]WindDay, 0, len(days)),
+		EasterlyDays:   countEasterlyDays(upcoming, opts.hysteresis),
+		GustyDays:      countGustyDays(upcoming, opts.gust),
+		HighWindDays:   countHighWindDays(upcoming, opts.speedAlert),
+		OverheadAlert:  opts.ovhAlert,
+		OverheadDays:   countOverheadDays(upcoming, opts.ovhAlert),
+	}
+	r.WesterlyDays = len(upcoming) - r.EasterlyDays
+
+	easterly := easterlyDays(days, opts.hysteresis)
+	for i, d := range days {
+		gusty := isGusty(d, opts.gust)
+		r.Days = append(r.Days, WindDay{
+			Date:       d.Date,
+			WindSpeed:  d.WindSpeedMax,
+			WindGust:   d.WindGustMax,
+			WindDir:    d.WindDirMean,
+			Direction:  compass(easterly[i]),
+			Easterly:   easterly[i],
+			Gusty:      gusty,
+			HighWind:   isHighWind(d, opts.speedAlert),
+			Overhead:   overheadScore(d),
+			Conditions: classifyDay(easterly[i], gusty),
+			LongRange:  d.LongRange,
+			Past:       d.Past,
+		})
+	}
+	return r
+}
+
+func newRainReport(location string, days []weather.RainForecast, opts rainOptions) RainReport {
+	r := RainReport{Location: location, Days: make([]RainDay, 0, len(days))}
+
+	for _, d := range days {
+		day := RainDay{
+			Date:           d.Date,
+			PrecipProb:     d.PrecipProb,
+			PrecipMM:       d.PrecipMM,
+			HasPrecipMM:    d.HasPrecipMM,
+			PrecipSpreadMM: d.PrecipSpreadMM,
+			HasSpread:      d.HasSpread,
+			LowConfidence:  lowConfidence(d),
+			Rainy:          opts.rainyDay.rainy(d),
+			Holiday:        inRanges(opts.holidays, d.Date),
+		}
+		if sd, ok := opts.schedule[d.Date.Weekday()]; ok && !day.Holiday {
+			day.School = true
+			for _, w := range sd.windows() {
+				rw := newRainWindow(d, w, opts)
+				day.Windows = append(day.Windows, *rw)
+				switch {
+				case w.Pickup && day.Pickup == nil:
+					day.Pickup = rw
+				case !w.Pickup && day.DropOff == nil:
+					day.DropOff = rw
+				}
+			}
+		}
+		r.Days = append(r.Days, day)
+	}
+	return r
+}
+
+// newRainWindow evaluates w on day.
+func newRainWindow(day weather.RainForecast, sw SchoolWindow, opts rainOptions) *RainWindow {
+	w := &sw.HourWindow
+	rw := &RainWindow{Name: sw.Name, Pickup: sw.Pickup, Label: w.label(), Prob: windowProb(day, *w, opts), Umbrella: UmbrellaNone}
+	switch {
+	case rw.Prob >= opts.definite:
+		rw.Umbrella = UmbrellaDefinite
+	case rw.Prob >= opts.maybe:
+		rw.Umbrella = UmbrellaMaybe
+	}
+	if mm, ok := windowMM(day, *w); ok {
+		rw.MM, rw.HasMM = mm, true
+		rw.Intensity = intensity(mm, opts)
+	}
+	if cm, snow := windowSnow(day, *w, opts); snow {
+		rw.Snow, rw.SnowCM = true, cm
+	}
+	rw.Transition = rainTransition(day, *w, opts)
+	return rw
+}
@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSpec is a parsed five-field cron expression (minute hour day-of-month
+// month day-of-week). Each field is a bitset of the values it matches.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+
+	// As in standard cron, when both day fields are restricted a day matches
+	// if either does.
+	domStar, dowStar bool
+}
+
+// dailyCron is the spec for a run every day at hour:minute.
+func dailyCron(hour, minute int) string {
+	return fmt.Sprintf("%d %d * * *", minute, hour)
+}
+
+// parseCron parses a standard five-field cron expression. Fields accept *,
+// numbers, ranges (1-5), steps (*/15, 8-18/2) and comma lists; day-of-week is
+// 0-7 with both 0 and 7 meaning Sunday.
+func parseCron(spec string) (*cronSpec, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: want 5 fields (minute hour day month weekday), got %d", spec, len(fields))
+	}
+
+	var c cronSpec
+	var err error
+	parsers := []struct {
+		name     string
+		min, max int
+		dst      *uint64
+	}{
+		{"minute", 0, 59, &c.minute},
+		{"hour", 0, 23, &c.hour},
+		{"day of month", 1, 31, &c.dom},
+		{"month", 1, 12, &c.month},
+		{"day of week", 0, 7, &c.dow},
+	}
+	for i, p := range parsers {
+		if *p.dst, err = parseCronField(fields[i], p.min, p.max); err != nil {
+			return nil, fmt.Errorf("cron %q: %s: %w", spec, p.name, err)
+		}
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 // 7 is Sunday too
+	}
+	c.domStar = fields[2] == "*"
+	c.dowStar = fields[4] == "*"
+	return &c, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = cronValue(a, min, max); err != nil {
+				return 0, err
+			}
+			if hi, err = cronValue(b, min, max); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			v, err := cronValue(rng, min, max)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if !hasStep {
+				hi = v // "5/10" means 5 to max every 10, like cronie
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, min, max int) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("%d out of range %d-%d", v, min, max)
+	}
+	return v, nil
+}
+
+func (c *cronSpec) matchesDay(t time.Time) bool {
+	if c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first time matching c in loc strictly after now. Days are
+// stepped by calendar date rather than 24h so runs stay at the same
+// wall-clock time across DST transitions.
+func (c *cronSpec) next(now time.Time, loc *time.Location) (time.Time, error) {
+	now = now.In(loc)
+	// Five years covers every valid spec, including 29 February
+	for i := range 5 * 366 {
+		day := time.Date(now.Year(), now.Month(), now.Day()+i, 0, 0, 0, 0, loc)
+		if !c.matchesDay(day) {
+			continue
+		}
+		for h := range 24 {
+			if c.hour&(1<<h) == 0 {
+				continue
+			}
+			for m := range 60 {
+				if c.minute&(1<<m) == 0 {
+					continue
+				}
+				if t := time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, loc); t.After(now) {
+					return t, nil
+				}
+			}
+		}
+	}
+	return time.Time{}, errors.New("cron spec never matches")
+}
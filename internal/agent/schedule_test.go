@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronNextAcrossDST(t *testing.T) {
+	london := mustLoadLocation(t, "Europe/London")
+	utc := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	// 2026: BST starts 29 March 01:00 GMT and ends 25 October 01:00 GMT
+	tests := []struct {
+		name string
+		cron string
+		now  time.Time
+		want time.Time
+	}{
+		{"day before spring forward", "0 8 * * *", utc(time.March, 28, 9, 0), utc(time.March, 29, 7, 0)},
+		{"spring forward, before the change", "0 8 * * *", utc(time.March, 29, 0, 30), utc(time.March, 29, 7, 0)},
+		{"spring forward, after the change", "0 8 * * *", utc(time.March, 29, 1, 30), utc(time.March, 29, 7, 0)},
+		{"spring forward, past the hour", "0 8 * * *", utc(time.March, 29, 7, 30), utc(time.March, 30, 7, 0)},
+		{"day before fall back", "0 8 * * *", utc(time.October, 24, 8, 0), utc(time.October, 25, 8, 0)},
+		{"fall back, before the change", "0 8 * * *", utc(time.October, 24, 23, 30), utc(time.October, 25, 8, 0)},
+		{"fall back, after the change", "0 8 * * *", utc(time.October, 25, 1, 30), utc(time.October, 25, 8, 0)},
+		{"fall back, past the hour", "0 8 * * *", utc(time.October, 25, 8, 30), utc(time.October, 26, 8, 0)},
+		{"weekdays across spring forward", "30 7 * * 1-5", utc(time.March, 27, 8, 0), utc(time.March, 30, 6, 30)},
+		{"weekdays across fall back", "30 7 * * 1-5", utc(time.October, 23, 8, 0), utc(time.October, 26, 7, 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := parseCron(tt.cron)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := spec.next(tt.now, london)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("next(%s) = %s, want %s", tt.now, got, tt.want.In(london))
+			}
+		})
+	}
+}
+
+func TestSchedulerRejectsUnknownTimezone(t *testing.T) {
+	a, err := New(Config{
+		Checks:               []Check{{Name: "Twickenham", Type: CheckRain, Weather: &fakeWeather{}, Timezone: "Europe/Londn"}},
+		DisableNotifications: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.newScheduler(); err == nil || !strings.Contains(err.Error(), `"Europe/Londn"`) {
+		t.Errorf("err = %v, want the timezone named", err)
+	}
+}
+
+func TestNewValidatesWeeklySummary(t *testing.T) {
+	tests := []struct {
+		cron, timezone string
+		wantErr        string // "" for valid
+	}{
+		{"0 18 * * 0", "", ""},
+		{"0 18 * * 0", "Europe/London", ""},
+		{"0 9 29 2 *", "", ""},
+		{"0 9 31 2 *", "", "never matches"},
+		{"0 18 * * 0", "Europe/Londn", `"Europe/Londn"`},
+		{"0 25 * * 0", "", "weekly summary"},
+	}
+	for _, tt := range tests {
+		_, err := New(Config{
+			Checks:                []Check{{Name: "Heathrow", Type: CheckWind, Weather: &fakeWeather{}}},
+			WeeklySummaryCron:     tt.cron,
+			WeeklySummaryTimezone: tt.timezone,
+			DisableNotifications:  true,
+		})
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%q in %q: %v, want no error", tt.cron, tt.timezone, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%q in %q: %v, want an error containing %q", tt.cron, tt.timezone, err, tt.wantErr)
+		}
+	}
+}
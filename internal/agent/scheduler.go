@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Triggers of a scheduled run, as logged.
+const (
+	triggerStartup  = "startup"
+	triggerSchedule = "schedule"
+)
+
+// scheduledRun is one pending run of a check.
+type scheduledRun struct {
+	at      time.Time
+	check   int // index into Config.Checks
+	trigger string
+}
+
+// runQueue is a min-heap of pending runs, earliest first.
+type runQueue []scheduledRun
+
+func (q runQueue) Len() int           { return len(q) }
+func (q runQueue) Less(i, j int) bool { return q[i].at.Before(q[j].at) }
+func (q runQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *runQueue) Push(x any)        { *q = append(*q, x.(scheduledRun)) }
+func (q *runQueue) Pop() any {
+	old := *q
+	r := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return r
+}
+
+// scheduler fires every check from a single loop: it keeps the pending runs
+// in a queue ordered by time, sleeps until the earliest and starts it. Each
+// check runs in its own goroutine so a slow one doesn't delay the others, but
+// a check still running when its next run comes up skips that run.
+type scheduler struct {
+	a       *Agent
+	locs    []*time.Location // per check, for its schedule
+	queue   runQueue
+	running []atomic.Bool
+}
+
+// newScheduler validates every check and queues its startup run, if any, and
+// its first scheduled run.
+func (a *Agent) newScheduler() (*scheduler, error) {
+	s := &scheduler{
+		a:       a,
+		locs:    make([]*time.Location, len(a.cfg.Checks)),
+		running: make([]atomic.Bool, len(a.cfg.Checks)),
+	}
+	now := a.cfg.Clock.Now()
+	for i, chk := range a.cfg.Checks {
+		loc, err := a.checkLocation(chk)
+		if err != nil {
+			return nil, err
+		}
+		s.locs[i] = loc
+
+		if chk.RunOnStart && !a.cfg.SkipImmediateRun {
+			if last, ok := a.state.last(chk.stateKey()); ok && now.Sub(last) < a.cfg.MinRunInterval {
+				a.log.Info("skipping startup run, ran recently", "check", chk.Type, "location", chk.Name, "last_run", last.Format(time.RFC3339))
+			} else {
+				heap.Push(&s.queue, scheduledRun{at: now.Add(a.startupDelay(chk)), check: i, trigger: triggerStartup})
+			}
+		}
+		if err := s.scheduleNext(i, now); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// scheduleNext queues check i's first scheduled run after t.
+func (s *scheduler) scheduleNext(i int, t time.Time) error {
+	chk := s.a.cfg.Checks[i]
+	next, err := s.a.schedules[i].next(t, s.locs[i])
+	if err != nil {
+		return fmt.Errorf("check %q: %w", chk.Name, err)
+	}
+	heap.Push(&s.queue, scheduledRun{at: next, check: i, trigger: triggerSchedule})
+	s.publishNext(i)
+	s.a.log.Info("check scheduled", "check", chk.Type, "location", chk.Name, "cron", chk.Cron, "next_run", next.Format(time.RFC3339))
+	return nil
+}
+
+// publishNext records check i's earliest queued run in the agent's status.
+func (s *scheduler) publishNext(i int) {
+	var next time.Time
+	for _, r := range s.queue {
+		if r.check == i && (next.IsZero() || r.at.Before(next)) {
+			next = r.at
+		}
+	}
+	s.a.status.scheduled(i, next)
+}
+
+// tooLate reports whether a scheduled run firing skew after its slot
+// exceeds Config.MaxScheduleSkew.
+func (a *Agent) tooLate(skew time.Duration) bool {
+	return a.cfg.MaxScheduleSkew > 0 && skew > a.cfg.MaxScheduleSkew
+}
+
+// run fires queued runs until ctx is done, then waits for in-flight checks.
+func (s *scheduler) run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for s.queue.Len() > 0 {
+		r := s.queue[0]
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.a.cfg.Clock.After(r.at.Sub(s.a.cfg.Clock.Now())):
+		}
+		heap.Pop(&s.queue)
+
+		chk := s.a.cfg.Checks[r.check]
+		if r.trigger == triggerSchedule {
+			if skew := s.a.cfg.Clock.Now().Sub(r.at); s.a.tooLate(skew) {
+				// Later slots may have passed too; skip to the first still ahead
+				s.a.log.Warn("scheduled run fired late, skipping it", "check", chk.Type, "location", chk.Name, "scheduled_for", r.at.Format(time.RFC3339), "skew", skew)
+				if err := s.scheduleNext(r.check, s.a.cfg.Clock.Now()); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := s.scheduleNext(r.check, r.at); err != nil {
+				return err
+			}
+		}
+		s.publishNext(r.check)
+
+		if !s.running[r.check].CompareAndSwap(false, true) {
+			s.a.log.Warn("check still running, skipping this run", "check", chk.Type, "location", chk.Name, "trigger", r.trigger)
+			continue
+		}
+		wg.Go(func() {
+			defer s.running[r.check].Store(false)
+			s.a.log.Info("running check", "check", chk.Type, "location", chk.Name, "trigger", r.trigger)
+			_ = s.a.doCheck(ctx, r.check) // already logged
+		})
+	}
+
+	// Only reachable with no checks configured
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchedulerSkipsLateRun(t *testing.T) {
+	start := time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		late     time.Duration // past the 08:00 slot when the wait ends
+		wantRan  bool
+		wantNext time.Time
+	}{
+		{"on time", 0, true, time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)},
+		{"within the skew", 10 * time.Minute, true, time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)},
+		{"laptop asleep for hours", 5 * time.Hour, false, time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)},
+		{"laptop asleep past the next slot", 30 * time.Hour, false, time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			clock := &fakeClock{now: start, late: tt.late, limit: 1}
+			a := newTestAgent(t, Config{
+				Checks:          []Check{{Name: "Heathrow", Type: CheckWind, Weather: &fakeWeather{wind: windDays(20, 90)}, Hour: 8}},
+				Clock:           clock,
+				MaxScheduleSkew: time.Hour,
+			})
+			s, err := a.newScheduler()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// One wait for the 08:00 run, then the loop is stopped once the
+			// check, if it started, is done
+			clock.stop = func() {
+				go func() {
+					for s.running[0].Load() {
+						time.Sleep(time.Millisecond)
+					}
+					cancel()
+				}()
+			}
+			if err := s.run(ctx); !errors.Is(err, context.Canceled) {
+				t.Fatalf("run = %v, want context.Canceled", err)
+			}
+
+			if ran := a.ready[0].Load(); ran != tt.wantRan {
+				t.Errorf("ran = %v, want %v", ran, tt.wantRan)
+			}
+			// Late runs skip to the first slot still ahead rather than catch up
+			if next := s.queue[0].at; !next.Equal(tt.wantNext) {
+				t.Errorf("next run = %s, want %s", next, tt.wantNext)
+			}
+		})
+	}
+}
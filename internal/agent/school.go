@@ -0,0 +1,472 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+// HourWindow is an inclusive range of whole hours checked for rain, e.g.
+// Start 17, End 18 covers the 17:00 and 18:00 hourly values.
+type HourWindow struct {
+	Start int
+	End   int
+	Label string // shown in messages; defaults to "Start-End"
+}
+
+func (w HourWindow) label() string {
+	if w.Label != "" {
+		return w.Label
+	}
+	return fmt.Sprintf("%d-%d", w.Start, w.End)
+}
+
+// SchoolDay holds the school-run windows for one weekday. A nil window means
+// there is no drop-off or pickup to check that day. Windows, when set,
+// replaces DropOff and Pickup with any number of named windows, e.g. one
+// per child or school.
+type SchoolDay struct {
+	DropOff *HourWindow
+	Pickup  *HourWindow
+	Windows []SchoolWindow
+}
+
+// SchoolWindow is a named drop-off or pickup, e.g. Name "Anna" with Label
+// "8:40" shows as "DROP-OFF (Anna 8:40)" and a "Drop Anna" table column.
+type SchoolWindow struct {
+	Name   string
+	Pickup bool // a pickup rather than a drop-off
+	HourWindow
+}
+
+// title is the window's name and label, e.g. "Anna 8:40".
+func (w SchoolWindow) title() string {
+	if w.Name == "" {
+		return w.label()
+	}
+	return w.Name + " " + w.label()
+}
+
+// verdictName heads the window's school-run verdict.
+func (w SchoolWindow) verdictName() string {
+	if w.Pickup {
+		return "PICKUP"
+	}
+	return "DROP-OFF"
+}
+
+// windowKey identifies a window across weekdays: windows of the same kind
+// and name share a table column.
+type windowKey struct {
+	pickup bool
+	name   string
+}
+
+func (w SchoolWindow) key() windowKey {
+	return windowKey{pickup: w.Pickup, name: w.Name}
+}
+
+// column is the table header for windows with key k, e.g. "Drop Anna".
+func (k windowKey) column() string {
+	col := "Drop"
+	if k.pickup {
+		col = "Pick"
+	}
+	if k.name != "" {
+		col += " " + k.name
+	}
+	return col
+}
+
+// windows returns the day's windows, drop-offs first: Windows, or else
+// DropOff and Pickup unnamed.
+func (d SchoolDay) windows() []SchoolWindow {
+	var out []SchoolWindow
+	if len(d.Windows) == 0 {
+		if d.DropOff != nil {
+			out = append(out, SchoolWindow{HourWindow: *d.DropOff})
+		}
+		if d.Pickup != nil {
+			out = append(out, SchoolWindow{Pickup: true, HourWindow: *d.Pickup})
+		}
+		return out
+	}
+	for _, pickup := range []bool{false, true} {
+		for _, w := range d.Windows {
+			if w.Pickup == pickup {
+				out = append(out, w)
+			}
+		}
+	}
+	return out
+}
+
+// window returns the day's first window with key k, or nil.
+func (d SchoolDay) window(k windowKey) *SchoolWindow {
+	for _, w := range d.windows() {
+		if w.key() == k {
+			return &w
+		}
+	}
+	return nil
+}
+
+// SchoolSchedule maps each school weekday to its windows. Weekdays missing
+// from the map are treated as no-school days.
+type SchoolSchedule map[time.Weekday]SchoolDay
+
+// DefaultSchoolSchedule is Monday to Friday with an 8-9am drop-off, a 17-18
+// pickup, and an early 15:15-16 pickup on Wednesday.
+func DefaultSchoolSchedule() SchoolSchedule {
+	dropOff := &HourWindow{Start: 8, End: 9, Label: "8-9am"}
+	pickup := &HourWindow{Start: 17, End: 18, Label: "17-18"}
+	early := &HourWindow{Start: 15, End: 16, Label: "15:15-16"}
+
+	return SchoolSchedule{
+		time.Monday:    {DropOff: dropOff, Pickup: pickup},
+		time.Tuesday:   {DropOff: dropOff, Pickup: pickup},
+		time.Wednesday: {DropOff: dropOff, Pickup: early},
+		time.Thursday:  {DropOff: dropOff, Pickup: pickup},
+		time.Friday:    {DropOff: dropOff, Pickup: pickup},
+	}
+}
+
+// weekOrder is Monday to Sunday.
+var weekOrder = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+// columns returns the rain table's window columns: drop-offs then pickups,
+// each in order of first appearance in the week. A schedule of unnamed
+// windows always has both a Drop and a Pick column.
+func (s SchoolSchedule) columns() []windowKey {
+	named := false
+	for _, sd := range s {
+		named = named || len(sd.Windows) > 0
+	}
+	if !named {
+		return []windowKey{{pickup: false}, {pickup: true}}
+	}
+	var keys []windowKey
+	for _, pickup := range []bool{false, true} {
+		for _, wd := range weekOrder {
+			for _, w := range s[wd].windows() {
+				if w.Pickup == pickup && !slices.Contains(keys, w.key()) {
+					keys = append(keys, w.key())
+				}
+			}
+		}
+	}
+	return keys
+}
+
+// describe renders the schedule for the Ollama prompt, grouping weekdays that
+// share a window.
+func (s SchoolSchedule) describe() string {
+	group := func(k windowKey) string {
+		var labels []string
+		days := make(map[string][]string)
+		for _, wd := range weekOrder {
+			sd, ok := s[wd]
+			if !ok || sd.window(k) == nil {
+				continue
+			}
+			l := sd.window(k).label()
+			if _, seen := days[l]; !seen {
+				labels = append(labels, l)
+			}
+			days[l] = append(days[l], wd.String()[:3])
+		}
+		if len(labels) == 0 {
+			return "none"
+		}
+		parts := make([]string, 0, len(labels))
+		for _, l := range labels {
+			parts = append(parts, fmt.Sprintf("%s (%s)", l, strings.Join(days[l], "/")))
+		}
+		return strings.Join(parts, " or ")
+	}
+
+	var noSchool []string
+	for _, wd := range weekOrder {
+		if _, ok := s[wd]; !ok {
+			noSchool = append(noSchool, wd.String()[:3])
+		}
+	}
+
+	var b strings.Builder
+	for _, k := range s.columns() {
+		name := "Drop-off"
+		if k.pickup {
+			name = "Pickup"
+		}
+		if k.name != "" {
+			name += " (" + k.name + ")"
+		}
+		b.WriteString(name + ": " + group(k) + "\n")
+	}
+	if len(noSchool) > 0 {
+		b.WriteString("No school: " + strings.Join(noSchool, "/"))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Values for Config.RainAggregation.
+const (
+	RainAggregateMax       = "max"
+	RainAggregateMean      = "mean"
+	RainAggregateSustained = "sustained"
+)
+
+// Values for Config.RainPrimaryMetric.
+const (
+	RainMetricProbability = "probability"
+	RainMetricMM          = "mm"
+)
+
+// RainyDayThreshold defines a "rainy day" for the weekly count, apart from
+// the school-run thresholds: a day is rainy when its daily max probability
+// reaches Prob (%) or its daily total reaches MM. A zero field is unused;
+// with both zero, Prob defaults to 40.
+type RainyDayThreshold struct {
+	Prob int
+	MM   float64
+}
+
+func (t RainyDayThreshold) rainy(day weather.RainForecast) bool {
+	return (t.Prob > 0 && day.PrecipProb >= t.Prob) || (t.MM > 0 && day.HasPrecipMM && day.PrecipMM >= t.MM)
+}
+
+// ParseRainyDayThreshold parses a probability ("40%"), a daily total ("1mm")
+// or both, comma-separated ("40%,1mm").
+func ParseRainyDayThreshold(s string) (RainyDayThreshold, error) {
+	var t RainyDayThreshold
+	for part := range strings.SplitSeq(s, ",") {
+		part = strings.TrimSpace(part)
+		var err error
+		switch {
+		case part == "":
+			continue
+		case strings.HasSuffix(part, "%"):
+			_, err = fmt.Sscanf(part, "%d%%", &t.Prob)
+			if err == nil && (t.Prob <= 0 || t.Prob > 100) {
+				err = fmt.Errorf("%d%% out of range 1..100", t.Prob)
+			}
+		case strings.HasSuffix(part, "mm"):
+			_, err = fmt.Sscanf(part, "%gmm", &t.MM)
+			if err == nil && !(t.MM > 0) {
+				err = fmt.Errorf("%gmm must be above 0", t.MM)
+			}
+		default:
+			err = fmt.Errorf(`want a percentage ("40%%") or an amount ("1mm")`)
+		}
+		if err != nil {
+			return RainyDayThreshold{}, fmt.Errorf("parse rainy day threshold %q: %w", part, err)
+		}
+	}
+	return t, nil
+}
+
+// rainOptions carries the settings the rain table and school-run analysis
+// depend on.
+type rainOptions struct {
+	schedule SchoolSchedule
+	holidays []DateRange
+	maybe    int     // % for "maybe umbrella"
+	definite int     // % for "umbrella!"
+	lightMM  float64 // mm/h below which rain is "light drizzle"
+	heavyMM  float64 // mm/h from which rain is a "soaking downpour"
+	snowCM   float64 // cm/h of snowfall from which a window can count as snow
+	primary  string  // Config.RainPrimaryMetric
+
+	aggregation    string // Config.RainAggregation
+	sustainedHours int    // Config.RainSustainedHours
+
+	rainyDay RainyDayThreshold
+	markers  Markers
+
+	sparkline     bool   // Config.ShowSparkline
+	sparklineRamp string // Config.SparklineRamp
+	dryMorning    bool   // Config.ShowDryMorning
+
+	compact bool // Config.CompactTable
+	width   int  // Config.TableWidth
+}
+
+func (a *Agent) rainOptions(ctx context.Context) rainOptions {
+	return rainOptions{
+		schedule: a.cfg.SchoolSchedule,
+		holidays: a.schoolHolidays(ctx),
+		maybe:    a.cfg.RainMaybeThreshold,
+		definite: a.cfg.RainDefiniteThreshold,
+		lightMM:  a.cfg.RainLightMM,
+		heavyMM:  a.cfg.RainHeavyMM,
+		snowCM:   a.cfg.SnowThresholdCM,
+		primary:  a.cfg.RainPrimaryMetric,
+
+		aggregation:    a.cfg.RainAggregation,
+		sustainedHours: a.cfg.RainSustainedHours,
+
+		rainyDay: a.cfg.RainyDayThreshold,
+		markers:  a.cfg.Markers,
+
+		sparkline:     a.cfg.ShowSparkline,
+		sparklineRamp: a.cfg.SparklineRamp,
+		dryMorning:    a.cfg.ShowDryMorning,
+
+		compact: a.cfg.CompactTable,
+		width:   a.cfg.TableWidth,
+	}
+}
+
+// hourValue returns the value for hour h from whichever of the forecast's
+// morning or afternoon slices covers it, using the day's start hours to map
+// hours to indices.
+func hourValue[T any](day weather.RainForecast, h int, morning, afternoon []T) (T, bool) {
+	if i := h - day.MorningStartHour; i >= 0 && i < len(morning) {
+		return morning[i], true
+	}
+	if i := h - day.AfternoonStartHour; i >= 0 && i < len(afternoon) {
+		return afternoon[i], true
+	}
+	var zero T
+	return zero, false
+}
+
+// hourProb returns the hourly rain probability at hour h, if the forecast
+// carries it.
+func hourProb(day weather.RainForecast, h int) (int, bool) {
+	return hourValue(day, h, day.MorningRainProb, day.AfternoonProb)
+}
+
+// windowProb returns the hourly rain probability within w, aggregated as
+// opts.aggregation says, falling back to the daily max when no hourly values
+// are available (or all are zero).
+func windowProb(day weather.RainForecast, w HourWindow, opts rainOptions) int {
+	var probs []int
+	for h := w.Start; h <= w.End; h++ {
+		if p, ok := hourProb(day, h); ok {
+			probs = append(probs, p)
+		}
+	}
+	if len(probs) == 0 || slices.Max(probs) == 0 {
+		return day.PrecipProb
+	}
+
+	switch opts.aggregation {
+	case RainAggregateMean:
+		sum := 0
+		for _, p := range probs {
+			sum += p
+		}
+		return int(math.Round(float64(sum) / float64(len(probs))))
+	case RainAggregateSustained:
+		return sustainedProb(probs, opts.sustainedHours)
+	}
+	return slices.Max(probs)
+}
+
+// RainTransition is where the hourly rain probability crosses the "maybe
+// umbrella" threshold within a window.
+type RainTransition struct {
+	Hour     int  // first hour on the new side of the threshold
+	Starting bool // rain arriving (dry, then wet); false when it is clearing
+}
+
+// rainTransition finds the first hour within w at which the hourly
+// probability crosses opts.maybe, in either direction. It returns nil when the
+// window is steadily wet or dry, or has no hourly values.
+func rainTransition(day weather.RainForecast, w HourWindow, opts rainOptions) *RainTransition {
+	prev, seen := false, false
+	for h := w.Start; h <= w.End; h++ {
+		p, ok := hourProb(day, h)
+		if !ok {
+			continue
+		}
+		wet := p >= opts.maybe
+		if seen && wet != prev {
+			return &RainTransition{Hour: h, Starting: wet}
+		}
+		prev, seen = wet, true
+	}
+	return nil
+}
+
+// phrase describes t for the school-run verdict, e.g. "rain easing by 9:00".
+func (t RainTransition) phrase() string {
+	if t.Starting {
+		return fmt.Sprintf("rain arriving around %d:00", t.Hour)
+	}
+	return fmt.Sprintf("rain easing by %d:00", t.Hour)
+}
+
+// sustainedProb is the highest probability held for hours consecutive hours:
+// the best, over every run of that length, of the run's lowest hour. A window
+// shorter than hours counts as a single run, so a threshold is only reached
+// when every hour in it reaches it.
+func sustainedProb(probs []int, hours int) int {
+	run := min(max(hours, 1), len(probs))
+	best := 0
+	for i := 0; i+run <= len(probs); i++ {
+		best = max(best, slices.Min(probs[i:i+run]))
+	}
+	return best
+}
+
+// hourMM returns the hourly precipitation at hour h, if the forecast carries it.
+func hourMM(day weather.RainForecast, h int) (float64, bool) {
+	return hourValue(day, h, day.MorningRainMM, day.AfternoonRainMM)
+}
+
+// windowMM returns the heaviest hourly precipitation within w, and false when
+// the forecast has no amounts for the window.
+func windowMM(day weather.RainForecast, w HourWindow) (float64, bool) {
+	maxMM, found := 0.0, false
+	for h := w.Start; h <= w.End; h++ {
+		if mm, ok := hourMM(day, h); ok {
+			found = true
+			maxMM = max(maxMM, mm)
+		}
+	}
+	return maxMM, found
+}
+
+// intensity describes an hourly rain amount.
+func intensity(mm float64, opts rainOptions) string {
+	switch {
+	case mm >= opts.heavyMM:
+		return "soaking downpour"
+	case mm < opts.lightMM:
+		return "light drizzle"
+	}
+	return "steady rain"
+}
+
+// snowWaterRatio is Open-Meteo's cm of snowfall per mm of water equivalent,
+// the unit its precipitation figures (which include snow) are in: 7cm of
+// snow falls as 10mm of water.
+const snowWaterRatio = 0.7
+
+// hourSnow returns the hourly snowfall at hour h, if the forecast carries it.
+func hourSnow(day weather.RainForecast, h int) (float64, bool) {
+	return hourValue(day, h, day.MorningSnowCM, day.AfternoonSnowCM)
+}
+
+// windowSnow returns the heaviest hourly snowfall within w and whether the
+// window counts as snow: snowfall reaches the threshold and makes up at least
+// half of the precipitation. Without snowfall data it is treated as rain.
+func windowSnow(day weather.RainForecast, w HourWindow, opts rainOptions) (float64, bool) {
+	maxCM, maxMM := 0.0, 0.0
+	for h := w.Start; h <= w.End; h++ {
+		if cm, ok := hourSnow(day, h); ok {
+			maxCM = max(maxCM, cm)
+		}
+		if mm, ok := hourMM(day, h); ok {
+			maxMM = max(maxMM, mm)
+		}
+	}
+	return maxCM, maxCM >= opts.snowCM && maxCM/snowWaterRatio >= maxMM/2
+}
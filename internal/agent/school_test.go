@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+func TestHourProbShiftedWindow(t *testing.T) {
+	// Probabilities are 10 × the hour, so each maps back to its hour
+	day := weather.RainForecast{
+		MorningStartHour:   7,
+		MorningRainProb:    []int{70, 80, 90},
+		AfternoonStartHour: 14,
+		AfternoonProb:      []int{140, 150},
+	}
+	tests := []struct {
+		hour   int
+		want   int
+		wantOK bool
+	}{
+		{6, 0, false},
+		{7, 70, true},
+		{9, 90, true},
+		{10, 0, false},
+		{13, 0, false},
+		{14, 140, true},
+		{15, 150, true},
+		{16, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := hourProb(day, tt.hour)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("hourProb(%d) = %d, %v, want %d, %v", tt.hour, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestWindowProbShiftedWindow(t *testing.T) {
+	day := weather.RainForecast{
+		PrecipProb:         25,
+		MorningStartHour:   5,
+		MorningRainProb:    []int{0, 0, 0, 60, 20}, // 5:00-9:00
+		AfternoonStartHour: 16,
+		AfternoonProb:      []int{10, 30, 80}, // 16:00-18:00
+	}
+	opts := rainOptions{}
+	tests := []struct {
+		window HourWindow
+		want   int
+	}{
+		{HourWindow{Start: 8, End: 9}, 60},
+		{HourWindow{Start: 9, End: 9}, 20},
+		{HourWindow{Start: 5, End: 7}, 25}, // all zero: the daily max
+		{HourWindow{Start: 16, End: 17}, 30},
+		{HourWindow{Start: 18, End: 20}, 80},
+		{HourWindow{Start: 11, End: 14}, 25}, // no hourly values
+	}
+	for _, tt := range tests {
+		if got := windowProb(day, tt.window, opts); got != tt.want {
+			t.Errorf("windowProb(%d-%d) = %d, want %d", tt.window.Start, tt.window.End, got, tt.want)
+		}
+	}
+}
+
+func TestRainyDayThreshold(t *testing.T) {
+	day := func(prob int, mm float64, hasMM bool) weather.RainForecast {
+		return weather.RainForecast{PrecipProb: prob, PrecipMM: mm, HasPrecipMM: hasMM}
+	}
+	tests := []struct {
+		name      string
+		threshold RainyDayThreshold
+		day       weather.RainForecast
+		want      bool
+	}{
+		{"below the probability", RainyDayThreshold{Prob: 40}, day(39, 5, true), false},
+		{"at the probability", RainyDayThreshold{Prob: 40}, day(40, 0, true), true},
+		{"above the probability", RainyDayThreshold{Prob: 40}, day(90, 0, true), true},
+		{"a dry 0%", RainyDayThreshold{Prob: 40}, day(0, 0, true), false},
+		{"below the amount", RainyDayThreshold{MM: 1}, day(100, 0.9, true), false},
+		{"at the amount", RainyDayThreshold{MM: 1}, day(0, 1, true), true},
+		{"amount missing", RainyDayThreshold{MM: 1}, day(100, 0, false), false},
+		{"either: probability", RainyDayThreshold{Prob: 60, MM: 2}, day(70, 0.2, true), true},
+		{"either: amount", RainyDayThreshold{Prob: 60, MM: 2}, day(30, 2.5, true), true},
+		{"either: neither", RainyDayThreshold{Prob: 60, MM: 2}, day(59, 1.9, true), false},
+	}
+	for _, tt := range tests {
+		if got := tt.threshold.rainy(tt.day); got != tt.want {
+			t.Errorf("%s: rainy = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRainyDayThreshold(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    RainyDayThreshold
+		wantErr bool
+	}{
+		{"40%", RainyDayThreshold{Prob: 40}, false},
+		{"1mm", RainyDayThreshold{MM: 1}, false},
+		{"40%, 0.5mm", RainyDayThreshold{Prob: 40, MM: 0.5}, false},
+		{"", RainyDayThreshold{}, false},
+		{"0%", RainyDayThreshold{}, true},
+		{"101%", RainyDayThreshold{}, true},
+		{"0mm", RainyDayThreshold{}, true},
+		{"40", RainyDayThreshold{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRainyDayThreshold(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRainyDayThreshold(%q) = %+v, %v, want %+v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWindowSnow(t *testing.T) {
+	// Precipitation includes snowfall, as its water equivalent
+	day := func(cm, mm float64) weather.RainForecast {
+		return weather.RainForecast{MorningStartHour: 8, MorningSnowCM: []float64{cm}, MorningRainMM: []float64{mm}}
+	}
+	tests := []struct {
+		name string
+		day  weather.RainForecast
+		want bool
+	}{
+		{"all snow", day(1, 1.43), true},
+		{"heavy snow, all of it", day(7, 10), true},
+		{"half snow", day(0.7, 2), true},
+		{"mostly rain", day(0.7, 2.1), false},
+		{"below the threshold", day(0.4, 0.57), false},
+		{"no precipitation figure", day(1, 0), true},
+		{"rain only", day(0, 3), false},
+	}
+	opts := rainOptions{snowCM: 0.5}
+	for _, tt := range tests {
+		if _, got := windowSnow(tt.day, HourWindow{Start: 8, End: 8}, opts); got != tt.want {
+			t.Errorf("%s: snow = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/metrics"
+)
+
+// serveHTTP runs an HTTP server on addr until ctx is cancelled, then shuts it down gracefully.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("http server on %s: %w", addr, err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("shutdown http server on %s: %w", addr, err)
+		}
+		return nil
+	}
+}
+
+// httpHandlers groups the enabled HTTP endpoints by listen address, so that
+// endpoints configured on the same address share a single server.
+func (a *Agent) httpHandlers() map[string]*http.ServeMux {
+	muxes := make(map[string]*http.ServeMux)
+	muxFor := func(addr string) *http.ServeMux {
+		if mux, ok := muxes[addr]; ok {
+			return mux
+		}
+		mux := http.NewServeMux()
+		muxes[addr] = mux
+		return mux
+	}
+
+	if a.cfg.MetricsAddr != "" {
+		muxFor(a.cfg.MetricsAddr).Handle("/metrics", metrics.Handler())
+	}
+	if a.cfg.HealthAddr != "" {
+		mux := muxFor(a.cfg.HealthAddr)
+		mux.HandleFunc("/healthz", a.handleHealthz)
+		mux.HandleFunc("/readyz", a.handleReadyz)
+		mux.HandleFunc("GET /forecast", a.handleForecast)
+		mux.HandleFunc("GET /status", a.handleStatus)
+	}
+	return muxes
+}
+
+// handleHealthz reports liveness: always OK while the process is serving.
+func (a *Agent) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// handleReadyz reports OK only once every check has succeeded at least once.
+func (a *Agent) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	var pending []string
+	for i, chk := range a.cfg.Checks {
+		if !a.ready[i].Load() {
+			pending = append(pending, fmt.Sprintf("%s %s", chk.Name, chk.Type))
+		}
+	}
+	if len(pending) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprintf(w, "not ready: %s\n", strings.Join(pending, ", "))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ready\n"))
+}
+
+// handleStatus serves GET /status: Status as a JSON array, one entry per
+// check. Nothing is fetched.
+func (a *Agent) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.Status()); err != nil {
+		a.log.Debug("write status response", "err", err)
+	}
+}
+
+// handleForecast serves GET /forecast?type=wind|rain, optionally with
+// &check=<name>: a JSON array of freshly computed reports (WindReport or
+// RainReport) for the matching checks. Fetches go through each check's
+// weather cache, so frequent polling doesn't reach Open-Meteo every time.
+func (a *Agent) handleForecast(w http.ResponseWriter, r *http.Request) {
+	typ := CheckType(r.URL.Query().Get("type"))
+	if typ != CheckWind && typ != CheckRain {
+		http.Error(w, fmt.Sprintf("unknown type %q (want wind or rain)", typ), http.StatusBadRequest)
+		return
+	}
+	name := r.URL.Query().Get("check")
+
+	reports := []any{}
+	for _, chk := range a.cfg.Checks {
+		if chk.Type != typ || (name != "" && chk.Name != name) {
+			continue
+		}
+		var report any
+		var err error
+		if typ == CheckWind {
+			report, err = a.WindReport(r.Context(), chk.Name)
+		} else {
+			report, err = a.RainReport(r.Context(), chk.Name)
+		}
+		if err != nil {
+			a.log.Warn("forecast request failed", "check", chk.Type, "location", chk.Name, "err", err)
+			http.Error(w, fmt.Sprintf("%s: %v", chk.Name, err), http.StatusServiceUnavailable)
+			return
+		}
+		reports = append(reports, report)
+	}
+	if name != "" && len(reports) == 0 {
+		http.Error(w, fmt.Sprintf("no %s check named %q", typ, name), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reports); err != nil {
+		a.log.Debug("write forecast response", "err", err)
+	}
+}
@@ -0,0 +1,36 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+// DefaultSparklineRamp is the characters of a rain sparkline, driest first.
+const DefaultSparklineRamp = "▁▂▃▄▅▆▇█"
+
+// sparkline maps each day's rain probability onto ramp, 0% to its first
+// character and 100% to its last, e.g. "▁▃█▅▂▁▁". It is empty for no days.
+func sparkline(days []weather.RainForecast, ramp string) string {
+	chars := []rune(ramp)
+	if len(chars) == 0 {
+		chars = []rune(DefaultSparklineRamp)
+	}
+	var b strings.Builder
+	for _, d := range days {
+		p := min(max(d.PrecipProb, 0), 100)
+		b.WriteRune(chars[(p*(len(chars)-1)+50)/100])
+	}
+	return b.String()
+}
+
+// sparklineLine heads the rain table with the sparkline and the days it
+// spans: "Rain ▁▃█▅▂▁▁ Mon–Sun". It is empty for no days.
+func sparklineLine(days []weather.RainForecast, ramp string) string {
+	if len(days) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Rain %s %s–%s\n", sparkline(days, ramp),
+		days[0].Date.Format("Mon"), days[len(days)-1].Date.Format("Mon"))
+}
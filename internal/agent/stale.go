@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+// lastGood keeps each check's most recent successfully fetched forecast, so
+// a failed fetch can fall back to it (see Config.MaxStaleAge).
+type lastGood struct {
+	mu   sync.Mutex
+	wind map[int]goodForecast[weather.ForecastDay]
+	rain map[int]goodForecast[weather.RainForecast]
+}
+
+type goodForecast[T any] struct {
+	days      []T
+	fetchedAt time.Time
+}
+
+func (l *lastGood) putWind(i int, days []weather.ForecastDay, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.wind == nil {
+		l.wind = make(map[int]goodForecast[weather.ForecastDay])
+	}
+	l.wind[i] = goodForecast[weather.ForecastDay]{days: days, fetchedAt: at}
+}
+
+func (l *lastGood) putRain(i int, days []weather.RainForecast, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rain == nil {
+		l.rain = make(map[int]goodForecast[weather.RainForecast])
+	}
+	l.rain[i] = goodForecast[weather.RainForecast]{days: days, fetchedAt: at}
+}
+
+// staleWind returns check i's last good wind forecast if it is no older than
+// maxAge at now. A maxAge of 0 disables the fallback.
+func (l *lastGood) staleWind(i int, now time.Time, maxAge time.Duration) (goodForecast[weather.ForecastDay], bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return notTooStale(l.wind, i, now, maxAge)
+}
+
+// staleRain is staleWind for rain checks.
+func (l *lastGood) staleRain(i int, now time.Time, maxAge time.Duration) (goodForecast[weather.RainForecast], bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return notTooStale(l.rain, i, now, maxAge)
+}
+
+func notTooStale[T any](m map[int]goodForecast[T], i int, now time.Time, maxAge time.Duration) (goodForecast[T], bool) {
+	g, ok := m[i]
+	if !ok || maxAge <= 0 || len(g.days) == 0 || now.Sub(g.fetchedAt) > maxAge {
+		return goodForecast[T]{}, false
+	}
+	return g, true
+}
+
+// fromToday drops the days before now's date, which a forecast fetched
+// yesterday still starts with.
+func fromToday[T any](days []T, date func(T) time.Time, now time.Time) []T {
+	for i, d := range days {
+		day := date(d)
+		if !day.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, day.Location())) {
+			return days[i:]
+		}
+	}
+	return nil
+}
+
+// staleNote heads a message built from a fallback forecast, with the fetch
+// time in loc: "⚠️ Stale forecast, fetched at 09:12 (latest fetch failed)".
+func staleNote(fetchedAt, now time.Time, loc *time.Location) string {
+	at := fetchedAt.In(loc)
+	layout := "15:04"
+	if !sameDay(at, now.In(loc)) {
+		layout = "Mon 02 Jan 15:04"
+	}
+	return fmt.Sprintf("⚠️ Stale forecast, fetched at %s (latest fetch failed)\n", at.Format(layout))
+}
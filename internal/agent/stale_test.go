@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/notify"
+)
+
+func TestStaleFallback(t *testing.T) {
+	fetchedAt := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		fail      bool
+		after     time.Duration // since the good fetch
+		maxAge    time.Duration
+		wantSent  bool
+		wantStale string // "" for a fresh message
+		wantErr   bool
+	}{
+		{name: "fresh", after: time.Hour, maxAge: 3 * time.Hour, wantSent: true},
+		{name: "stale within the limit", fail: true, after: time.Hour, maxAge: 3 * time.Hour, wantSent: true, wantStale: "⚠️ Stale forecast, fetched at 08:00", wantErr: true},
+		{name: "stale at the limit", fail: true, after: 3 * time.Hour, maxAge: 3 * time.Hour, wantSent: true, wantStale: "fetched at 08:00", wantErr: true},
+		{name: "stale the next day", fail: true, after: 20 * time.Hour, maxAge: 24 * time.Hour, wantSent: true, wantStale: "fetched at Fri 16 Oct 08:00", wantErr: true},
+		{name: "too stale", fail: true, after: 3*time.Hour + time.Minute, maxAge: 3 * time.Hour, wantErr: true},
+		{name: "fallback off", fail: true, after: time.Minute, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &fakeWeather{wind: windDays(20, 90, 90, 270)}
+			clock := &fakeClock{now: fetchedAt}
+			rec := &recordingNotifier{}
+			a := newTestAgent(t, Config{
+				Checks:      []Check{{Name: "Heathrow", Type: CheckWind, Weather: src}},
+				Clock:       clock,
+				MaxStaleAge: tt.maxAge,
+				Notifiers:   []notify.Notifier{rec},
+			})
+			if err := a.doCheck(context.Background(), 0); err != nil {
+				t.Fatal(err)
+			}
+
+			clock.now = fetchedAt.Add(tt.after)
+			if tt.fail {
+				src.err = errors.New("open-meteo returned 503 Service Unavailable")
+			}
+			err := a.doCheck(context.Background(), 0)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+
+			sent := rec.sent()
+			if got := len(sent) == 2; got != tt.wantSent {
+				t.Fatalf("sent %d messages after the first, want a second: %v", len(sent)-1, tt.wantSent)
+			}
+			if !tt.wantSent {
+				return
+			}
+			switch {
+			case tt.wantStale == "" && strings.Contains(sent[1], "Stale"):
+				t.Errorf("fresh message marked stale:\n%s", sent[1])
+			case tt.wantStale != "" && !strings.Contains(sent[1], tt.wantStale):
+				t.Errorf("message lacks %q:\n%s", tt.wantStale, sent[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// runState persists when each check last succeeded, so a restart can skip
+// startup runs that would repeat a recent message, and with
+// Config.ShowForecastChanges its last report. A nil *runState (no state file
+// configured) remembers nothing.
+type runState struct {
+	path string
+
+	mu      sync.Mutex
+	lastRun map[string]time.Time
+	reports map[string]savedReport
+}
+
+// stateFile is the on-disk format of runState.
+type stateFile struct {
+	LastRun map[string]time.Time   `json:"last_run"`
+	Reports map[string]savedReport `json:"reports,omitempty"`
+}
+
+// savedReport is a check's last report, the baseline its next run's
+// forecast changes are found against.
+type savedReport struct {
+	At   time.Time   `json:"at"`
+	Wind *WindReport `json:"wind,omitempty"`
+	Rain *RainReport `json:"rain,omitempty"`
+}
+
+// loadRunState reads path, treating a missing file as empty state.
+func loadRunState(path string) (*runState, error) {
+	s := &runState{path: path, lastRun: make(map[string]time.Time), reports: make(map[string]savedReport)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("read state file: %w", err)
+	}
+
+	var f stateFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return s, fmt.Errorf("parse state file %s: %w", path, err)
+	}
+	for k, v := range f.LastRun {
+		s.lastRun[k] = v
+	}
+	for k, v := range f.Reports {
+		s.reports[k] = v
+	}
+	return s, nil
+}
+
+func (s *runState) last(key string) (time.Time, bool) {
+	if s == nil {
+		return time.Time{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.lastRun[key]
+	return t, ok
+}
+
+// record stores t as key's last run and rewrites the state file.
+func (s *runState) record(key string, t time.Time) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRun[key] = t
+	return s.save()
+}
+
+func (s *runState) report(key string) (savedReport, bool) {
+	if s == nil {
+		return savedReport{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.reports[key]
+	return r, ok
+}
+
+// putReport stores r as key's last report and rewrites the state file.
+func (s *runState) putReport(key string, r savedReport) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports[key] = r
+	return s.save()
+}
+
+// save writes the state file, with s.mu held. The file is replaced
+// atomically so a crash mid-write cannot corrupt it.
+func (s *runState) save() error {
+	data, err := json.MarshalIndent(stateFile{LastRun: s.lastRun, Reports: s.reports}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".state-*")
+	if err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write state file: %w", err)
+	}
+	return nil
+}
+
+// stateKey identifies a check in the state file.
+func (c Check) stateKey() string {
+	return c.Name + "/" + string(c.Type)
+}
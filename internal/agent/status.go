@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// CheckStatus is a check's last and next run, as Agent.Status returns it.
+type CheckStatus struct {
+	Name    string
+	Type    CheckType
+	Running bool
+	// LastRun is when the last run started, and LastEnd when it finished;
+	// both are zero before the first run, and LastEnd while it is running.
+	LastRun time.Time `json:",omitzero"`
+	LastEnd time.Time `json:",omitzero"`
+	// LastError is why the last finished run failed; "" when it succeeded.
+	LastError string `json:",omitempty"`
+	// NextRun is the check's next queued run; zero when none is queued, as
+	// with Config.RunOnce.
+	NextRun time.Time `json:",omitzero"`
+}
+
+// statusBoard holds each check's CheckStatus, shared between the scheduler
+// and the checks' goroutines.
+type statusBoard struct {
+	mu     sync.Mutex
+	checks []CheckStatus // by index into Config.Checks
+}
+
+func newStatusBoard(checks []Check) *statusBoard {
+	b := &statusBoard{checks: make([]CheckStatus, len(checks))}
+	for i, chk := range checks {
+		b.checks[i] = CheckStatus{Name: chk.Name, Type: chk.Type}
+	}
+	return b
+}
+
+// start records that check i started running at now.
+func (b *statusBoard) start(i int, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &b.checks[i]
+	s.Running, s.LastRun, s.LastEnd = true, now, time.Time{}
+}
+
+// finish records that check i's run ended at now with err.
+func (b *statusBoard) finish(i int, now time.Time, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &b.checks[i]
+	s.Running, s.LastEnd, s.LastError = false, now, ""
+	if err != nil {
+		s.LastError = err.Error()
+	}
+}
+
+// scheduled records check i's next queued run; zero for none.
+func (b *statusBoard) scheduled(i int, next time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checks[i].NextRun = next
+}
+
+// Status returns every check's last and next run, in Config.Checks order,
+// without running or fetching anything.
+func (a *Agent) Status() []CheckStatus {
+	a.status.mu.Lock()
+	defer a.status.mu.Unlock()
+	return slices.Clone(a.status.checks)
+}
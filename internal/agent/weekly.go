@@ -0,0 +1,162 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/notify"
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+// weeklyDays is how many days the weekly summary looks ahead, starting tomorrow.
+const weeklyDays = 7
+
+// runWeekly sends the weekly summary on its schedule until ctx is done.
+// Failures are logged and retried at the next scheduled time.
+func (a *Agent) runWeekly(ctx context.Context) error {
+	for {
+		now := a.cfg.Clock.Now()
+		next, err := a.weekly.next(now, a.weeklyLoc)
+		if err != nil {
+			return fmt.Errorf("weekly summary: %w", err)
+		}
+		a.log.Info("weekly summary scheduled", "cron", a.cfg.WeeklySummaryCron, "next_run", next.Format(time.RFC3339))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.cfg.Clock.After(next.Sub(now)):
+		}
+
+		if skew := a.cfg.Clock.Now().Sub(next); a.tooLate(skew) {
+			a.log.Warn("weekly summary fired late, skipping it", "scheduled_for", next.Format(time.RFC3339), "skew", skew)
+			continue
+		}
+		if err := a.sendWeekly(ctx); err != nil {
+			a.log.Error("weekly summary failed", "err", err)
+		}
+	}
+}
+
+// sendWeekly builds the summary across all checks and sends it as its own
+// message, bypassing the daily digest.
+func (a *Agent) sendWeekly(ctx context.Context) error {
+	now := a.cfg.Clock.Now()
+	var lines []string
+	var errs []error
+	for _, chk := range a.cfg.Checks {
+		var line string
+		var err error
+		switch chk.Type {
+		case CheckWind:
+			line, err = a.weeklyWind(ctx, chk, now)
+		case CheckRain:
+			line, err = a.weeklyRain(ctx, chk, now)
+		}
+		if err != nil {
+			a.log.Warn("weekly summary: fetch failed", "check", chk.Type, "location", chk.Name, "err", err)
+			errs = append(errs, fmt.Errorf("%s %s: %w", chk.Name, chk.Type, err))
+			line = fmt.Sprintf("⚠️ %s data unavailable", chk.Type)
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s", chk.icon(), chk.Name, line))
+	}
+	if len(errs) == len(a.cfg.Checks) {
+		return errors.Join(errs...)
+	}
+
+	msg := "🗓️ This week\n" + strings.Join(lines, "\n")
+	return a.notify(ctx, notify.Text(msg))
+}
+
+func (a *Agent) weeklyWind(ctx context.Context, chk Check, now time.Time) (string, error) {
+	forecast, err := limitedFetch(ctx, a, chk.Weather.Fetch, weeklyDays+1)
+	if err != nil {
+		return "", err
+	}
+	week := nextWeek(upcomingDays(forecast), func(d weather.ForecastDay) time.Time { return d.Date }, now)
+
+	var easterly, gusty []string
+	flags := easterlyDays(week, a.cfg.DirectionHysteresis)
+	for i, d := range week {
+		if flags[i] {
+			easterly = append(easterly, d.Date.Format("Mon"))
+		}
+		if isGusty(d, a.cfg.GustThreshold) {
+			gusty = append(gusty, d.Date.Format("Mon"))
+		}
+	}
+	return countDays(len(easterly), "easterly wind day") + dayList(easterly) +
+		", " + countDays(len(gusty), "gusty day") + dayList(gusty), nil
+}
+
+func (a *Agent) weeklyRain(ctx context.Context, chk Check, now time.Time) (string, error) {
+	forecast, err := limitedFetch(ctx, a, chk.Weather.FetchRain, weeklyDays+1)
+	if err != nil {
+		return "", err
+	}
+	week := nextWeek(forecast, func(d weather.RainForecast) time.Time { return d.Date }, now)
+	opts := a.rainOptions(ctx)
+
+	var rainy, mornings, pickups []string
+	for _, d := range week {
+		if opts.rainyDay.rainy(d) {
+			rainy = append(rainy, d.Date.Format("Mon"))
+		}
+		sd, ok := opts.schedule[d.Date.Weekday()]
+		if !ok || inRanges(opts.holidays, d.Date) {
+			continue
+		}
+		// A day counts once however many of its windows are wet
+		wetMorning, wetPickup := false, false
+		for _, w := range sd.windows() {
+			if windowProb(d, w.HourWindow, opts) < opts.maybe {
+				continue
+			}
+			if w.Pickup {
+				wetPickup = true
+			} else {
+				wetMorning = true
+			}
+		}
+		if wetMorning {
+			mornings = append(mornings, d.Date.Format("Mon"))
+		}
+		if wetPickup {
+			pickups = append(pickups, d.Date.Format("Mon"))
+		}
+	}
+	return countDays(len(rainy), "rainy day") + dayList(rainy) +
+		", " + countDays(len(mornings), "rainy school-run morning") + dayList(mornings) +
+		", " + countDays(len(pickups), "rainy pickup") + dayList(pickups), nil
+}
+
+// nextWeek drops today, the day of now, and keeps at most weeklyDays
+// following days.
+func nextWeek[T any](days []T, date func(T) time.Time, now time.Time) []T {
+	if len(days) > 0 && sameDay(date(days[0]), now.In(date(days[0]).Location())) {
+		days = days[1:]
+	}
+	return days[:min(len(days), weeklyDays)]
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+// countDays formats n with a noun, pluralised: "1 gusty day", "3 gusty days".
+func countDays(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func dayList(days []string) string {
+	if len(days) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(days, ", ") + ")"
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+func TestNextWeekEastOfUTC(t *testing.T) {
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+	var days []weather.ForecastDay
+	for i := range 9 {
+		days = append(days, weather.ForecastDay{Date: time.Date(2026, 10, 16+i, 0, 0, 0, 0, tokyo)})
+	}
+	date := func(d weather.ForecastDay) time.Time { return d.Date }
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want int // day of October the week starts on
+	}{
+		// Still 15 October in UTC, but already the first forecast day in Tokyo
+		{"early morning in Tokyo", time.Date(2026, 10, 15, 15, 30, 0, 0, time.UTC), 17},
+		{"evening in Tokyo", time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), 17},
+		{"the day before in Tokyo", time.Date(2026, 10, 15, 14, 30, 0, 0, time.UTC), 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextWeek(days, date, tt.now)
+			if len(got) != weeklyDays {
+				t.Fatalf("got %d days, want %d", len(got), weeklyDays)
+			}
+			if d := got[0].Date; d.Day() != tt.want {
+				t.Errorf("week starts %s, want %d October", d.Format("Mon 02 Jan"), tt.want)
+			}
+		})
+	}
+}
+
+func TestWeeklyRainCountsRainyDays(t *testing.T) {
+	// Fri 16 October, today, then Sat-Fri with a mix of daily probabilities
+	var days []weather.RainForecast
+	for i, prob := range []int{90, 0, 39, 40, 85, 10, 60, 20} {
+		days = append(days, weather.RainForecast{Date: time.Date(2026, 10, 16+i, 0, 0, 0, 0, time.UTC), PrecipProb: prob})
+	}
+	chk := Check{Name: "Twickenham", Type: CheckRain, Weather: &fakeWeather{rain: days}}
+	now := time.Date(2026, 10, 16, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		threshold RainyDayThreshold
+		want      string
+	}{
+		{RainyDayThreshold{}, "3 rainy days (Mon, Tue, Thu)"}, // default 40%
+		{RainyDayThreshold{Prob: 10}, "6 rainy days (Sun, Mon, Tue, Wed, Thu, Fri)"},
+		{RainyDayThreshold{Prob: 90}, "0 rainy days"},
+	}
+	for _, tt := range tests {
+		a := newTestAgent(t, Config{Checks: []Check{chk}, RainyDayThreshold: tt.threshold})
+		got, err := a.weeklyRain(context.Background(), chk, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(got, tt.want+",") {
+			t.Errorf("threshold %+v: %q, want it to start with %q", tt.threshold, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+// Package config loads the agent's settings from a YAML or JSON file, with
+// the environment variables of the same names overriding it.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v2"
+
+	"github.com/emanuelefumagalli/test-agent/internal/agent"
+)
+
+// File is the schema of a config file. Each key is the lower-case name of
+// the environment variable it stands in for, takes the same values (see the
+// README for defaults) and is overridden by it when that is set. Secrets and
+// connection settings, such as TELEGRAM_TOKEN or OLLAMA_HOST, stay in the
+// environment. For example:
+//
+//	gust_threshold: 45
+//	wind_cron: "0 7 * * *"
+//	rain_summary: false
+//	school_holidays: 2025-12-20:2026-01-04
+//	quiet_start: "22:00"
+//	quiet_end: "07:00"
+type File struct {
+	// Wind check
+	ForecastDays        *int     `json:"forecast_days" yaml:"forecast_days"`
+	WindCron            string   `json:"wind_cron" yaml:"wind_cron"`
+	GustThreshold       *float64 `json:"gust_threshold" yaml:"gust_threshold"`
+	WindSpeedAlert      *float64 `json:"wind_speed_alert" yaml:"wind_speed_alert"`
+	DirectionHysteresis *float64 `json:"direction_hysteresis" yaml:"direction_hysteresis"`
+	DominantMargin      *int     `json:"dominant_margin" yaml:"dominant_margin"`
+	CompassResolution   *int     `json:"compass_resolution" yaml:"compass_resolution"`
+	DirectionStyle      string   `json:"direction_style" yaml:"direction_style"`
+	ShowOverheadScore   *bool    `json:"show_overhead_score" yaml:"show_overhead_score"`
+	OverheadAlert       *int     `json:"overhead_alert" yaml:"overhead_alert"`
+
+	// Rain check
+	RainCron              string   `json:"rain_cron" yaml:"rain_cron"`
+	SchoolHolidays        string   `json:"school_holidays" yaml:"school_holidays"`
+	SchoolHolidayICalURL  string   `json:"school_holiday_ical_url" yaml:"school_holiday_ical_url"`
+	RainMaybeThreshold    *int     `json:"rain_maybe_threshold" yaml:"rain_maybe_threshold"`
+	RainDefiniteThreshold *int     `json:"rain_definite_threshold" yaml:"rain_definite_threshold"`
+	RainLightMM           *float64 `json:"rain_light_mm" yaml:"rain_light_mm"`
+	RainHeavyMM           *float64 `json:"rain_heavy_mm" yaml:"rain_heavy_mm"`
+	RainPrimaryMetric     string   `json:"rain_primary_metric" yaml:"rain_primary_metric"`
+	RainAggregation       string   `json:"rain_aggregation" yaml:"rain_aggregation"`
+	RainSustainedHours    *int     `json:"rain_sustained_hours" yaml:"rain_sustained_hours"`
+	SnowThresholdCM       *float64 `json:"snow_threshold_cm" yaml:"snow_threshold_cm"`
+	RainTodayHourly       *bool    `json:"rain_today_hourly" yaml:"rain_today_hourly"`
+	RainyDayThreshold     string   `json:"rainy_day_threshold" yaml:"rainy_day_threshold"`
+	ShowSparkline         *bool    `json:"show_sparkline" yaml:"show_sparkline"`
+	SparklineRamp         string   `json:"sparkline_ramp" yaml:"sparkline_ramp"`
+	ShowDryMorning        *bool    `json:"show_dry_morning" yaml:"show_dry_morning"`
+	WeeklySummaryCron     string   `json:"weekly_summary_cron" yaml:"weekly_summary_cron"`
+
+	// Summaries and message text
+	WindSummary          *bool  `json:"wind_summary" yaml:"wind_summary"`
+	RainSummary          *bool  `json:"rain_summary" yaml:"rain_summary"`
+	OllamaTimeout        string `json:"ollama_timeout" yaml:"ollama_timeout"`
+	SummaryLanguage      string `json:"summary_language" yaml:"summary_language"`
+	SanitizeSummary      *bool  `json:"sanitize_summary" yaml:"sanitize_summary"`
+	WindPromptTemplate   string `json:"wind_prompt_template" yaml:"wind_prompt_template"`
+	RainPromptTemplate   string `json:"rain_prompt_template" yaml:"rain_prompt_template"`
+	NoEasterlyTemplate   string `json:"no_easterly_template" yaml:"no_easterly_template"`
+	ManyEasterlyTemplate string `json:"many_easterly_template" yaml:"many_easterly_template"`
+	Markers              string `json:"markers" yaml:"markers"`
+	CompactTable         *bool  `json:"compact_table" yaml:"compact_table"`
+	TableWidth           *int   `json:"table_width" yaml:"table_width"`
+	ShowForecastChanges  *bool  `json:"show_forecast_changes" yaml:"show_forecast_changes"` // needs state_file
+
+	// Delivery
+	DigestMode    *bool  `json:"digest_mode" yaml:"digest_mode"`
+	FlushTimeout  string `json:"flush_timeout" yaml:"flush_timeout"`
+	QuietStart    string `json:"quiet_start" yaml:"quiet_start"`
+	QuietEnd      string `json:"quiet_end" yaml:"quiet_end"`
+	QuietTimezone string `json:"quiet_timezone" yaml:"quiet_timezone"`
+
+	// Running
+	SkipStartupRun   *bool  `json:"skip_startup_run" yaml:"skip_startup_run"`
+	StartupJitter    string `json:"startup_jitter" yaml:"startup_jitter"`
+	ScheduleSkew     string `json:"max_schedule_skew" yaml:"max_schedule_skew"`
+	StateFile        string `json:"state_file" yaml:"state_file"`
+	MinRunInterval   string `json:"min_run_interval" yaml:"min_run_interval"`
+	HistoryDB        string `json:"history_db" yaml:"history_db"`
+	MaxStaleAge      string `json:"max_stale_age" yaml:"max_stale_age"`
+	FetchConcurrency *int   `json:"fetch_concurrency" yaml:"fetch_concurrency"`
+	MetricsAddr      string `json:"metrics_addr" yaml:"metrics_addr"`
+	HealthAddr       string `json:"health_addr" yaml:"health_addr"`
+	LogFormat        string `json:"log_format" yaml:"log_format"`
+	LogLevel         string `json:"log_level" yaml:"log_level"`
+}
+
+// Load reads the config file at path, a .yaml, .yml or .json file, and
+// returns the agent config it and the environment describe; an empty path
+// reads the environment alone. The returned checks, the wind check then the
+// rain check, carry only their schedules: the caller supplies each one's
+// Name and Weather, and the rain check's Timezone. Notifiers and the Ollama
+// client are left for the caller too.
+//
+// Errors name the offending key. An invalid numeric, boolean or duration
+// environment variable is logged and ignored instead, as it always has been.
+func Load(path string) (agent.Config, error) {
+	s := source{file: map[string]string{}}
+	if path != "" {
+		f, err := readFile(path)
+		if err != nil {
+			return agent.Config{}, err
+		}
+		s.file = f.values()
+		s.path = path
+	}
+	return s.config()
+}
+
+// readFile decodes path strictly: unknown keys and mistyped values are
+// errors.
+func readFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	var f File
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &f)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&f)
+	default:
+		return nil, fmt.Errorf("config %s: unknown format %q (want .yaml, .yml or .json)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// values returns the keys f sets, by environment variable name, in the
+// variables' string form.
+func (f *File) values() map[string]string {
+	out := make(map[string]string)
+	v := reflect.ValueOf(f).Elem()
+	for i := range v.NumField() {
+		key := strings.ToUpper(v.Type().Field(i).Tag.Get("yaml"))
+		switch field := v.Field(i); {
+		case field.Kind() == reflect.Pointer && !field.IsNil():
+			out[key] = fmt.Sprint(field.Elem().Interface())
+		case field.Kind() == reflect.String && field.String() != "":
+			out[key] = field.String()
+		}
+	}
+	return out
+}
+
+// source looks settings up in the environment, then the file.
+type source struct {
+	path string
+	file map[string]string
+	errs []error
+}
+
+// lookup returns key's value and whether it came from the file.
+func (s *source) lookup(key string) (value string, fromFile bool) {
+	if v := os.Getenv(key); v != "" {
+		return v, false
+	}
+	return s.file[key], true
+}
+
+// scalar parses key with fn. An invalid environment variable is logged and
+// the file's value used instead; an invalid file value is an error.
+func scalar[T any](s *source, key string, fallback T, fn func(string) (T, error)) T {
+	if v := os.Getenv(key); v != "" {
+		out, err := fn(v)
+		if err == nil {
+			return out
+		}
+		slog.Warn("invalid env var, ignoring it", "key", key, "value", v, "err", err)
+	}
+	if v := s.file[key]; v != "" {
+		out, err := fn(v)
+		if err == nil {
+			return out
+		}
+		s.errs = append(s.errs, fmt.Errorf("config %s: %s: %w", s.path, strings.ToLower(key), err))
+	}
+	return fallback
+}
+
+func (s *source) string(key, fallback string) string {
+	if v, _ := s.lookup(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func (s *source) int(key string, fallback int) int {
+	return scalar(s, key, fallback, strconv.Atoi)
+}
+
+func (s *source) float(key string, fallback float64) float64 {
+	return scalar(s, key, fallback, func(v string) (float64, error) { return strconv.ParseFloat(v, 64) })
+}
+
+func (s *source) bool(key string, fallback bool) bool {
+	return scalar(s, key, fallback, strconv.ParseBool)
+}
+
+func (s *source) duration(key string, fallback time.Duration) time.Duration {
+	return scalar(s, key, fallback, time.ParseDuration)
+}
+
+// parse parses key with fn; unlike the scalars, a bad value is an error
+// wherever it came from, as the agent has no sensible fallback for it.
+func parse[T any](s *source, key string, fn func(string) (T, error)) T {
+	v, fromFile := s.lookup(key)
+	out, err := fn(v)
+	if err != nil {
+		if fromFile && v != "" {
+			s.errs = append(s.errs, fmt.Errorf("config %s: %s: %w", s.path, strings.ToLower(key), err))
+		} else {
+			s.errs = append(s.errs, fmt.Errorf("invalid %s: %w", key, err))
+		}
+	}
+	return out
+}
+
+func (s *source) config() (agent.Config, error) {
+	cfg := agent.Config{
+		Checks: []agent.Check{
+			{
+				// Wind check at 10am UTC, plus once on startup
+				Type:       agent.CheckWind,
+				Days:       s.int("FORECAST_DAYS", 0), // 15, or the provider's horizon if shorter
+				Hour:       10,
+				Timezone:   "UTC",
+				Cron:       s.string("WIND_CRON", ""),
+				RunOnStart: true,
+			},
+			{
+				// Rain check at 7:30am local time
+				Type:   agent.CheckRain,
+				Days:   7,
+				Hour:   7,
+				Minute: 30,
+				Cron:   s.string("RAIN_CRON", ""),
+			},
+		},
+
+		GustThreshold:       s.float("GUST_THRESHOLD", 40),
+		WindSpeedAlert:      s.float("WIND_SPEED_ALERT", 0),
+		DirectionHysteresis: s.float("DIRECTION_HYSTERESIS", 0),
+		DominantMargin:      s.int("DOMINANT_MARGIN", 0),
+		CompassResolution:   s.int("COMPASS_RESOLUTION", 2),
+		DirectionStyle:      s.string("DIRECTION_STYLE", agent.DirectionLetters),
+		ShowOverheadScore:   s.bool("SHOW_OVERHEAD_SCORE", false),
+		OverheadAlert:       s.int("OVERHEAD_ALERT", 0),
+
+		SchoolHolidays:           parse(s, "SCHOOL_HOLIDAYS", agent.ParseDateRanges),
+		SchoolHolidayCalendarURL: s.string("SCHOOL_HOLIDAY_ICAL_URL", ""),
+		RainMaybeThreshold:       s.int("RAIN_MAYBE_THRESHOLD", 30),
+		RainDefiniteThreshold:    s.int("RAIN_DEFINITE_THRESHOLD", 70),
+		RainLightMM:              s.float("RAIN_LIGHT_MM", 0.5),
+		RainHeavyMM:              s.float("RAIN_HEAVY_MM", 4),
+		RainPrimaryMetric:        s.string("RAIN_PRIMARY_METRIC", ""),
+		RainAggregation:          s.string("RAIN_AGGREGATION", ""),
+		RainSustainedHours:       s.int("RAIN_SUSTAINED_HOURS", 2),
+		SnowThresholdCM:          s.float("SNOW_THRESHOLD_CM", 0.2),
+		RainTodayHourly:          s.bool("RAIN_TODAY_HOURLY", false),
+		RainyDayThreshold:        parse(s, "RAINY_DAY_THRESHOLD", agent.ParseRainyDayThreshold),
+		ShowSparkline:            s.bool("SHOW_SPARKLINE", false),
+		SparklineRamp:            s.string("SPARKLINE_RAMP", ""),
+		ShowDryMorning:           s.bool("SHOW_DRY_MORNING", false),
+
+		DisableWindSummary: !s.bool("WIND_SUMMARY", true),
+		DisableRainSummary: !s.bool("RAIN_SUMMARY", true),
+		OllamaTimeout:      s.duration("OLLAMA_TIMEOUT", 5*time.Minute),
+		FlushTimeout:       s.duration("FLUSH_TIMEOUT", 10*time.Second),
+		SummaryLanguage:    s.string("SUMMARY_LANGUAGE", ""),
+		SanitizeSummary:    s.bool("SANITIZE_SUMMARY", false),
+
+		MetricsAddr: s.string("METRICS_ADDR", ""),
+		HealthAddr:  s.string("HEALTH_ADDR", ""),
+		DigestMode:  s.bool("DIGEST_MODE", false),
+		LogFormat:   s.string("LOG_FORMAT", ""),
+		LogLevel:    s.string("LOG_LEVEL", ""),
+
+		QuietTimezone: s.string("QUIET_TIMEZONE", ""),
+
+		WeeklySummaryCron:     s.weeklySummaryCron(),
+		WeeklySummaryTimezone: "Europe/London",
+
+		SkipImmediateRun: s.bool("SKIP_STARTUP_RUN", false),
+		StartupJitter:    s.duration("STARTUP_JITTER", 0),
+		MaxScheduleSkew:  s.duration("MAX_SCHEDULE_SKEW", 0),
+		StateFile:        s.string("STATE_FILE", ""),
+		MinRunInterval:   s.duration("MIN_RUN_INTERVAL", 0),
+		HistoryDB:        s.string("HISTORY_DB", ""),
+		MaxStaleAge:      s.duration("MAX_STALE_AGE", 0),
+		FetchConcurrency: s.int("FETCH_CONCURRENCY", 0),
+
+		ShowForecastChanges: s.bool("SHOW_FORECAST_CHANGES", false),
+
+		WindPromptTemplate: s.string("WIND_PROMPT_TEMPLATE", ""),
+		RainPromptTemplate: s.string("RAIN_PROMPT_TEMPLATE", ""),
+
+		NoEasterlyTemplate:   s.string("NO_EASTERLY_TEMPLATE", ""),
+		ManyEasterlyTemplate: s.string("MANY_EASTERLY_TEMPLATE", ""),
+		Markers:              parse(s, "MARKERS", agent.ParseMarkers),
+		CompactTable:         s.bool("COMPACT_TABLE", false),
+		TableWidth:           s.int("TABLE_WIDTH", 0),
+	}
+	cfg.QuietStart, cfg.QuietEnd = s.quietHours()
+	return cfg, errors.Join(s.errs...)
+}
+
+// quietHours reads QUIET_START and QUIET_END ("22:00"); neither set means
+// no quiet hours.
+func (s *source) quietHours() (start, end agent.TimeOfDay) {
+	if v, _ := s.lookup("QUIET_START"); v == "" {
+		if v, _ := s.lookup("QUIET_END"); v == "" {
+			return start, end
+		}
+	}
+	return parse(s, "QUIET_START", agent.ParseTimeOfDay), parse(s, "QUIET_END", agent.ParseTimeOfDay)
+}
+
+// weeklySummaryCron defaults the weekly summary to Sunday 6pm; "off" disables it.
+func (s *source) weeklySummaryCron() string {
+	spec := s.string("WEEKLY_SUMMARY_CRON", "0 18 * * 0")
+	if spec == "off" {
+		return ""
+	}
+	return spec
+}
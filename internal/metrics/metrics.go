@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Upstream labels for RequestDuration.
+const (
+	UpstreamOpenMeteo      = "open-meteo"
+	UpstreamOpenWeatherMap = "openweathermap"
+	UpstreamOllama         = "ollama"
+)
+
+var (
+	// ChecksTotal counts check cycles attempted, labelled by location and check type (wind, rain).
+	ChecksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "weather_agent_checks_total",
+		Help: "Number of check cycles attempted.",
+	}, []string{"location", "type"})
+
+	// CheckFailuresTotal counts check cycles that failed, labelled by location and check type.
+	CheckFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "weather_agent_check_failures_total",
+		Help: "Number of check cycles that failed.",
+	}, []string{"location", "type"})
+
+	// LastSuccess records the unix time of the last successful cycle per location and check type.
+	LastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "weather_agent_last_success_timestamp_seconds",
+		Help: "Unix time of the last successful check cycle.",
+	}, []string{"location", "type"})
+
+	// RequestDuration tracks latency of calls to upstream APIs.
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "weather_agent_upstream_request_duration_seconds",
+		Help:    "Latency of requests to upstream APIs.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"upstream"})
+
+	// CircuitState is each upstream's circuit breaker state: 0 closed, 1
+	// half-open, 2 open.
+	CircuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "weather_agent_circuit_breaker_state",
+		Help: "Circuit breaker state per upstream: 0 closed, 1 half-open, 2 open.",
+	}, []string{"upstream"})
+
+	// BuildInfo is always 1, labelled with the running build's version, commit and build date.
+	BuildInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "weather_agent_build_info",
+		Help: "Build information of the running agent; always 1.",
+	}, []string{"version", "commit", "build_date"})
+)
+
+// ObserveRequest records the time elapsed since start against the given upstream.
+func ObserveRequest(upstream string, start time.Time) {
+	RequestDuration.WithLabelValues(upstream).Observe(time.Since(start).Seconds())
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Phase labels for RequestPhaseDuration.
+const (
+	PhaseDNS     = "dns"
+	PhaseConnect = "connect"
+	PhaseTLS     = "tls"
+	PhaseTTFB    = "ttfb"
+)
+
+// RequestPhaseDuration tracks connection phases of traced upstream requests,
+// labelled by upstream and phase. Only requests sent through TraceTransport
+// are observed, and a reused connection has no dns, connect or tls phase.
+var RequestPhaseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "weather_agent_upstream_request_phase_seconds",
+	Help:    "Duration of connection phases (dns, connect, tls, ttfb) of traced requests to upstream APIs.",
+	Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+}, []string{"upstream", "phase"})
+
+// TraceTransport wraps next (http.DefaultTransport when nil) so each request
+// records its DNS lookup, connect, TLS handshake and time to first byte in
+// RequestPhaseDuration, and logs them at debug level to logger (nil discards
+// them). Tracing is opt-in: clients that don't use this transport pay nothing.
+func TraceTransport(upstream string, next http.RoundTripper, logger *slog.Logger) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &traceTransport{upstream: upstream, next: next, logger: logger}
+}
+
+type traceTransport struct {
+	upstream string
+	next     http.RoundTripper
+	logger   *slog.Logger
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var p phases
+	start := time.Now()
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), p.trace(start)))
+
+	resp, err := t.next.RoundTrip(req)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	attrs := []any{"upstream", t.upstream, "host", req.URL.Host, "reused", p.reused}
+	for _, ph := range []struct {
+		name string
+		d    time.Duration
+	}{{PhaseDNS, p.dns}, {PhaseConnect, p.connect}, {PhaseTLS, p.tls}, {PhaseTTFB, p.ttfb}} {
+		if ph.d <= 0 {
+			continue // phase didn't happen, e.g. on a reused connection
+		}
+		RequestPhaseDuration.WithLabelValues(t.upstream, ph.name).Observe(ph.d.Seconds())
+		attrs = append(attrs, ph.name, ph.d)
+	}
+	if err != nil {
+		attrs = append(attrs, "err", err)
+	}
+	t.logger.Debug("upstream request timing", attrs...)
+	return resp, err
+}
+
+// phases collects one request's timings. The trace hooks may fire from the
+// transport's own goroutines, so every field is guarded by mu.
+type phases struct {
+	mu                            sync.Mutex
+	dnsStart, connStart, tlsStart time.Time
+	dns, connect, tls, ttfb       time.Duration
+	reused                        bool
+}
+
+func (p *phases) trace(start time.Time) *httptrace.ClientTrace {
+	since := func(t time.Time) time.Duration {
+		if t.IsZero() {
+			return 0
+		}
+		return time.Since(t)
+	}
+	locked := func(f func()) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		f()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { locked(func() { p.dnsStart = time.Now() }) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { locked(func() { p.dns = since(p.dnsStart) }) },
+		// With several addresses the dialer may race connections; the
+		// first to start and the last to finish bound the phase.
+		ConnectStart: func(string, string) {
+			locked(func() {
+				if p.connStart.IsZero() {
+					p.connStart = time.Now()
+				}
+			})
+		},
+		ConnectDone:       func(string, string, error) { locked(func() { p.connect = since(p.connStart) }) },
+		TLSHandshakeStart: func() { locked(func() { p.tlsStart = time.Now() }) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { locked(func() { p.tls = since(p.tlsStart) }) },
+		GotConn:           func(info httptrace.GotConnInfo) { locked(func() { p.reused = info.Reused }) },
+		GotFirstResponseByte: func() {
+			locked(func() { p.ttfb = time.Since(start) })
+		},
+	}
+}
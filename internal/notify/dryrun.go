@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DryRun prints messages instead of delivering them, so formats and prompts
+// can be developed without spamming real chats.
+type DryRun struct {
+	// Target is the backend the message would have gone to (may be nil).
+	Target Notifier
+	// Out receives the messages (default os.Stdout).
+	Out io.Writer
+}
+
+// Name returns "dry-run".
+func (*DryRun) Name() string { return "dry-run" }
+
+// Notify writes message to Out, prefixed with the target backend's name.
+func (d *DryRun) Notify(_ context.Context, message string) error {
+	out := d.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	name := "no backend"
+	if d.Target != nil {
+		name = d.Target.Name()
+	}
+
+	_, err := fmt.Fprintf(out, "----- [dry-run] %s -----\n%s\n----- [end dry-run] -----\n", name, message)
+	return err
+}
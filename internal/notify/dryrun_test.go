@@ -0,0 +1,28 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDryRunNamesTarget(t *testing.T) {
+	tests := []struct {
+		target Notifier
+		want   string
+	}{
+		{nil, "----- [dry-run] no backend -----"},
+		{&Telegram{}, "----- [dry-run] telegram -----"},
+		{&Webhook{}, "----- [dry-run] webhook -----"},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		d := &DryRun{Target: tt.target, Out: &out}
+		if err := d.Notify(context.Background(), "hello"); err != nil {
+			t.Fatal(err)
+		}
+		if first, _, _ := strings.Cut(out.String(), "\n"); first != tt.want {
+			t.Errorf("header = %q, want %q", first, tt.want)
+		}
+	}
+}
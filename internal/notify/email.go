@@ -0,0 +1,218 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultEmailPort    = 587
+	defaultEmailTimeout = 30 * time.Second
+
+	// smtpsPort is the conventional implicit-TLS port; any other port uses
+	// STARTTLS when the server offers it.
+	smtpsPort = 465
+)
+
+// Email sends messages over SMTP as multipart mail: an HTML part with the
+// text in <pre> blocks and tables as HTML tables, and a plaintext fallback.
+type Email struct {
+	Host string
+	// Port defaults to 587. Port 465 uses implicit TLS, others STARTTLS.
+	Port     int
+	Username string // empty skips authentication
+	Password string
+	From     string
+	To       []string
+	// Timeout bounds the whole SMTP exchange (default 30s).
+	Timeout time.Duration
+	// Logger receives diagnostics; nil discards them.
+	Logger *slog.Logger
+}
+
+// Name returns "email".
+func (*Email) Name() string { return "email" }
+
+// Notify emails message to every recipient. The first line becomes the
+// subject.
+func (e *Email) Notify(ctx context.Context, message string) error {
+	return e.NotifyMessage(ctx, Text(message))
+}
+
+// NotifyMessage is Notify, rendering m's tables with HTMLTable in the HTML
+// part.
+func (e *Email) NotifyMessage(ctx context.Context, m Message) error {
+	if len(e.To) == 0 {
+		return errors.New("email: no recipients")
+	}
+	port := e.Port
+	if port == 0 {
+		port = defaultEmailPort
+	}
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = defaultEmailTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := buildEmail(e.From, e.To, m)
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: e.Host}
+
+	var conn net.Conn
+	if port == smtpsPort {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("email: connect %s: %w", addr, err)
+	}
+	// net/smtp has no context support: a deadline bounds a stalled server and
+	// closing the connection aborts on cancellation.
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("email: greeting from %s: %w", addr, err)
+	}
+	defer func() {
+		if cerr := client.Close(); cerr != nil {
+			// Already closed after QUIT or cancellation
+			logger(e.Logger).Debug("close smtp connection", "err", cerr)
+		}
+	}()
+
+	if port != smtpsPort {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("email: starttls: %w", err)
+			}
+		}
+	}
+	if e.Username != "" {
+		// smtp.PlainAuth refuses to send credentials over an unencrypted link
+		if err := client.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host)); err != nil {
+			return fmt.Errorf("email: authentication as %q failed: %w", e.Username, err)
+		}
+	}
+
+	if err := client.Mail(e.From); err != nil {
+		return fmt.Errorf("email: sender %q rejected: %w", e.From, err)
+	}
+	for _, to := range e.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("email: recipient %q rejected: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("email: start data: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("email: write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("email: send message: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildEmail renders m as a multipart/alternative mail. Markdown code fences
+// are dropped: the plaintext part keeps ASCII tables as they are, and the HTML
+// part has HTML tables, with the text in <pre> blocks to keep its layout.
+func buildEmail(from string, to []string, m Message) ([]byte, error) {
+	// A line break would end the header early and start another
+	for _, addr := range append([]string{from}, to...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return nil, fmt.Errorf("address %q contains a line break", addr)
+		}
+	}
+	text := stripFences(m.Render(ASCIITable{}))
+
+	var htmlBody strings.Builder
+	for _, bl := range m.blocks {
+		if bl.table != nil {
+			htmlBody.WriteString(HTMLTable{}.RenderTable(*bl.table))
+			continue
+		}
+		if t := strings.Trim(stripFences(bl.text), "\n"); t != "" {
+			htmlBody.WriteString("<pre style=\"font-family: monospace\">" + html.EscapeString(t) + "</pre>")
+		}
+	}
+
+	// Q-encoding escapes any control characters left in the subject line
+	subject, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		subject = "Weather forecast"
+	}
+
+	var token [12]byte
+	if _, err := rand.Read(token[:]); err != nil {
+		return nil, fmt.Errorf("generate boundary: %w", err)
+	}
+	boundary := hex.EncodeToString(token[:])
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+
+	parts := []struct{ contentType, body string }{
+		{"text/plain", text},
+		{"text/html", "<html><body>" + htmlBody.String() + "</body></html>"},
+	}
+	for _, p := range parts {
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		fmt.Fprintf(&b, "Content-Type: %s; charset=utf-8\r\n", p.contentType)
+		b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		qp := quotedprintable.NewWriter(&b)
+		if _, err := qp.Write([]byte(strings.ReplaceAll(p.body, "\n", "\r\n"))); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+		b.WriteString("\r\n")
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
+
+// stripFences drops Markdown code fence lines from text.
+func stripFences(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "```" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
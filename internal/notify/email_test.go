@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestBuildEmailRejectsLineBreaksInAddresses(t *testing.T) {
+	tests := []struct {
+		from string
+		to   []string
+	}{
+		{"agent@example.com\r\nBcc: victim@example.com", []string{"me@example.com"}},
+		{"agent@example.com", []string{"me@example.com", "you@example.com\nBcc: victim@example.com"}},
+	}
+	for _, tt := range tests {
+		if _, err := buildEmail(tt.from, tt.to, Text("hello")); err == nil || !strings.Contains(err.Error(), "line break") {
+			t.Errorf("buildEmail(%q, %q) = %v, want the line break rejected", tt.from, tt.to, err)
+		}
+	}
+}
+
+func TestBuildEmailSubjectCannotAddHeaders(t *testing.T) {
+	tests := []struct {
+		message, want string
+	}{
+		{"🛫 Heathrow\nEasterly tomorrow", "🛫 Heathrow"},
+		{"Heathrow\r\nBcc: victim@example.com", "Heathrow"},
+		{"Heathrow\rBcc: victim@example.com\nrest", "Heathrow\rBcc: victim@example.com"},
+	}
+	for _, tt := range tests {
+		raw, err := buildEmail("agent@example.com", []string{"me@example.com"}, Text(tt.message))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := mail.ReadMessage(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bcc := msg.Header.Get("Bcc"); bcc != "" {
+			t.Errorf("%q: added a Bcc header %q", tt.message, bcc)
+		}
+		subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if subject != tt.want {
+			t.Errorf("%q: subject %q, want %q", tt.message, subject, tt.want)
+		}
+	}
+}
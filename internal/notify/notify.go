@@ -0,0 +1,22 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Notifier delivers a formatted forecast message to a single backend.
+// Implementations should abort in-flight sends when ctx is cancelled.
+type Notifier interface {
+	Notify(ctx context.Context, message string) error
+	// Name is the backend's short lowercase name, e.g. "telegram".
+	Name() string
+}
+
+// logger returns l, or a logger that discards everything when l is nil.
+func logger(l *slog.Logger) *slog.Logger {
+	if l != nil {
+		return l
+	}
+	return slog.New(slog.DiscardHandler)
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBucket paces calls to rate per second, allowing bursts of up to burst
+// calls after a quiet spell. A nil *tokenBucket doesn't limit.
+type tokenBucket struct {
+	rate  float64 // tokens added per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns nil, no limit, for a negative rate.
+func newTokenBucket(rate float64) *tokenBucket {
+	if rate < 0 {
+		return nil
+	}
+	burst := max(math.Floor(rate), 1)
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst}
+}
+
+// wait blocks until a token is available, or returns ctx's error if it is
+// cancelled first. Waiters are served in the order they arrived.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	now := time.Now()
+	if !b.last.IsZero() {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*b.rate, b.burst)
+	}
+	b.last = now
+	// Take the token now, going into debt if need be, so later callers queue
+	// behind this one
+	b.tokens--
+	delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.tokens++ // give it back
+		b.mu.Unlock()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
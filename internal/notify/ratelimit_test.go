@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketPaces(t *testing.T) {
+	b := newTokenBucket(5) // a burst of 5, then one every 200ms
+	start := time.Now()
+	for range 8 {
+		if err := b.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Three calls past the burst wait 200ms each
+	if elapsed := time.Since(start); elapsed < 550*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("8 calls took %s, want about 600ms", elapsed)
+	}
+}
+
+func TestTokenBucketCancel(t *testing.T) {
+	b := newTokenBucket(1)
+	if err := b.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	// The cancelled call gave its token back, so the next waits a second,
+	// not two
+	start := time.Now()
+	if err := b.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Errorf("next call waited %s, want under a second", elapsed)
+	}
+}
+
+func TestTokenBucketOff(t *testing.T) {
+	b := newTokenBucket(-1)
+	if b != nil {
+		t.Fatal("want no bucket for a negative rate")
+	}
+	start := time.Now()
+	for range 100 {
+		if err := b.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("100 calls took %s, want no pacing", elapsed)
+	}
+}
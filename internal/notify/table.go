@@ -0,0 +1,288 @@
+package notify
+
+import (
+	"context"
+	"html"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Table is a table of forecast data, kept apart from its formatting so each
+// backend can render it in its own way. Cells are laid out for a monospace
+// font, padded to line up in ASCIITable; the other renderers trim them.
+type Table struct {
+	Header    []string
+	Rows      [][]string
+	Footnotes []string // lines explaining markers, shown under the table
+}
+
+// TableRenderer formats a Table for one kind of backend.
+type TableRenderer interface {
+	RenderTable(t Table) string
+}
+
+// ASCIITable renders the pipe-delimited monospace table, padding each column
+// to its widest cell:
+//
+//	Date       | Wind | Gust
+//	-----------+------+-----
+//	Mon 02 Jan |   12 |   30
+//
+// Fenced wraps it in a ``` code block, which Telegram shows in monospace and
+// the other plain-text backends strip or pass through.
+type ASCIITable struct {
+	Fenced bool
+}
+
+func (r ASCIITable) RenderTable(t Table) string {
+	widths := t.widths()
+	rule := make([]string, len(widths))
+	for i, w := range widths {
+		rule[i] = strings.Repeat("-", w)
+	}
+
+	// Pad every column but the last, so lines carry no trailing spaces
+	line := func(cells []string) string {
+		out := make([]string, len(cells))
+		for i, c := range cells {
+			if i < len(cells)-1 && i < len(widths) {
+				c += strings.Repeat(" ", widths[i]-cellWidth(c))
+			}
+			out[i] = c
+		}
+		return strings.Join(out, "|") + "\n"
+	}
+
+	var b strings.Builder
+	if r.Fenced {
+		b.WriteString("```\n")
+	}
+	b.WriteString(line(t.Header))
+	b.WriteString(strings.Join(rule, "+") + "\n")
+	for _, row := range t.Rows {
+		b.WriteString(line(row))
+	}
+	for _, f := range t.Footnotes {
+		b.WriteString(f + "\n")
+	}
+	if r.Fenced {
+		b.WriteString("```")
+	}
+	return b.String()
+}
+
+// widths returns the width of each column, that of its widest cell.
+func (t Table) widths() []int {
+	widths := make([]int, len(t.Header))
+	for _, row := range append([][]string{t.Header}, t.Rows...) {
+		for i, cell := range row {
+			if i < len(widths) {
+				widths[i] = max(widths[i], cellWidth(cell))
+			}
+		}
+	}
+	return widths
+}
+
+// Width is the length of t's lines as rendered by ASCIITable, separators
+// included.
+func (t Table) Width() int {
+	widths := t.widths()
+	n := max(len(widths)-1, 0)
+	for _, w := range widths {
+		n += w
+	}
+	return n
+}
+
+// Fit drops t's last columns until its lines are at most width long, always
+// keeping the first. The new last column's cells lose their trailing
+// padding. A width of 0 or less keeps every column.
+func (t Table) Fit(width int) Table {
+	if width <= 0 {
+		return t
+	}
+	for len(t.Header) > 1 && t.Width() > width {
+		n := len(t.Header) - 1
+		t.Header = trimLast(slices.Clone(t.Header[:n]))
+		rows := make([][]string, len(t.Rows))
+		for i, row := range t.Rows {
+			rows[i] = trimLast(slices.Clone(row[:min(len(row), n)]))
+		}
+		t.Rows = rows
+	}
+	return t
+}
+
+// trimLast trims the trailing spaces of cells' last cell, in place.
+func trimLast(cells []string) []string {
+	if n := len(cells); n > 0 {
+		cells[n-1] = strings.TrimRight(cells[n-1], " ")
+	}
+	return cells
+}
+
+// cellWidth counts a cell's runes, leaving out the emoji variation selector
+// so "⚠️" counts as one, as the tables' hand-padded markers assume.
+func cellWidth(cell string) int {
+	return utf8.RuneCountInString(cell) - strings.Count(cell, "\ufe0f")
+}
+
+// MarkdownTable renders a GitHub-flavoured Markdown table, for backends that
+// display Markdown.
+type MarkdownTable struct{}
+
+func (MarkdownTable) RenderTable(t Table) string {
+	row := func(cells []string) string {
+		out := make([]string, len(cells))
+		for i, c := range cells {
+			out[i] = strings.ReplaceAll(strings.TrimSpace(c), "|", `\|`)
+		}
+		return "| " + strings.Join(out, " | ") + " |\n"
+	}
+
+	// A blank line first, so the table doesn't run on from a paragraph
+	var b strings.Builder
+	b.WriteString("\n" + row(t.Header))
+	b.WriteString("|" + strings.Repeat(" --- |", len(t.Header)) + "\n")
+	for _, r := range t.Rows {
+		b.WriteString(row(r))
+	}
+	if len(t.Footnotes) > 0 {
+		b.WriteString("\n" + strings.Join(t.Footnotes, "  \n") + "\n")
+	}
+	return b.String()
+}
+
+// HTMLTable renders an HTML <table>, for email and web pages.
+type HTMLTable struct{}
+
+func (HTMLTable) RenderTable(t Table) string {
+	var b strings.Builder
+	b.WriteString(`<table style="border-collapse: collapse">` + "\n<thead><tr>")
+	for _, c := range t.Header {
+		b.WriteString(`<th style="border-bottom: 1px solid #999; padding: 2px 8px; text-align: left">` + html.EscapeString(strings.TrimSpace(c)) + "</th>")
+	}
+	b.WriteString("</tr></thead>\n<tbody>\n")
+	for _, r := range t.Rows {
+		b.WriteString("<tr>")
+		for _, c := range r {
+			b.WriteString(`<td style="padding: 2px 8px">` + html.EscapeString(strings.TrimSpace(c)) + "</td>")
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</tbody>\n</table>")
+	for _, f := range t.Footnotes {
+		b.WriteString("\n<small>" + html.EscapeString(f) + "</small><br>")
+	}
+	return b.String()
+}
+
+// Message is a notification's content: running text interleaved with tables.
+// Backends that implement MessageNotifier render the tables themselves; the
+// others get Render(ASCIITable{Fenced: true}). The zero Message is empty.
+type Message struct {
+	blocks []block
+	data   any // see WithData
+}
+
+type block struct {
+	text  string
+	table *Table
+}
+
+// Text returns a message of plain text.
+func Text(s string) Message {
+	return Message{}.Text(s)
+}
+
+// Text returns m followed by s.
+func (m Message) Text(s string) Message {
+	if s == "" {
+		return m
+	}
+	m.blocks = append(m.blocks[:len(m.blocks):len(m.blocks)], block{text: s})
+	return m
+}
+
+// Table returns m followed by t.
+func (m Message) Table(t Table) Message {
+	m.blocks = append(m.blocks[:len(m.blocks):len(m.blocks)], block{table: &t})
+	return m
+}
+
+// Append returns m followed by o, keeping m's data.
+func (m Message) Append(o Message) Message {
+	m.blocks = append(m.blocks[:len(m.blocks):len(m.blocks)], o.blocks...)
+	return m
+}
+
+// WithData returns m carrying v, the structured data the message was built
+// from, for backends that format their own payload (see Webhook).
+func (m Message) WithData(v any) Message {
+	m.data = v
+	return m
+}
+
+// Data returns the data set by WithData, or nil.
+func (m Message) Data() any {
+	return m.data
+}
+
+// IsEmpty reports whether m has no content.
+func (m Message) IsEmpty() bool {
+	return len(m.blocks) == 0
+}
+
+// TrimRight returns m without trailing newlines.
+func (m Message) TrimRight() Message {
+	blocks := m.blocks
+	for len(blocks) > 0 {
+		last := blocks[len(blocks)-1]
+		if last.table != nil {
+			break
+		}
+		if t := strings.TrimRight(last.text, "\n"); t != "" {
+			blocks = append(blocks[:len(blocks)-1:len(blocks)-1], block{text: t})
+			break
+		}
+		blocks = blocks[:len(blocks)-1]
+	}
+	m.blocks = blocks
+	return m
+}
+
+// Render formats m as text with its tables rendered by r.
+func (m Message) Render(r TableRenderer) string {
+	var b strings.Builder
+	for _, bl := range m.blocks {
+		if bl.table != nil {
+			b.WriteString(r.RenderTable(*bl.table))
+		} else {
+			b.WriteString(bl.text)
+		}
+	}
+	return b.String()
+}
+
+// String renders m for plain-text backends.
+func (m Message) String() string {
+	return m.Render(ASCIITable{Fenced: true})
+}
+
+// MessageNotifier is a Notifier that formats a Message's tables for its
+// backend instead of receiving ASCII tables.
+type MessageNotifier interface {
+	Notifier
+	NotifyMessage(ctx context.Context, m Message) error
+}
+
+// Send delivers m through n, as a Message when n supports it and as plain
+// text otherwise.
+func Send(ctx context.Context, n Notifier, m Message) error {
+	if mn, ok := n.(MessageNotifier); ok {
+		return mn.NotifyMessage(ctx, m)
+	}
+	return n.Notify(ctx, m.String())
+}
@@ -0,0 +1,436 @@
+package notify
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	telegramBaseURL = "https://api.telegram.org"
+
+	// telegramMaxMessage is the Bot API's limit on message text length.
+	telegramMaxMessage = 4096
+
+	defaultTelegramMaxAttempts = 4
+	defaultTelegramBackoff     = time.Second
+
+	// Telegram's documented limits: about one message per second to a chat
+	// and 30 per second across all chats
+	defaultTelegramChatRate   = 1
+	defaultTelegramGlobalRate = 30
+)
+
+// Telegram sends messages through the Telegram Bot API.
+type Telegram struct {
+	Token  string
+	ChatID string
+	// ChatIDs are further chats that get every message, e.g. a family group
+	// as well as a personal chat. Each is sent to independently, so one bad
+	// ID doesn't stop the others.
+	ChatIDs []string
+
+	// MaxAttempts bounds delivery attempts per message (default 4).
+	MaxAttempts int
+	// Backoff is the initial delay between attempts, doubled after each
+	// failure (default 1s). A 429 response's retry_after takes precedence.
+	Backoff time.Duration
+	// BaseURL overrides the Bot API root (mainly for tests).
+	BaseURL string
+	// Logger receives diagnostics; nil discards them.
+	Logger *slog.Logger
+	// ParseMode is the Bot API formatting mode: "" (plain text, default),
+	// "Markdown", "MarkdownV2" or "HTML". Messages are escaped to suit it, so
+	// stray _ or * in a summary can't break delivery.
+	ParseMode string
+
+	// ChatRate and GlobalRate pace sends, in messages per second, to each
+	// chat and across all chats (default 1 and 30, Telegram's limits), so a
+	// burst of messages waits its turn instead of drawing 429s. A negative
+	// rate turns that limit off.
+	ChatRate   float64
+	GlobalRate float64
+
+	limitsMu sync.Mutex
+	global   *tokenBucket
+	perChat  map[string]*tokenBucket
+}
+
+// TelegramMessage is the payload for Telegram API
+type TelegramMessage struct {
+	ChatID    string `json:"chat_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+// telegramAPIError is a non-OK response from the Bot API.
+type telegramAPIError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // from parameters.retry_after on 429
+}
+
+func (e *telegramAPIError) Error() string {
+	return fmt.Sprintf("telegram API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// Name returns "telegram".
+func (*Telegram) Name() string { return "telegram" }
+
+// Notify sends message to every configured chat, returning the joined errors
+// of those that failed. Messages over Telegram's length limit are split on
+// line boundaries and sent in order; within a chat, the first chunk that
+// fails stops the rest, as does cancelling ctx.
+func (t *Telegram) Notify(ctx context.Context, message string) error {
+	switch t.ParseMode {
+	case "", "Markdown", "MarkdownV2", "HTML":
+	default:
+		return fmt.Errorf("telegram: unsupported parse mode %q", t.ParseMode)
+	}
+
+	chats := t.chats()
+	if len(chats) == 1 {
+		return t.notifyChat(ctx, chats[0], message)
+	}
+	var errs []error
+	for _, chatID := range chats {
+		if err := t.notifyChat(ctx, chatID, message); err != nil {
+			errs = append(errs, fmt.Errorf("telegram chat %s: %w", chatID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// chats lists ChatID and ChatIDs, skipping empty entries.
+func (t *Telegram) chats() []string {
+	var out []string
+	for _, id := range append([]string{t.ChatID}, t.ChatIDs...) {
+		if id != "" {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// notifyChat sends message to one chat, in chunks if needed.
+func (t *Telegram) notifyChat(ctx context.Context, chatID, message string) error {
+	// The limit applies to the rendered text, so split before escaping
+	chunks := splitTelegramMessage(message, telegramMaxMessage)
+	for i, chunk := range chunks {
+		if err := t.send(ctx, chatID, formatTelegram(chunk, t.ParseMode)); err != nil {
+			if len(chunks) == 1 {
+				return err
+			}
+			return fmt.Errorf("telegram chunk %d/%d: %w", i+1, len(chunks), err)
+		}
+	}
+	return nil
+}
+
+// send delivers a single message, retrying transient failures (network
+// errors, 429 and 5xx) with backoff. The last error is returned once attempts
+// are exhausted, or ctx's error if it is cancelled while waiting to retry.
+func (t *Telegram) send(ctx context.Context, chatID, message string) error {
+	attempts := t.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultTelegramMaxAttempts
+	}
+	backoff := t.Backoff
+	if backoff <= 0 {
+		backoff = defaultTelegramBackoff
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err := t.pace(ctx, chatID); err != nil {
+			return err
+		}
+		_, err = sendTelegramMessage(ctx, logger(t.Logger), t.baseURL(), t.Token, chatID, t.ParseMode, message)
+		if err == nil || attempt == attempts || ctx.Err() != nil {
+			break
+		}
+
+		wait := backoff
+		var apiErr *telegramAPIError
+		if errors.As(err, &apiErr) {
+			if apiErr.StatusCode != http.StatusTooManyRequests && apiErr.StatusCode < 500 {
+				break // not retryable, e.g. bad token or chat ID
+			}
+			if apiErr.RetryAfter > 0 {
+				wait = apiErr.RetryAfter
+			}
+		}
+
+		logger(t.Logger).Warn("telegram send failed, retrying", "chat_id", chatID, "attempt", attempt, "max_attempts", attempts, "retry_in", wait, "err", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+// pace waits for chatID's rate limit and then the global one.
+func (t *Telegram) pace(ctx context.Context, chatID string) error {
+	t.limitsMu.Lock()
+	// The global bucket is nil when off, so test the map for first use
+	if t.perChat == nil {
+		t.global = newTokenBucket(cmp.Or(t.GlobalRate, defaultTelegramGlobalRate))
+		t.perChat = make(map[string]*tokenBucket)
+	}
+	chat, ok := t.perChat[chatID]
+	if !ok {
+		chat = newTokenBucket(cmp.Or(t.ChatRate, defaultTelegramChatRate))
+		t.perChat[chatID] = chat
+	}
+	t.limitsMu.Unlock()
+
+	if err := chat.wait(ctx); err != nil {
+		return err
+	}
+	return t.global.wait(ctx)
+}
+
+// Test sends message once to ChatID, without splitting, escaping or retries,
+// and returns the Bot API's raw response body. It is meant for checking the
+// token and chat ID by hand; on a non-OK response the body is in the error.
+func (t *Telegram) Test(ctx context.Context, message string) (string, error) {
+	body, err := sendTelegramMessage(ctx, logger(t.Logger), t.baseURL(), t.Token, t.ChatID, "", message)
+	return string(body), err
+}
+
+// GetMe checks the token with the Bot API's getMe method and returns the
+// bot's username.
+func (t *Telegram) GetMe(ctx context.Context) (string, error) {
+	url := fmt.Sprintf("%s/bot%s/getMe", t.baseURL(), t.Token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create telegram request: %w", err)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL carries the token; keep it out of errors and logs
+		return "", fmt.Errorf("failed to call telegram getMe: %w", errors.Unwrap(err))
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			logger(t.Logger).Debug("close telegram response body", "err", cerr)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read telegram response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &telegramAPIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var payload struct {
+		Result struct {
+			Username string `json:"username"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", fmt.Errorf("failed to decode telegram response: %w", err)
+	}
+	return payload.Result.Username, nil
+}
+
+func (t *Telegram) baseURL() string {
+	if t.BaseURL != "" {
+		return t.BaseURL
+	}
+	return telegramBaseURL
+}
+
+// sendTelegramMessage posts one message and returns the response body.
+func sendTelegramMessage(ctx context.Context, log *slog.Logger, base, token, chatID, parseMode, message string) ([]byte, error) {
+	url := fmt.Sprintf("%s/bot%s/sendMessage", base, token)
+
+	msg := TelegramMessage{
+		ChatID:    chatID,
+		Text:      message,
+		ParseMode: parseMode,
+	}
+
+	jsonData, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal telegram message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create telegram request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL carries the token; keep it out of errors and logs
+		return nil, fmt.Errorf("failed to send telegram message: %w", errors.Unwrap(err))
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			// Harmless once the body has been read
+			log.Debug("close telegram response body", "err", cerr)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read telegram response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &telegramAPIError{StatusCode: resp.StatusCode, Body: string(body)}
+
+		var payload struct {
+			Parameters struct {
+				RetryAfter int `json:"retry_after"`
+			} `json:"parameters"`
+		}
+		if json.Unmarshal(body, &payload) == nil {
+			apiErr.RetryAfter = time.Duration(payload.Parameters.RetryAfter) * time.Second
+		}
+		return body, apiErr
+	}
+
+	return body, nil
+}
+
+// splitTelegramMessage breaks message into chunks of at most limit characters,
+// splitting on line boundaries. Code-block fences are closed at the end of a
+// chunk and reopened at the start of the next so each chunk renders alone.
+func splitTelegramMessage(message string, limit int) []string {
+	if len([]rune(message)) <= limit {
+		return []string{message}
+	}
+
+	const fence = "```"
+	var (
+		chunks  []string
+		current strings.Builder
+		size    int // runes in current
+		inFence bool
+	)
+	flush := func() {
+		if size == 0 {
+			return
+		}
+		text := strings.TrimSuffix(current.String(), "\n")
+		if inFence {
+			text += "\n" + fence
+		}
+		chunks = append(chunks, text)
+		current.Reset()
+		size = 0
+		if inFence {
+			current.WriteString(fence + "\n")
+			size = len(fence) + 1
+		}
+	}
+
+	for _, line := range strings.SplitAfter(message, "\n") {
+		// Reserve room to close an open fence at the end of the chunk
+		reserve := 0
+		if inFence {
+			reserve = len(fence) + 1
+		}
+
+		n := len([]rune(line))
+		if size+n+reserve > limit {
+			flush()
+		}
+		// A single line longer than a whole chunk is hard-split
+		for runes := []rune(line); size+len(runes)+reserve > limit; {
+			take := max(limit-size-reserve, 1)
+			current.WriteString(string(runes[:take]))
+			size += take
+			runes = runes[take:]
+			flush()
+			line = string(runes)
+		}
+
+		current.WriteString(line)
+		size += len([]rune(line))
+		if strings.HasPrefix(strings.TrimSpace(line), fence) {
+			inFence = !inFence
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// formatTelegram renders message, plain text with ``` fences around tables,
+// for parse mode: fences become code blocks and everything else is escaped so
+// it displays literally. Plain mode drops the fences.
+func formatTelegram(message, mode string) string {
+	const fence = "```"
+	var lines []string
+	inFence := false
+	for _, line := range strings.Split(message, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), fence) {
+			lines = append(lines, escapeTelegram(line, mode, inFence))
+			continue
+		}
+		switch {
+		case mode == "":
+		case mode != "HTML":
+			lines = append(lines, fence)
+		case inFence:
+			lines = append(lines, "</pre>")
+		default:
+			lines = append(lines, "<pre>")
+		}
+		inFence = !inFence
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escapeTelegram escapes one line of text for mode. Code blocks need less
+// escaping than running text.
+func escapeTelegram(text, mode string, code bool) string {
+	var special string
+	switch mode {
+	case "HTML":
+		return html.EscapeString(text)
+	case "Markdown":
+		if code {
+			return text // legacy Markdown has no escapes inside code
+		}
+		special = "_*`["
+	case "MarkdownV2":
+		special = "_*[]()~`>#+-=|{}.!\\"
+		if code {
+			special = "`\\"
+		}
+	default:
+		return text
+	}
+
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,246 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// telegramServer serves sendMessage with the statuses in order, repeating the
+// last, and counts the calls. A 429 carries a retry_after of one second.
+func telegramServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		status := statuses[min(n, len(statuses))-1]
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		switch status {
+		case http.StatusOK:
+			_, _ = w.Write([]byte(`{"ok": true}`))
+		case http.StatusTooManyRequests:
+			_, _ = w.Write([]byte(`{"ok": false, "error_code": 429, "parameters": {"retry_after": 1}}`))
+		default:
+			_, _ = w.Write([]byte(`{"ok": false}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// testTelegram is a Telegram client for srv without rate limits, so only
+// retries delay sends.
+func testTelegram(srv *httptest.Server) *Telegram {
+	return &Telegram{
+		Token:      "123:abc",
+		ChatID:     "42",
+		Backoff:    10 * time.Millisecond,
+		BaseURL:    srv.URL,
+		ChatRate:   -1,
+		GlobalRate: -1,
+	}
+}
+
+func TestTelegramRetriesAfter429(t *testing.T) {
+	srv, calls := telegramServer(t, http.StatusTooManyRequests, http.StatusOK)
+	tg := testTelegram(srv)
+
+	start := time.Now()
+	if err := tg.Notify(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("sent %d requests, want 2 (one retry)", n)
+	}
+	// The backoff is 10ms, so waiting a second means retry_after was honoured
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least retry_after's 1s", elapsed)
+	}
+}
+
+func TestTelegramGivesUpAfterMaxAttempts(t *testing.T) {
+	srv, calls := telegramServer(t, http.StatusBadGateway)
+	tg := testTelegram(srv)
+	tg.MaxAttempts = 3
+
+	if err := tg.Notify(context.Background(), "hello"); err == nil {
+		t.Fatal("want an error once attempts are exhausted")
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("sent %d requests, want 3", n)
+	}
+}
+
+func TestTelegramDoesNotRetryClientErrors(t *testing.T) {
+	srv, calls := telegramServer(t, http.StatusBadRequest)
+	if err := testTelegram(srv).Notify(context.Background(), "hello"); err == nil {
+		t.Fatal("want an error")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestTelegramCancelStopsBackoff(t *testing.T) {
+	srv, calls := telegramServer(t, http.StatusBadGateway)
+	tg := testTelegram(srv)
+	tg.Backoff = 10 * time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for calls.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	start := time.Now()
+	err := tg.Notify(ctx, "hello")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("returned after %s, want promptly rather than after the backoff", elapsed)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestTelegramCancelAbortsRequest(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := testTelegram(srv).Notify(ctx, "hello")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("returned after %s, want promptly rather than after the client timeout", elapsed)
+	}
+}
+
+func TestTelegramMessagePayload(t *testing.T) {
+	var got TelegramMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:abc/sendMessage" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	if err := testTelegram(srv).Notify(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got.ChatID != "42" || got.Text != "hello" || got.ParseMode != "" {
+		t.Errorf("payload = %+v", got)
+	}
+}
+
+func TestFormatTelegramEscapesUnderscores(t *testing.T) {
+	const message = "wind_speed is *high* at 3.5 <km/h>\n```\nmax_gust | 40\n```"
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"", "wind_speed is *high* at 3.5 <km/h>\nmax_gust | 40"},
+		{"Markdown", "wind\\_speed is \\*high\\* at 3.5 <km/h>\n```\nmax_gust | 40\n```"},
+		{"MarkdownV2", "wind\\_speed is \\*high\\* at 3\\.5 <km/h\\>\n```\nmax_gust | 40\n```"},
+		{"HTML", "wind_speed is *high* at 3.5 &lt;km/h&gt;\n<pre>\nmax_gust | 40\n</pre>"},
+	}
+	for _, tt := range tests {
+		t.Run("mode="+tt.mode, func(t *testing.T) {
+			if got := formatTelegram(message, tt.mode); got != tt.want {
+				t.Errorf("formatTelegram(%q) =\n%s\nwant\n%s", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTelegramSendsParseMode(t *testing.T) {
+	for _, mode := range []string{"", "Markdown", "MarkdownV2", "HTML"} {
+		t.Run("mode="+mode, func(t *testing.T) {
+			var got TelegramMessage
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("decode payload: %v", err)
+				}
+				_, _ = w.Write([]byte(`{"ok": true}`))
+			}))
+			defer srv.Close()
+
+			tg := testTelegram(srv)
+			tg.ParseMode = mode
+			if err := tg.Notify(context.Background(), "gusty_days: 2"); err != nil {
+				t.Fatal(err)
+			}
+			if got.ParseMode != mode {
+				t.Errorf("parse_mode = %q, want %q", got.ParseMode, mode)
+			}
+			if got.Text != formatTelegram("gusty_days: 2", mode) {
+				t.Errorf("text = %q, want it escaped for %q", got.Text, mode)
+			}
+		})
+	}
+}
+
+func TestTelegramRejectsUnknownParseMode(t *testing.T) {
+	tg := &Telegram{ParseMode: "markdown"}
+	if err := tg.Notify(context.Background(), "hi"); err == nil {
+		t.Error("want an error for an unsupported parse mode")
+	}
+}
+
+func TestTelegramThrottlesRapidSends(t *testing.T) {
+	tests := []struct {
+		name                 string
+		chatRate, globalRate float64
+		chatIDs              []string
+	}{
+		{"per chat", 5, -1, nil},
+		{"across chats", -1, 5, []string{"43"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := telegramServer(t, http.StatusOK)
+			tg := testTelegram(srv)
+			tg.ChatRate, tg.GlobalRate, tg.ChatIDs = tt.chatRate, tt.globalRate, tt.chatIDs
+
+			// 8 requests at 5 a second: a burst of 5, then three 200ms waits
+			start := time.Now()
+			for range 8 / len(tg.chats()) {
+				if err := tg.Notify(context.Background(), "hello"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			elapsed := time.Since(start)
+			if n := calls.Load(); n != 8 {
+				t.Errorf("sent %d requests, want 8", n)
+			}
+			if elapsed < 550*time.Millisecond || elapsed > 3*time.Second {
+				t.Errorf("8 requests took %s, want about 600ms", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	twilioBaseURL = "https://api.twilio.com/2010-04-01"
+
+	// twilioMaxBody is the longest body Twilio accepts for a single message
+	// (it is split into SMS segments on delivery).
+	twilioMaxBody = 1600
+
+	twilioTrimmedNote = "\n…(table trimmed)"
+)
+
+// Twilio sends messages through the Twilio Messages API. Prefix From and To
+// with "whatsapp:" to deliver over WhatsApp instead of SMS.
+type Twilio struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	To         string
+	HTTPClient *http.Client
+	// BaseURL overrides the Twilio API root (mainly for tests).
+	BaseURL string
+	// Logger receives diagnostics; nil discards them.
+	Logger *slog.Logger
+}
+
+// Name returns "twilio".
+func (*Twilio) Name() string { return "twilio" }
+
+// Notify sends message as a single SMS/WhatsApp message, trimming it to fit.
+func (t *Twilio) Notify(ctx context.Context, message string) error {
+	base := t.BaseURL
+	if base == "" {
+		base = twilioBaseURL
+	}
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", base, url.PathEscape(t.AccountSID))
+
+	form := url.Values{}
+	form.Set("From", t.From)
+	form.Set("To", t.To)
+	form.Set("Body", truncateMessage(message, twilioMaxBody))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("build twilio request: %w", err)
+	}
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := t.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("call twilio: %w", err)
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			// Harmless once the body has been read
+			logger(t.Logger).Debug("close twilio response body", "err", cerr)
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("twilio returned %s: %s", resp.Status, twilioErrorMessage(resp.Body))
+	}
+	return nil
+}
+
+// twilioErrorMessage extracts the message from a Twilio error body, which is
+// usually JSON but may be plain text or HTML from an intermediary.
+func twilioErrorMessage(r io.Reader) string {
+	body, _ := io.ReadAll(io.LimitReader(r, 4096))
+
+	var apiErr struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Message != "" {
+		return fmt.Sprintf("%s (code %d)", apiErr.Message, apiErr.Code)
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// truncateMessage cuts message on a line boundary so it fits in limit runes,
+// appending a note that the content was trimmed.
+func truncateMessage(message string, limit int) string {
+	runes := []rune(message)
+	if len(runes) <= limit {
+		return message
+	}
+
+	cut := string(runes[:limit-len([]rune(twilioTrimmedNote))])
+	if i := strings.LastIndex(cut, "\n"); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + twilioTrimmedNote
+}
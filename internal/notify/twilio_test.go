@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTwilioPostsForm(t *testing.T) {
+	var got struct {
+		path, user, pass, contentType string
+		ok                            bool
+		from, to, body                string
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.path = r.URL.Path
+		got.user, got.pass, got.ok = r.BasicAuth()
+		got.contentType = r.Header.Get("Content-Type")
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		got.from, got.to, got.body = r.PostForm.Get("From"), r.PostForm.Get("To"), r.PostForm.Get("Body")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	tw := &Twilio{
+		AccountSID: "AC123",
+		AuthToken:  "secret",
+		From:       "+15005550006",
+		To:         "whatsapp:+447700900123",
+		BaseURL:    srv.URL,
+	}
+	if err := tw.Notify(context.Background(), "🛫 Heathrow\nEasterly tomorrow"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.path != "/Accounts/AC123/Messages.json" {
+		t.Errorf("path = %q", got.path)
+	}
+	if !got.ok || got.user != "AC123" || got.pass != "secret" {
+		t.Errorf("basic auth = %q, %q (%v), want AC123, secret", got.user, got.pass, got.ok)
+	}
+	if got.contentType != "application/x-www-form-urlencoded" {
+		t.Errorf("Content-Type = %q", got.contentType)
+	}
+	if got.from != "+15005550006" || got.to != "whatsapp:+447700900123" {
+		t.Errorf("From, To = %q, %q", got.from, got.to)
+	}
+	if got.body != "🛫 Heathrow\nEasterly tomorrow" {
+		t.Errorf("Body = %q", got.body)
+	}
+}
+
+func TestTwilioTrimsLongBody(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = r.FormValue("Body")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	tw := &Twilio{AccountSID: "AC123", BaseURL: srv.URL}
+	if err := tw.Notify(context.Background(), strings.Repeat("Mon 02 Jan |   12 |   30\n", 100)); err != nil {
+		t.Fatal(err)
+	}
+	if n := len([]rune(body)); n > twilioMaxBody {
+		t.Errorf("body is %d runes, want at most %d", n, twilioMaxBody)
+	}
+	if !strings.HasSuffix(body, twilioTrimmedNote) {
+		t.Errorf("body doesn't end with the trimmed note: %q", body[len(body)-40:])
+	}
+}
+
+func TestTwilioErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"json", "application/json", `{"code": 21211, "message": "Invalid 'To' Phone Number"}`, "Invalid 'To' Phone Number (code 21211)"},
+		{"plain text", "text/plain", "upstream unavailable\n", "upstream unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			tw := &Twilio{AccountSID: "AC123", BaseURL: srv.URL}
+			err := tw.Notify(context.Background(), "hi")
+			if err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to end with %q", err, tt.want)
+			}
+		})
+	}
+}
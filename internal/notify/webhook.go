@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultWebhookContentType = "application/json"
+
+// Webhook sends messages as HTTP requests to a generic webhook, with a body
+// shaped by a template, for automation systems that ingest them.
+type Webhook struct {
+	URL string
+	// Method is the HTTP method (default POST).
+	Method string
+	// Headers are set on every request, e.g. an Authorization token.
+	Headers map[string]string
+	// ContentType is the body's Content-Type (default application/json).
+	// Bodies of a JSON content type are checked to be valid JSON.
+	ContentType string
+	// Body renders the request body from a WebhookData; nil sends
+	// {"text": "<message>"}. Parse it with ParseWebhookBody for the json
+	// function.
+	Body       *template.Template
+	HTTPClient *http.Client
+	// Logger receives diagnostics; nil discards them.
+	Logger *slog.Logger
+}
+
+// WebhookData is what a Webhook's Body template renders from.
+type WebhookData struct {
+	// Text is the message as plain text, tables in ASCII.
+	Text string
+	// Report is the structured data the message was built from (see
+	// Message.WithData), or nil when it has none.
+	Report any
+}
+
+// ParseWebhookBody parses a Webhook Body template. Besides the standard
+// functions it has json, which marshals its argument, so strings are quoted
+// and escaped: {"text": {{json .Text}}}.
+func ParseWebhookBody(text string) (*template.Template, error) {
+	t, err := template.New("webhook").Funcs(template.FuncMap{"json": webhookJSON}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse webhook body template: %w", err)
+	}
+	return t, nil
+}
+
+func webhookJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Name returns "webhook".
+func (*Webhook) Name() string { return "webhook" }
+
+// Notify sends message as the webhook's text, with no report.
+func (w *Webhook) Notify(ctx context.Context, message string) error {
+	return w.NotifyMessage(ctx, Text(message))
+}
+
+// NotifyMessage renders m and its data into the body and sends it. Any 2xx
+// response is success.
+func (w *Webhook) NotifyMessage(ctx context.Context, m Message) error {
+	body, err := w.body(WebhookData{Text: m.Render(ASCIITable{}), Report: m.Data()})
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+
+	method := w.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, method, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", withoutURL(err))
+	}
+	req.Header.Set("Content-Type", w.contentType())
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := w.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("call webhook: %w", withoutURL(err))
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			// Harmless once the body has been read
+			logger(w.Logger).Debug("close webhook response body", "err", cerr)
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// body renders the request body for d.
+func (w *Webhook) body(d WebhookData) ([]byte, error) {
+	if w.Body == nil {
+		return json.Marshal(map[string]string{"text": d.Text})
+	}
+	var b bytes.Buffer
+	if err := w.Body.Execute(&b, d); err != nil {
+		return nil, fmt.Errorf("render body template: %w", err)
+	}
+	if strings.Contains(w.contentType(), "json") && !json.Valid(b.Bytes()) {
+		// The body may carry secrets too, so it isn't quoted
+		return nil, errors.New("body template rendered invalid JSON")
+	}
+	return b.Bytes(), nil
+}
+
+// withoutURL strips the URL a *url.Error quotes: webhook URLs often carry the
+// secret, which must stay out of errors and logs.
+func withoutURL(err error) error {
+	if uerr, ok := err.(*url.Error); ok {
+		return uerr.Err
+	}
+	return err
+}
+
+func (w *Webhook) contentType() string {
+	if w.ContentType == "" {
+		return defaultWebhookContentType
+	}
+	return w.ContentType
+}
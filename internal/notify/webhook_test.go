@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// webhookRequest is what a test webhook server received.
+type webhookRequest struct {
+	method, contentType, auth, body string
+}
+
+// webhookServer records each request into *got and answers with status.
+func webhookServer(t *testing.T, status int, got *webhookRequest) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		*got = webhookRequest{r.Method, r.Header.Get("Content-Type"), r.Header.Get("Authorization"), string(body)}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("busy"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func mustParseWebhookBody(t *testing.T, text string) *template.Template {
+	t.Helper()
+	body, err := ParseWebhookBody(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestWebhookSendsRenderedBody(t *testing.T) {
+	message := Text(`Easterly "tomorrow"`).WithData(map[string]any{"gust": 42})
+	tests := []struct {
+		name    string
+		webhook *Webhook
+		want    webhookRequest
+	}{
+		{"default body", &Webhook{}, webhookRequest{"POST", "application/json", "", `{"text":"Easterly \"tomorrow\""}`}},
+		{
+			"template with report",
+			&Webhook{Body: mustParseWebhookBody(t, `{"text": {{json .Text}}, "gust": {{.Report.gust}}}`)},
+			webhookRequest{"POST", "application/json", "", `{"text": "Easterly \"tomorrow\"", "gust": 42}`},
+		},
+		{
+			"method, headers and content type",
+			&Webhook{
+				Method:      http.MethodPut,
+				Headers:     map[string]string{"Authorization": "Bearer t0k3n"},
+				ContentType: "text/plain",
+				Body:        mustParseWebhookBody(t, `alert: {{.Text}}`),
+			},
+			webhookRequest{"PUT", "text/plain", "Bearer t0k3n", `alert: Easterly "tomorrow"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got webhookRequest
+			tt.webhook.URL = webhookServer(t, http.StatusNoContent, &got).URL
+			if err := tt.webhook.NotifyMessage(context.Background(), message); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("request = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookRejectsInvalidJSON(t *testing.T) {
+	var got webhookRequest
+	w := &Webhook{
+		URL:  webhookServer(t, http.StatusOK, &got).URL,
+		Body: mustParseWebhookBody(t, `{"text": {{.Text}}}`),
+	}
+
+	err := w.Notify(context.Background(), "hello")
+	if err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("err = %v, want the invalid JSON rejected", err)
+	}
+	if got.method != "" {
+		t.Errorf("sent %+v, want no request", got)
+	}
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	var got webhookRequest
+	w := &Webhook{URL: webhookServer(t, http.StatusServiceUnavailable, &got).URL}
+	err := w.Notify(context.Background(), "hello")
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "busy") {
+		t.Errorf("err = %v, want the status and response body", err)
+	}
+}
+
+func TestWebhookErrorsOmitSecrets(t *testing.T) {
+	const secret = "T000/B000/s3cr3t"
+	srv := httptest.NewServer(http.NotFoundHandler())
+	unreachable := srv.URL + "/services/" + secret
+	srv.Close()
+
+	tests := []struct {
+		name    string
+		webhook *Webhook
+	}{
+		{"unreachable", &Webhook{URL: unreachable}},
+		{"unparsable", &Webhook{URL: "http://hooks.example.com/services/" + secret + "/%zz"}},
+		{"invalid JSON", &Webhook{URL: unreachable, Body: mustParseWebhookBody(t, `{"token": "`+secret+`", "text": {{.Text}}}`)}},
+	}
+	for _, tt := range tests {
+		err := tt.webhook.Notify(context.Background(), "hello")
+		if err == nil {
+			t.Fatalf("%s: want an error", tt.name)
+		}
+		if strings.Contains(err.Error(), secret) {
+			t.Errorf("%s: error %q leaks the secret", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/metrics"
+)
+
+// ErrCircuitOpen is returned without calling the host while the circuit
+// breaker is open (see Client.BreakerFailures).
+var ErrCircuitOpen = errors.New("ollama circuit breaker open")
+
+// Circuit breaker states, as returned by Client.BreakerState.
+const (
+	BreakerClosed   = "closed"    // requests go through
+	BreakerOpen     = "open"      // requests fail fast until the cooldown ends
+	BreakerHalfOpen = "half-open" // one request is probing the host
+)
+
+// DefaultBreakerCooldown is Client.BreakerCooldown's default.
+const DefaultBreakerCooldown = 5 * time.Minute
+
+// breaker counts consecutive failures and trips open at a threshold.
+type breaker struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// allow reports whether a request may go ahead: always when closed, and
+// after the cooldown when open, as the single half-open probe.
+func (b *breaker) allow(cooldown time.Duration, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < cooldown {
+			return false
+		}
+		b.set(BreakerHalfOpen)
+		return true
+	case BreakerHalfOpen:
+		return false // the probe is still in flight
+	default:
+		return true
+	}
+}
+
+// done records a request's outcome, opening the circuit after threshold
+// consecutive failures or a failed probe, and closing it on success.
+func (b *breaker) done(err error, threshold int, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		b.set(BreakerClosed)
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= threshold {
+		b.openedAt = now
+		b.set(BreakerOpen)
+	}
+}
+
+// breakerGauge is each state's value in metrics.CircuitState.
+var breakerGauge = map[string]float64{BreakerClosed: 0, BreakerHalfOpen: 1, BreakerOpen: 2}
+
+func (b *breaker) set(state string) {
+	b.state = state
+	metrics.CircuitState.WithLabelValues(metrics.UpstreamOllama).Set(breakerGauge[state])
+}
+
+// BreakerState returns the circuit breaker's state: BreakerClosed,
+// BreakerOpen or BreakerHalfOpen. It is always BreakerClosed when the
+// breaker is disabled.
+func (c *Client) BreakerState() string {
+	c.breaker.mu.Lock()
+	defer c.breaker.mu.Unlock()
+	if c.breaker.state == "" {
+		return BreakerClosed
+	}
+	return c.breaker.state
+}
+
+// guard runs call through the circuit breaker, if enabled. Requests the
+// caller cancelled say nothing about the host and aren't counted; timeouts
+// are, as a dead host shows up as one.
+func (c *Client) guard(ctx context.Context, call func() error) error {
+	if c.BreakerFailures <= 0 {
+		return call()
+	}
+	cooldown := c.BreakerCooldown
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	if !c.breaker.allow(cooldown, time.Now()) {
+		return ErrCircuitOpen
+	}
+	err := call()
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		// Not the host's fault; let the next request probe it again
+		c.breaker.mu.Lock()
+		if c.breaker.state == BreakerHalfOpen {
+			c.breaker.set(BreakerOpen)
+		}
+		c.breaker.mu.Unlock()
+		return err
+	}
+	c.breaker.done(err, c.BreakerFailures, time.Now())
+	return err
+}
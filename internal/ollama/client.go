@@ -2,20 +2,80 @@ package ollama
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/metrics"
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
 )
 
-// Client talks to a local Ollama instance (https://ollama.com/).
+// Client talks to a local Ollama instance (https://ollama.com/), or a hosted
+// Ollama-compatible gateway. Host may include a path prefix, e.g.
+// "https://gateway.example.com/ollama".
 type Client struct {
-	Host       string
-	Model      string
+	Host  string
+	Model string
+	// EmbedModel is the model Embed uses (default Model), since generation
+	// models often make poor embeddings, e.g. "nomic-embed-text".
+	EmbedModel string
+	// HTTPClient defaults to a client with a 15 minute timeout on
+	// http.DefaultTransport, which honours HTTP_PROXY, HTTPS_PROXY and
+	// NO_PROXY. A custom client uses its own transport's proxy setting instead.
 	HTTPClient *http.Client
+	// APIKey, when set, is sent as "Authorization: Bearer <APIKey>".
+	APIKey string
+	// Headers are added to every request, e.g. a gateway's own auth header.
+	// They are applied after APIKey, so an explicit Authorization wins.
+	Headers map[string]string
+	// UserAgent identifies requests to the host (default weather.DefaultUserAgent).
+	UserAgent string
+	// KeepAlive is how long the host keeps the model loaded after Generate
+	// or Chat, as Ollama's keep_alive: a duration such as "30m", a number
+	// of seconds, or "-1" to keep it loaded indefinitely. Longer avoids
+	// reloading the model (a slow cold start) between checks and runs, at
+	// the cost of holding its memory, GPU memory included, in between.
+	// Empty leaves it to the host (5 minutes by default).
+	KeepAlive string
+	// Logger receives debug diagnostics; nil discards them.
+	Logger *slog.Logger
+
+	// BreakerFailures, when > 0, opens a circuit breaker after that many
+	// consecutive failed requests, so a dead host costs one timeout rather
+	// than one per check: requests then fail fast with ErrCircuitOpen for
+	// BreakerCooldown (default DefaultBreakerCooldown, 5m), after which one
+	// request probes the host and closes the circuit if it succeeds.
+	BreakerFailures int
+	BreakerCooldown time.Duration
+	breaker         breaker
+
+	// Optional hooks, e.g. for logging prompts. OnRequest fires before each
+	// call with the prompt (for Chat, the last message), and OnResponse after
+	// a successful one with the model's reply and the round-trip latency.
+	OnRequest  func(prompt string)
+	OnResponse func(resp string, latency time.Duration)
+}
+
+func (c *Client) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+// Message is one turn of a chat conversation.
+type Message struct {
+	Role    string `json:"role"` // "system", "user" or "assistant"
+	Content string `json:"content"`
 }
 
 // Generate sends a prompt to Ollama and returns the model response (non-streaming).
@@ -24,32 +84,201 @@ func (c *Client) Generate(ctx context.Context, prompt string) (string, error) {
 		return "", errors.New("prompt cannot be empty")
 	}
 
-	host := c.Host
-	if host == "" {
-		host = "http://127.0.0.1:11434"
+	var result struct {
+		Response string `json:"response"`
+	}
+	start := c.beforeRequest(prompt)
+	if err := c.post(ctx, "/api/generate", c.withKeepAlive(map[string]any{
+		"model":  c.model(),
+		"prompt": prompt,
+		"stream": false,
+	}), &result); err != nil {
+		return "", err
 	}
 
-	model := c.Model
-	if model == "" {
-		model = "llama3.1"
+	response := strings.TrimSpace(result.Response)
+	c.afterResponse(response, start)
+	return response, nil
+}
+
+// Chat sends a conversation to Ollama's chat endpoint and returns the
+// assistant's reply (non-streaming). Earlier turns give the model context,
+// e.g. previous days' outlooks.
+func (c *Client) Chat(ctx context.Context, messages []Message) (string, error) {
+	if len(messages) == 0 {
+		return "", errors.New("messages cannot be empty")
 	}
 
-	payload := map[string]any{
-		"model":  model,
-		"prompt": prompt,
-		"stream": false,
+	var result struct {
+		Message Message `json:"message"`
+	}
+	start := c.beforeRequest(messages[len(messages)-1].Content)
+	if err := c.post(ctx, "/api/chat", c.withKeepAlive(map[string]any{
+		"model":    c.model(),
+		"messages": messages,
+		"stream":   false,
+	}), &result); err != nil {
+		return "", err
+	}
+
+	response := strings.TrimSpace(result.Message.Content)
+	c.afterResponse(response, start)
+	return response, nil
+}
+
+// Embed returns the embedding vector of text from Ollama's embeddings
+// endpoint, using EmbedModel. Compare vectors with CosineSimilarity. The
+// OnRequest and OnResponse hooks don't fire.
+func (c *Client) Embed(ctx context.Context, text string) ([]float64, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, errors.New("text cannot be empty")
+	}
+
+	var result struct {
+		Embedding []float64 `json:"embedding"`
+	}
+	if err := c.post(ctx, "/api/embeddings", map[string]any{
+		"model":  cmp.Or(c.EmbedModel, c.model()),
+		"prompt": text,
+	}, &result); err != nil {
+		return nil, err
 	}
+	if len(result.Embedding) == 0 {
+		return nil, errors.New("ollama returned an empty embedding")
+	}
+	return result.Embedding, nil
+}
+
+// CosineSimilarity returns the cosine of the angle between a and b: 1 for
+// vectors pointing the same way, 0 for unrelated ones. It is 0 when the
+// lengths differ or either vector is all zeros.
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
 
+// Models lists the names of the models pulled on the host, e.g.
+// "llama3.1:latest".
+func (c *Client) Models(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := c.get(ctx, "/api/tags", &resp); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
+
+// CheckModel returns an error unless the host is reachable and has Model
+// pulled. A model named without a tag matches its ":latest" tag.
+func (c *Client) CheckModel(ctx context.Context) error {
+	names, err := c.Models(ctx)
+	if err != nil {
+		return err
+	}
+	want := c.model()
+	if !strings.Contains(want, ":") {
+		want += ":latest"
+	}
+	for _, name := range names {
+		if name == want {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %q is not pulled (try \"ollama pull %s\")", c.model(), c.model())
+}
+
+// beforeRequest fires OnRequest and returns the start time for afterResponse.
+func (c *Client) beforeRequest(prompt string) time.Time {
+	if c.OnRequest != nil {
+		c.OnRequest(prompt)
+	}
+	return time.Now()
+}
+
+func (c *Client) afterResponse(resp string, start time.Time) {
+	if c.OnResponse != nil {
+		c.OnResponse(resp, time.Since(start))
+	}
+}
+
+// withKeepAlive adds KeepAlive to a request body, if set. Ollama reads a
+// string as a duration, which needs a unit, so a bare number is sent as one.
+func (c *Client) withKeepAlive(body map[string]any) map[string]any {
+	if c.KeepAlive == "" {
+		return body
+	}
+	if secs, err := strconv.Atoi(c.KeepAlive); err == nil {
+		body["keep_alive"] = secs
+	} else {
+		body["keep_alive"] = c.KeepAlive
+	}
+	return body
+}
+
+func (c *Client) model() string {
+	if c.Model != "" {
+		return c.Model
+	}
+	return "llama3.1"
+}
+
+// post sends payload as JSON to path on the Ollama host and decodes the
+// response into out.
+func (c *Client) post(ctx context.Context, path string, payload any, out any) error {
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return "", fmt.Errorf("marshal ollama payload: %w", err)
+		return fmt.Errorf("marshal ollama payload: %w", err)
 	}
+	return c.do(ctx, http.MethodPost, path, bytes.NewReader(body), out)
+}
+
+// get fetches path on the Ollama host and decodes the response into out.
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	return c.do(ctx, http.MethodGet, path, nil, out)
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, host+"/api/generate", bytes.NewReader(body))
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, out any) error {
+	return c.guard(ctx, func() error { return c.request(ctx, method, path, body, out) })
+}
+
+func (c *Client) request(ctx context.Context, method, path string, body io.Reader, out any) error {
+	host := c.Host
+	if host == "" {
+		host = "http://127.0.0.1:11434"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, host+path, body)
 	if err != nil {
-		return "", fmt.Errorf("build ollama request: %w", err)
+		return fmt.Errorf("build ollama request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", cmp.Or(c.UserAgent, weather.DefaultUserAgent))
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
 	}
-	req.Header.Set("Content-Type", "application/json")
 
 	client := c.HTTPClient
 	if client == nil {
@@ -58,26 +287,25 @@ func (c *Client) Generate(ctx context.Context, prompt string) (string, error) {
 		}
 	}
 
+	start := time.Now()
 	resp, err := client.Do(req)
+	metrics.ObserveRequest(metrics.UpstreamOllama, start)
 	if err != nil {
-		return "", fmt.Errorf("call ollama: %w", err)
+		return fmt.Errorf("call ollama: %w", err)
 	}
 	defer func() {
 		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Printf("warning: close response body: %v\n", cerr)
+			// Harmless once the body has been read
+			c.logger().Debug("close ollama response body", "err", cerr)
 		}
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("ollama returned %s", resp.Status)
+		return fmt.Errorf("ollama returned %s", resp.Status)
 	}
 
-	var result struct {
-		Response string `json:"response"`
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode ollama response: %w", err)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("decode ollama response: %w", err)
-	}
-
-	return strings.TrimSpace(result.Response), nil
+	return nil
 }
@@ -0,0 +1,142 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+
+	"github.com/emanuelefumagalli/test-agent/internal/weather"
+)
+
+// captureServer answers generate and chat requests, storing each request's
+// decoded JSON payload in *payload.
+func captureServer(t *testing.T, payload *map[string]any) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*payload = nil
+		if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"response": "ok", "message": {"role": "assistant", "content": "ok"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestKeepAlivePayload(t *testing.T) {
+	tests := []struct {
+		keepAlive string
+		want      any // nil for absent
+	}{
+		{"", nil},
+		{"30m", "30m"},
+		{"-1", float64(-1)},
+		{"3600", float64(3600)},
+	}
+	for _, tt := range tests {
+		t.Run("keep_alive="+tt.keepAlive, func(t *testing.T) {
+			var payload map[string]any
+			c := &Client{Host: captureServer(t, &payload).URL, KeepAlive: tt.keepAlive}
+
+			calls := map[string]func() error{
+				"generate": func() error { _, err := c.Generate(context.Background(), "hi"); return err },
+				"chat": func() error {
+					_, err := c.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}})
+					return err
+				},
+			}
+			for name, call := range calls {
+				if err := call(); err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				got, ok := payload["keep_alive"]
+				switch {
+				case tt.want == nil && ok:
+					t.Errorf("%s: keep_alive = %v, want it absent", name, got)
+				case tt.want != nil && got != tt.want:
+					t.Errorf("%s: keep_alive = %#v, want %#v", name, got, tt.want)
+				}
+				if payload["model"] != "llama3.1" || payload["stream"] != false {
+					t.Errorf("%s: payload = %v", name, payload)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultClientUsesProxyFromEnvironment(t *testing.T) {
+	if os.Getenv("OLLAMA_TEST_PROXY_CHILD") == "1" {
+		c := &Client{Host: "http://ollama.invalid:11434"}
+		if _, err := c.Models(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.URL.String())
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"models": []}`))
+	}))
+	defer proxy.Close()
+
+	// net/http reads the proxy variables once per process, so the request is
+	// made by a fresh test binary with them set
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDefaultClientUsesProxyFromEnvironment$")
+	cmd.Env = append(os.Environ(),
+		"OLLAMA_TEST_PROXY_CHILD=1",
+		"HTTP_PROXY="+proxy.URL, "http_proxy="+proxy.URL,
+		"NO_PROXY=", "no_proxy=",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("child test failed: %v\n%s", err, out)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || got[0] != "http://ollama.invalid:11434/api/tags" {
+		t.Errorf("proxy saw %q, want the tags request", got)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent, want string
+	}{
+		{"", weather.DefaultUserAgent},
+		{"test-agent/1.2", "test-agent/1.2"},
+	}
+	for _, tt := range tests {
+		var got []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = append(got, r.Header.Get("User-Agent"))
+			_, _ = w.Write([]byte(`{"response": "ok", "models": []}`))
+		}))
+		c := &Client{Host: srv.URL, UserAgent: tt.userAgent}
+		if _, err := c.Generate(context.Background(), "hi"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Models(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		srv.Close()
+		if len(got) != 2 {
+			t.Fatalf("server saw %d requests, want 2", len(got))
+		}
+		for _, ua := range got {
+			if ua != tt.want {
+				t.Errorf("UserAgent %q: sent %q, want %q", tt.userAgent, ua, tt.want)
+			}
+		}
+	}
+}
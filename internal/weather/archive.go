@@ -0,0 +1,67 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const openMeteoArchiveURL = "https://archive-api.open-meteo.com/v1/archive"
+
+// FetchArchive retrieves observed (reanalysis) daily wind from start to end
+// inclusive, mapped like Fetch. The archive lags real time by a few days;
+// days it has no data for yet are left out.
+func (c *OpenMeteoClient) FetchArchive(ctx context.Context, start, end time.Time) ([]ForecastDay, error) {
+	if end.Before(start) {
+		return nil, errors.New("archive end date is before start date")
+	}
+
+	query := url.Values{}
+	query.Set("latitude", fmt.Sprintf("%f", c.Latitude))
+	query.Set("longitude", fmt.Sprintf("%f", c.Longitude))
+	query.Set("daily", "windspeed_10m_max,windgusts_10m_max,winddirection_10m_dominant")
+	query.Set("start_date", start.Format("2006-01-02"))
+	query.Set("end_date", end.Format("2006-01-02"))
+	query.Set("timezone", c.timezone())
+
+	var payload struct {
+		responseZone
+		Daily *struct {
+			Time         []string   `json:"time"`
+			WindSpeedMax []*float64 `json:"windspeed_10m_max"`
+			WindGustMax  []*float64 `json:"windgusts_10m_max"`
+			WindDirMean  []*float64 `json:"winddirection_10m_dominant"`
+		} `json:"daily"`
+	}
+	if err := c.getURL(ctx, openMeteoArchiveURL, query, &payload); err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	d := payload.Daily
+	if d == nil || len(d.Time) == 0 {
+		return nil, errors.New("no daily archive data returned")
+	}
+	if len(d.Time) != len(d.WindSpeedMax) || len(d.Time) != len(d.WindGustMax) || len(d.Time) != len(d.WindDirMean) {
+		return nil, errors.New("open-meteo arrays differ in length")
+	}
+
+	loc := c.location(payload.responseZone)
+	out := make([]ForecastDay, 0, len(d.Time))
+	for i, ts := range d.Time {
+		date, err := time.ParseInLocation("2006-01-02", ts, loc)
+		if err != nil {
+			return nil, fmt.Errorf("parse date %q: %w", ts, err)
+		}
+		if d.WindSpeedMax[i] == nil || d.WindGustMax[i] == nil || d.WindDirMean[i] == nil {
+			continue // not yet in the archive
+		}
+		out = append(out, ForecastDay{
+			Date:         date,
+			WindSpeedMax: *d.WindSpeedMax[i],
+			WindGustMax:  *d.WindGustMax[i],
+			WindDirMean:  *d.WindDirMean[i],
+		})
+	}
+	return out, nil
+}
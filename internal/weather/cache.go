@@ -0,0 +1,66 @@
+package weather
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is well under Open-Meteo's model update cadence.
+const DefaultCacheTTL = 15 * time.Minute
+
+// Cache is an in-memory store of API responses keyed by request URL,
+// which encodes coordinates, parameter set and forecast days. It is safe for
+// concurrent use; a nil *Cache disables caching.
+type Cache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+// NewCache returns a cache whose entries expire after ttl (DefaultCacheTTL if ttl <= 0).
+func NewCache(ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &Cache{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (c *Cache) get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.body, true
+}
+
+func (c *Cache) set(key string, body []byte) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = cacheEntry{
+		body:    body,
+		expires: time.Now().Add(c.ttl),
+	}
+}
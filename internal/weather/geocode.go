@@ -0,0 +1,183 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const openMeteoGeocodingURL = "https://geocoding-api.open-meteo.com/v1/search"
+
+// geocodeCandidates is how many matches are requested, enough to spot a name
+// shared by places in different countries.
+const geocodeCandidates = 5
+
+// Place is a geocoding match.
+type Place struct {
+	Name        string  `json:"name"`
+	Admin1      string  `json:"admin1"` // region, e.g. "England"
+	CountryCode string  `json:"country_code"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Timezone    string  `json:"timezone"`
+}
+
+func (p Place) String() string {
+	parts := []string{p.Name}
+	if p.Admin1 != "" {
+		parts = append(parts, p.Admin1)
+	}
+	if p.CountryCode != "" {
+		parts = append(parts, p.CountryCode)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Geocode resolves a place name with Open-Meteo's geocoding API and returns
+// the top match. The name may end in a two-letter country code to narrow the
+// search ("Twickenham, GB"). Without one, a name matched exactly by places in
+// different countries is an error rather than a guess.
+func (c *OpenMeteoClient) Geocode(ctx context.Context, name string) (Place, error) {
+	search, country := strings.TrimSpace(name), ""
+	if i := strings.LastIndex(search, ","); i >= 0 {
+		if cc := strings.TrimSpace(search[i+1:]); len(cc) == 2 {
+			search, country = strings.TrimSpace(search[:i]), strings.ToUpper(cc)
+		}
+	}
+	if search == "" {
+		return Place{}, errors.New("geocode: empty place name")
+	}
+
+	query := url.Values{}
+	query.Set("name", search)
+	query.Set("count", fmt.Sprintf("%d", geocodeCandidates))
+	query.Set("language", "en")
+	query.Set("format", "json")
+	if country != "" {
+		query.Set("countryCode", country)
+	}
+
+	var payload struct {
+		Results []Place `json:"results"`
+	}
+	if err := c.getURL(ctx, openMeteoGeocodingURL, query, &payload); err != nil {
+		return Place{}, fmt.Errorf("geocode %q: %w", name, err)
+	}
+	if len(payload.Results) == 0 {
+		return Place{}, fmt.Errorf("geocode %q: no place found", name)
+	}
+
+	top := payload.Results[0]
+	if country == "" {
+		var others []string
+		for _, p := range payload.Results[1:] {
+			if strings.EqualFold(p.Name, top.Name) && p.CountryCode != top.CountryCode {
+				others = append(others, p.String())
+			}
+		}
+		if len(others) > 0 {
+			return Place{}, fmt.Errorf("geocode %q: ambiguous, matches %s and %s; add a country code, e.g. %q",
+				name, top, strings.Join(others, "; "), search+", "+top.CountryCode)
+		}
+	}
+	return top, nil
+}
+
+// Geocoder resolves place names once: results are kept in memory and, when
+// CachePath is set, in a JSON file so restarts skip the lookup. It is safe for
+// concurrent use.
+type Geocoder struct {
+	Client    *OpenMeteoClient
+	CachePath string
+
+	mu     sync.Mutex
+	places map[string]Place
+}
+
+// Resolve returns the cached place for name, geocoding it on first use.
+func (g *Geocoder) Resolve(ctx context.Context, name string) (Place, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := strings.ToLower(strings.TrimSpace(name))
+	if g.places == nil {
+		g.places = g.load()
+	}
+	if p, ok := g.places[key]; ok {
+		return p, nil
+	}
+
+	p, err := g.Client.Geocode(ctx, name)
+	if err != nil {
+		return Place{}, err
+	}
+	g.places[key] = p
+	if err := g.save(); err != nil {
+		// The place is still resolved; only the next restart pays for it
+		g.Client.logger().Warn("geocode cache not saved", "path", g.CachePath, "err", err)
+	}
+	return p, nil
+}
+
+// load reads the cache file, starting empty when it is unset, missing or
+// unreadable.
+func (g *Geocoder) load() map[string]Place {
+	places := make(map[string]Place)
+	if g.CachePath == "" {
+		return places
+	}
+	data, err := os.ReadFile(g.CachePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			g.Client.logger().Warn("ignoring unreadable geocode cache", "path", g.CachePath, "err", err)
+		}
+		return places
+	}
+	if err := json.Unmarshal(data, &places); err != nil {
+		g.Client.logger().Warn("ignoring unreadable geocode cache", "path", g.CachePath, "err", err)
+		return make(map[string]Place)
+	}
+	return places
+}
+
+func (g *Geocoder) save() error {
+	if g.CachePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(g.places, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode geocode cache: %w", err)
+	}
+	// Written aside then renamed over the cache, so a crash mid-write can't
+	// leave it truncated
+	tmp, err := os.CreateTemp(filepath.Dir(g.CachePath), ".geocode-*")
+	if err != nil {
+		return fmt.Errorf("write geocode cache: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write geocode cache: %w", err)
+	}
+	// CreateTemp's 0600 would hide the cache from other users
+	if err := tmp.Chmod(0o644); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write geocode cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write geocode cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), g.CachePath); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write geocode cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeocoderCacheFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "geocode.json")
+	const body = `{"results": [{"name": "Twickenham", "admin1": "England", "country_code": "GB",
+		"latitude": 51.45, "longitude": -0.33, "timezone": "Europe/London"}]}`
+
+	g := &Geocoder{Client: &OpenMeteoClient{HTTPClient: respondWith(body)}, CachePath: path}
+	want, err := g.Resolve(context.Background(), "Twickenham, GB")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cache is written aside and renamed into place, leaving nothing else
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "geocode.json" {
+		t.Errorf("cache directory holds %v, want only geocode.json", entries)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("cache file = %v, %v, want mode 0644", info, err)
+	}
+
+	// A restart resolves from the file without a request
+	offline := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("offline")
+	})}
+	g = &Geocoder{Client: &OpenMeteoClient{HTTPClient: offline}, CachePath: path}
+	got, err := g.Resolve(context.Background(), "twickenham, gb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("cached place = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,225 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	openMeteoEnsembleURL = "https://ensemble-api.open-meteo.com/v1/ensemble"
+
+	// ensembleModel is the GFS ensemble, the longest-running model the
+	// ensemble endpoint offers.
+	ensembleModel = "gfs05"
+
+	// MaxLongRangeDays is the longest horizon FetchLongRange supports.
+	MaxLongRangeDays = 35
+)
+
+// FetchLongRange is Fetch extended past the forecast endpoint's 16 days. Days
+// beyond MaxForecastDays come from the GFS ensemble, averaged over its
+// members, and are marked LongRange. Forecast skill that far out is low:
+// treat them as a trend ("mostly easterly next month"), not a daily forecast.
+func (c *OpenMeteoClient) FetchLongRange(ctx context.Context, days int) ([]ForecastDay, error) {
+	if days < 1 || days > MaxLongRangeDays {
+		return nil, fmt.Errorf("days must be between 1 and %d", MaxLongRangeDays)
+	}
+	if days <= MaxForecastDays {
+		return c.Fetch(ctx, days)
+	}
+
+	forecast, err := c.Fetch(ctx, MaxForecastDays)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("latitude", fmt.Sprintf("%f", c.Latitude))
+	query.Set("longitude", fmt.Sprintf("%f", c.Longitude))
+	query.Set("models", ensembleModel)
+	query.Set("daily", "wind_speed_10m_max,wind_gusts_10m_max,wind_direction_10m_dominant")
+	query.Set("forecast_days", fmt.Sprintf("%d", days))
+	query.Set("timezone", c.timezone())
+
+	var payload struct {
+		responseZone
+		Daily map[string]json.RawMessage `json:"daily"`
+	}
+	if err := c.getURL(ctx, openMeteoEnsembleURL, query, &payload); err != nil {
+		return nil, fmt.Errorf("ensemble: %w", err)
+	}
+
+	outlook, err := ensembleForecastDays(payload.Daily, c.location(payload.responseZone))
+	if err != nil {
+		return nil, fmt.Errorf("ensemble: %w", err)
+	}
+
+	last := forecast[len(forecast)-1].Date
+	for _, d := range outlook {
+		if d.Date.After(last) {
+			d.LongRange = true
+			forecast = append(forecast, d)
+		}
+	}
+	return forecast, nil
+}
+
+// ensembleForecastDays averages each day over the control run and every
+// member ("wind_speed_10m_max", "wind_speed_10m_max_member01", ...). Missing
+// (null) member values are skipped; wind direction is a vector mean.
+func ensembleForecastDays(daily map[string]json.RawMessage, loc *time.Location) ([]ForecastDay, error) {
+	var times []string
+	if err := json.Unmarshal(daily["time"], &times); err != nil || len(times) == 0 {
+		return nil, errors.New("no daily data returned")
+	}
+
+	speed, err := ensembleSeries(daily, "wind_speed_10m_max", len(times))
+	if err != nil {
+		return nil, err
+	}
+	gust, err := ensembleSeries(daily, "wind_gusts_10m_max", len(times))
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ensembleSeries(daily, "wind_direction_10m_dominant", len(times))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]ForecastDay, 0, len(times))
+	for i, ts := range times {
+		date, err := time.ParseInLocation("2006-01-02", ts, loc)
+		if err != nil {
+			return nil, fmt.Errorf("parse date %q: %w", ts, err)
+		}
+		s, okS := mean(speed[i])
+		g, okG := mean(gust[i])
+		d, okD := circularMean(dir[i])
+		if !okS || !okG || !okD {
+			continue // no member covers this day
+		}
+		out = append(out, ForecastDay{Date: date, WindSpeedMax: s, WindGustMax: g, WindDirMean: d})
+	}
+	return out, nil
+}
+
+// ensembleSeries collects, per day, the values of variable from every member.
+func ensembleSeries(daily map[string]json.RawMessage, variable string, n int) ([][]float64, error) {
+	series := make([][]float64, n)
+	found := false
+	for key, raw := range daily {
+		if key != variable && !strings.HasPrefix(key, variable+"_member") {
+			continue
+		}
+		var values []*float64
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", key, err)
+		}
+		if len(values) != n {
+			return nil, errors.New("open-meteo arrays differ in length")
+		}
+		for i, v := range values {
+			if v != nil {
+				series[i] = append(series[i], *v)
+			}
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("response missing %s", variable)
+	}
+	return series, nil
+}
+
+// addRainSpread sets PrecipSpreadMM on each of days from the ensemble's
+// daily precipitation totals, matched by date.
+func (c *OpenMeteoClient) addRainSpread(ctx context.Context, days []RainForecast) error {
+	if len(days) == 0 {
+		return nil
+	}
+	query := url.Values{}
+	query.Set("latitude", fmt.Sprintf("%f", c.Latitude))
+	query.Set("longitude", fmt.Sprintf("%f", c.Longitude))
+	query.Set("models", ensembleModel)
+	query.Set("daily", "precipitation_sum")
+	query.Set("forecast_days", fmt.Sprintf("%d", len(days)))
+	query.Set("timezone", c.timezone())
+
+	var payload struct {
+		Daily map[string]json.RawMessage `json:"daily"`
+	}
+	if err := c.getURL(ctx, openMeteoEnsembleURL, query, &payload); err != nil {
+		return fmt.Errorf("ensemble: %w", err)
+	}
+	var times []string
+	if err := json.Unmarshal(payload.Daily["time"], &times); err != nil || len(times) == 0 {
+		return errors.New("ensemble: no daily data returned")
+	}
+	precip, err := ensembleSeries(payload.Daily, "precipitation_sum", len(times))
+	if err != nil {
+		return fmt.Errorf("ensemble: %w", err)
+	}
+
+	byDate := make(map[string]int, len(times))
+	for i, ts := range times {
+		byDate[ts] = i
+	}
+	for i := range days {
+		j, ok := byDate[days[i].Date.Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		if sd, ok := stdDev(precip[j]); ok {
+			days[i].PrecipSpreadMM, days[i].HasSpread = sd, true
+		}
+	}
+	return nil
+}
+
+// stdDev is the population standard deviation of values.
+func stdDev(values []float64) (float64, bool) {
+	m, ok := mean(values)
+	if !ok {
+		return 0, false
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += (v - m) * (v - m)
+	}
+	return math.Sqrt(sum / float64(len(values))), true
+}
+
+func mean(values []float64) (float64, bool) {
+	if len(values) == 0 {
+		return 0, false
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values)), true
+}
+
+// circularMean averages compass bearings, so 350° and 10° give 0°, not 180°.
+func circularMean(degrees []float64) (float64, bool) {
+	if len(degrees) == 0 {
+		return 0, false
+	}
+	var x, y float64
+	for _, d := range degrees {
+		rad := d * math.Pi / 180
+		x += math.Cos(rad)
+		y += math.Sin(rad)
+	}
+	deg := math.Atan2(y, x) * 180 / math.Pi
+	if deg < 0 {
+		deg += 360
+	}
+	return deg, true
+}
@@ -0,0 +1,320 @@
+package weather
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/metrics"
+)
+
+const openWeatherMapURL = "https://api.openweathermap.org/data/3.0/onecall"
+
+// OpenWeatherMapMaxDays is the horizon of the One Call API's daily forecast:
+// today and the next seven days. Hourly detail covers only the first 48 hours;
+// later school-run windows fall back to the daily probability.
+const OpenWeatherMapMaxDays = 8
+
+// msToKMH converts OpenWeatherMap's metric wind speeds (m/s) to the km/h
+// Open-Meteo reports.
+const msToKMH = 3.6
+
+// snowCMPerMM converts OpenWeatherMap's snow, in mm of water, to the cm of
+// snowfall Open-Meteo reports, at the 7cm per 10mm of water the agent assumes.
+const snowCMPerMM = 0.7
+
+// OpenWeatherMapClient reads forecasts from OpenWeatherMap's One Call API
+// 3.0, an alternative to OpenMeteoClient for those with a subscription. Units
+// are converted to match Open-Meteo's. It has no long-range outlook:
+// FetchLongRange is Fetch, up to OpenWeatherMapMaxDays.
+type OpenWeatherMapClient struct {
+	Latitude  float64
+	Longitude float64
+	// APIKey is required; it is sent as the appid parameter.
+	APIKey string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Cache, when set, serves repeated identical requests from memory, so a
+	// wind and a rain check on the same coordinates share one call.
+	Cache *Cache
+	// RequestTimeout, when > 0, bounds each HTTP request independently of the
+	// caller's deadline.
+	RequestTimeout time.Duration
+	// UserAgent identifies requests (default DefaultUserAgent).
+	UserAgent string
+	// Logger receives debug diagnostics; nil discards them.
+	Logger *slog.Logger
+}
+
+func (c *OpenWeatherMapClient) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+// Horizon returns OpenWeatherMapMaxDays for both: there is no outlook.
+func (c *OpenWeatherMapClient) Horizon() (forecast, longRange int) {
+	return OpenWeatherMapMaxDays, OpenWeatherMapMaxDays
+}
+
+// Validate returns an error when the API key is missing or the coordinates
+// are off the globe.
+func (c *OpenWeatherMapClient) Validate() error {
+	if c.APIKey == "" {
+		return errors.New("openweathermap needs an API key")
+	}
+	if !(c.Latitude >= -90 && c.Latitude <= 90) {
+		return fmt.Errorf("latitude %g out of range -90..90", c.Latitude)
+	}
+	if !(c.Longitude >= -180 && c.Longitude <= 180) {
+		return fmt.Errorf("longitude %g out of range -180..180", c.Longitude)
+	}
+	return nil
+}
+
+type owmResponse struct {
+	Timezone       string      `json:"timezone"`
+	TimezoneOffset int         `json:"timezone_offset"`
+	Hourly         []owmHourly `json:"hourly"`
+	Daily          []owmDaily  `json:"daily"`
+}
+
+type owmHourly struct {
+	Dt   int64      `json:"dt"`
+	Pop  float64    `json:"pop"` // probability of precipitation, 0..1
+	Rain *owmVolume `json:"rain"`
+	Snow *owmVolume `json:"snow"`
+}
+
+// owmVolume is an hour's precipitation in mm; absent when there is none.
+type owmVolume struct {
+	OneHour float64 `json:"1h"`
+}
+
+func (v *owmVolume) mm() float64 {
+	if v == nil {
+		return 0
+	}
+	return v.OneHour
+}
+
+type owmDaily struct {
+	Dt        int64    `json:"dt"`
+	WindSpeed float64  `json:"wind_speed"`
+	WindGust  *float64 `json:"wind_gust"` // omitted on some days
+	WindDeg   float64  `json:"wind_deg"`
+	Pop       float64  `json:"pop"`
+	Rain      float64  `json:"rain"` // mm, omitted when dry
+	Snow      float64  `json:"snow"` // mm of water, omitted when none
+}
+
+func (r *owmResponse) location() *time.Location {
+	return responseZone{Timezone: r.Timezone, UTCOffsetSeconds: r.TimezoneOffset}.location()
+}
+
+// fetch calls the One Call API for the daily and hourly forecasts.
+func (c *OpenWeatherMapClient) fetch(ctx context.Context, days int) (*owmResponse, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if days < 1 || days > OpenWeatherMapMaxDays {
+		return nil, fmt.Errorf("days must be between 1 and %d", OpenWeatherMapMaxDays)
+	}
+
+	query := url.Values{}
+	query.Set("lat", fmt.Sprintf("%f", c.Latitude))
+	query.Set("lon", fmt.Sprintf("%f", c.Longitude))
+	query.Set("exclude", "current,minutely,alerts")
+	query.Set("units", "metric")
+	query.Set("appid", c.APIKey)
+
+	var resp owmResponse
+	if err := c.get(ctx, query, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Daily) == 0 {
+		return nil, errors.New("openweathermap response has no daily forecast")
+	}
+	resp.Daily = resp.Daily[:min(days, len(resp.Daily))]
+	return &resp, nil
+}
+
+// get calls the API and decodes the body into out, consulting the cache when
+// one is configured. Only a body that decodes is cached.
+func (c *OpenWeatherMapClient) get(ctx context.Context, query url.Values, out any) error {
+	reqURL := openWeatherMapURL + "?" + query.Encode()
+	body, ok := c.Cache.get(reqURL)
+	if !ok {
+		var err error
+		if body, err = c.request(ctx, reqURL); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode openweathermap response: %w", err)
+	}
+	if !ok {
+		c.Cache.set(reqURL, body)
+	}
+	return nil
+}
+
+// request performs the GET for get and returns the raw body.
+func (c *OpenWeatherMapClient) request(ctx context.Context, reqURL string) ([]byte, error) {
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if c.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RequestTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("User-Agent", cmp.Or(c.UserAgent, DefaultUserAgent))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	metrics.ObserveRequest(metrics.UpstreamOpenWeatherMap, start)
+	if err != nil {
+		// The URL carries the API key; keep it out of errors and logs
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			uerr.URL = openWeatherMapURL
+		}
+		return nil, fmt.Errorf("call openweathermap: %w", err)
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			c.logger().Debug("close openweathermap response body", "err", cerr)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read openweathermap response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Errors carry a reason, e.g. an invalid or unsubscribed key
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+			return nil, fmt.Errorf("openweathermap returned %s: %s", resp.Status, apiErr.Message)
+		}
+		return nil, fmt.Errorf("openweathermap returned %s", resp.Status)
+	}
+
+	return body, nil
+}
+
+// Fetch retrieves up to `days` (at most OpenWeatherMapMaxDays) of daily max
+// wind speeds and gusts, from today.
+func (c *OpenWeatherMapClient) Fetch(ctx context.Context, days int) ([]ForecastDay, error) {
+	resp, err := c.fetch(ctx, days)
+	if err != nil {
+		return nil, err
+	}
+	loc := resp.location()
+	out := make([]ForecastDay, 0, len(resp.Daily))
+	for _, d := range resp.Daily {
+		// Gusts are omitted on some days; the max speed stands in for them
+		gust := d.WindSpeed
+		if d.WindGust != nil {
+			gust = *d.WindGust
+		}
+		out = append(out, ForecastDay{
+			Date:         owmDate(d.Dt, loc),
+			WindSpeedMax: d.WindSpeed * msToKMH,
+			WindGustMax:  gust * msToKMH,
+			WindDirMean:  d.WindDeg,
+		})
+	}
+	return out, nil
+}
+
+// FetchLongRange is Fetch: OpenWeatherMap has no long-range outlook.
+func (c *OpenWeatherMapClient) FetchLongRange(ctx context.Context, days int) ([]ForecastDay, error) {
+	return c.Fetch(ctx, days)
+}
+
+// FetchRain retrieves the rain forecast from today, with hourly detail for
+// the school-run hours within the next 48 hours.
+func (c *OpenWeatherMapClient) FetchRain(ctx context.Context, days int) ([]RainForecast, error) {
+	resp, err := c.fetch(ctx, days)
+	if err != nil {
+		return nil, err
+	}
+	loc := resp.location()
+	out := make([]RainForecast, 0, len(resp.Daily))
+	for _, d := range resp.Daily {
+		date := owmDate(d.Dt, loc)
+		rf := RainForecast{
+			Date:               date,
+			PrecipProb:         owmProb(d.Pop),
+			PrecipMM:           d.Rain + d.Snow,
+			HasPrecipMM:        true,
+			SnowfallCM:         d.Snow * snowCMPerMM,
+			HasSnowfall:        true,
+			MorningStartHour:   MorningStartHour,
+			AfternoonStartHour: AfternoonStartHour,
+		}
+
+		// Hours already past today are missing, so each window's slices
+		// start at its first hour still in the forecast
+		for _, h := range resp.Hourly {
+			t := time.Unix(h.Dt, 0).In(loc)
+			if !sameDate(t, date) {
+				continue
+			}
+			prob, mm, cm := owmProb(h.Pop), h.Rain.mm()+h.Snow.mm(), h.Snow.mm()*snowCMPerMM
+			switch hour := t.Hour(); {
+			case hour >= MorningStartHour && hour <= MorningEndHour:
+				if len(rf.MorningRainProb) == 0 {
+					rf.MorningStartHour = hour
+				}
+				rf.MorningRainProb = append(rf.MorningRainProb, prob)
+				rf.MorningRainMM = append(rf.MorningRainMM, mm)
+				rf.MorningSnowCM = append(rf.MorningSnowCM, cm)
+			case hour >= AfternoonStartHour && hour <= AfternoonEndHour:
+				if len(rf.AfternoonProb) == 0 {
+					rf.AfternoonStartHour = hour
+				}
+				rf.AfternoonProb = append(rf.AfternoonProb, prob)
+				rf.AfternoonRainMM = append(rf.AfternoonRainMM, mm)
+				rf.AfternoonSnowCM = append(rf.AfternoonSnowCM, cm)
+			}
+		}
+		out = append(out, rf)
+	}
+	return out, nil
+}
+
+// owmDate is the local calendar date of a daily entry, at midnight in loc.
+func owmDate(dt int64, loc *time.Location) time.Time {
+	t := time.Unix(dt, 0).In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+func sameDate(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month() && a.Day() == b.Day()
+}
+
+// owmProb converts a 0..1 probability to Open-Meteo's whole percent.
+func owmProb(pop float64) int {
+	return int(math.Round(pop * 100))
+}
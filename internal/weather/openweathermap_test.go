@@ -0,0 +1,37 @@
+package weather
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// snowyOWMForecast is a one-day One Call response with 10mm of snow, as
+// water, over the day and 2mm in the 08:00 hour.
+const snowyOWMForecast = `{
+	"timezone": "UTC", "timezone_offset": 0,
+	"daily": [{"dt": 1792152000, "wind_speed": 3, "wind_deg": 90, "pop": 0.9, "snow": 10}],
+	"hourly": [{"dt": 1792137600, "pop": 0.8, "snow": {"1h": 2}}]
+}`
+
+func TestOpenWeatherMapSnowInCM(t *testing.T) {
+	c := &OpenWeatherMapClient{Latitude: 46.02, Longitude: 7.75, APIKey: "key", HTTPClient: respondWith(snowyOWMForecast)}
+	days, err := c.FetchRain(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := days[0]
+	// 7cm of snow falls as 10mm of water
+	if math.Abs(day.SnowfallCM-7) > 1e-9 || !day.HasSnowfall {
+		t.Errorf("daily snowfall = %gcm, want 7cm", day.SnowfallCM)
+	}
+	if day.PrecipMM != 10 {
+		t.Errorf("daily precipitation = %gmm, want the snow's 10mm", day.PrecipMM)
+	}
+	if len(day.MorningSnowCM) != 1 || math.Abs(day.MorningSnowCM[0]-1.4) > 1e-9 {
+		t.Errorf("08:00 snowfall = %vcm, want [1.4]", day.MorningSnowCM)
+	}
+	if len(day.MorningRainMM) != 1 || day.MorningRainMM[0] != 2 {
+		t.Errorf("08:00 precipitation = %vmm, want [2]", day.MorningRainMM)
+	}
+}
@@ -0,0 +1,107 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// HourlyRain is a single hour of rain forecast for a location.
+type HourlyRain struct {
+	Time        time.Time
+	PrecipProb  int     // %
+	PrecipMM    float64 // valid when HasPrecipMM
+	HasPrecipMM bool
+	SnowfallCM  float64 // valid when HasSnowfall
+	HasSnowfall bool
+}
+
+// TodayHourlyFetcher fetches every hour of today's rain forecast.
+type TodayHourlyFetcher interface {
+	FetchTodayHourly(ctx context.Context) ([]HourlyRain, error)
+}
+
+// FetchTodayHourly retrieves today's hourly precipitation probability, amount
+// and snowfall, midnight to midnight in the location's timezone (or
+// Timezone). It is a lighter request than FetchRain and covers every hour,
+// not only the school-run slices; see RainForecast.WithHourly.
+func (c *OpenMeteoClient) FetchTodayHourly(ctx context.Context) ([]HourlyRain, error) {
+	query := url.Values{}
+	query.Set("latitude", fmt.Sprintf("%f", c.Latitude))
+	query.Set("longitude", fmt.Sprintf("%f", c.Longitude))
+	query.Set("hourly", "precipitation_probability,precipitation,snowfall")
+	query.Set("forecast_days", "1")
+	query.Set("timezone", c.timezone())
+	c.setQueryOptions(query)
+
+	var payload struct {
+		responseZone
+		Hourly rainHourly `json:"hourly"`
+	}
+	if err := c.get(ctx, query, &payload); err != nil {
+		return nil, err
+	}
+	h := payload.Hourly
+	if len(h.Time) == 0 {
+		return nil, errors.New("no hourly rain data returned")
+	}
+	if len(h.PrecipProb) != len(h.Time) || !optionalLen(len(h.Time), len(h.Precip), len(h.Snowfall)) {
+		return nil, errors.New("open-meteo arrays differ in length")
+	}
+
+	loc := c.location(payload.responseZone)
+	out := make([]HourlyRain, 0, len(h.Time))
+	for i, ts := range h.Time {
+		t, err := time.ParseInLocation("2006-01-02T15:04", ts, loc)
+		if err != nil {
+			return nil, fmt.Errorf("parse hour %q: %w", ts, err)
+		}
+		hr := HourlyRain{Time: t, PrecipProb: h.PrecipProb[i]}
+		if i < len(h.Precip) {
+			hr.PrecipMM, hr.HasPrecipMM = h.Precip[i], true
+		}
+		if i < len(h.Snowfall) {
+			hr.SnowfallCM, hr.HasSnowfall = h.Snowfall[i], true
+		}
+		out = append(out, hr)
+	}
+	return out, nil
+}
+
+// WithHourly returns day with its hourly detail replaced by the hours on its
+// date, so any hour can be looked up, not only the school-run slices: the
+// Morning* slices carry them all from the first hour, and the Afternoon*
+// ones are empty. Amounts are dropped if any hour lacks them. Without hours
+// on day's date, day is returned unchanged.
+func (day RainForecast) WithHourly(hours []HourlyRain) RainForecast {
+	var on []HourlyRain
+	for _, h := range hours {
+		if sameDate(h.Time, day.Date) {
+			on = append(on, h)
+		}
+	}
+	if len(on) == 0 {
+		return day
+	}
+
+	day.MorningStartHour = on[0].Time.Hour()
+	day.MorningRainProb, day.MorningRainMM, day.MorningSnowCM = nil, nil, nil
+	day.AfternoonProb, day.AfternoonRainMM, day.AfternoonSnowCM = nil, nil, nil
+	amounts, snow := true, true
+	for _, h := range on {
+		day.MorningRainProb = append(day.MorningRainProb, h.PrecipProb)
+		day.MorningRainMM = append(day.MorningRainMM, h.PrecipMM)
+		day.MorningSnowCM = append(day.MorningSnowCM, h.SnowfallCM)
+		amounts = amounts && h.HasPrecipMM
+		snow = snow && h.HasSnowfall
+	}
+	if !amounts {
+		day.MorningRainMM = nil
+	}
+	if !snow {
+		day.MorningSnowCM = nil
+	}
+	return day
+}
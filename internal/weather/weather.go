@@ -1,13 +1,23 @@
 package weather
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/emanuelefumagalli/test-agent/internal/metrics"
 )
 
 // ForecastDay represents a daily wind forecast snapshot for a location.
@@ -16,16 +26,51 @@ type ForecastDay struct {
 	WindSpeedMax float64
 	WindGustMax  float64
 	WindDirMean  float64 // in degrees, 0 = North
+	LongRange    bool    // from the ensemble outlook (see FetchLongRange); low confidence
+	Past         bool    // before today, included via PastDays
 }
 
-// RainForecast represents rain data for a day with hourly detail.
+// Hours (inclusive, local time) FetchRain keeps hourly detail for: the
+// morning window covers school drop-off, the afternoon window pickup.
+const (
+	MorningStartHour   = 6
+	MorningEndHour     = 10
+	AfternoonStartHour = 15
+	AfternoonEndHour   = 18
+)
+
+// RainForecast represents rain data for a day with hourly detail. Index i of
+// the Morning* slices is hour MorningStartHour+i, and likewise for the
+// Afternoon* slices.
 type RainForecast struct {
 	Date            time.Time
 	PrecipProb      int       // daily max precipitation probability %
 	PrecipMM        float64   // daily total precipitation mm
-	MorningRainProb []int     // hourly rain probability 6am-10am (indices 0-4)
-	MorningRainMM   []float64 // hourly precipitation 6am-10am
-	AfternoonProb   []int     // hourly rain probability 15-18 (indices 0-3)
+	HasPrecipMM     bool      // false when the API omitted daily amounts
+	MorningRainProb []int     // hourly rain probability from MorningStartHour
+	MorningRainMM   []float64 // hourly precipitation from MorningStartHour (empty if omitted)
+	AfternoonProb   []int     // hourly rain probability from AfternoonStartHour
+	AfternoonRainMM []float64 // hourly precipitation from AfternoonStartHour (empty if omitted)
+	SnowfallCM      float64   // daily total snowfall cm
+	HasSnowfall     bool      // false when the API omitted snowfall
+	MorningSnowCM   []float64 // hourly snowfall from MorningStartHour (empty if omitted)
+	AfternoonSnowCM []float64 // hourly snowfall from AfternoonStartHour (empty if omitted)
+
+	MorningStartHour   int // hour of index 0 in the Morning* slices
+	AfternoonStartHour int // hour of index 0 in the Afternoon* slices
+
+	// PrecipSpreadMM is the standard deviation of the daily total across
+	// ensemble members; set (HasSpread) only with OpenMeteoClient.RainEnsemble.
+	PrecipSpreadMM float64
+	HasSpread      bool
+}
+
+// HourlyWind is a single hour of wind forecast for a location.
+type HourlyWind struct {
+	Time      time.Time
+	WindSpeed float64
+	WindGust  float64
+	WindDir   float64 // in degrees, 0 = North
 }
 
 // Forecaster fetches a set of daily wind forecasts.
@@ -33,50 +78,243 @@ type Forecaster interface {
 	Fetch(ctx context.Context, days int) ([]ForecastDay, error)
 }
 
+// HourlyForecaster fetches hour-by-hour wind forecasts.
+type HourlyForecaster interface {
+	FetchHourly(ctx context.Context, hours int) ([]HourlyWind, error)
+}
+
 // RainForecaster fetches rain forecasts.
 type RainForecaster interface {
 	FetchRain(ctx context.Context, days int) ([]RainForecast, error)
 }
 
+// LongRangeForecaster fetches daily wind forecasts, past the deterministic
+// horizon where the source has an outlook.
+type LongRangeForecaster interface {
+	FetchLongRange(ctx context.Context, days int) ([]ForecastDay, error)
+}
+
+// ArchiveFetcher fetches observed daily winds.
+type ArchiveFetcher interface {
+	FetchArchive(ctx context.Context, start, end time.Time) ([]ForecastDay, error)
+}
+
+// Client is a weather source checks can run against: OpenMeteoClient (the
+// default) or OpenWeatherMapClient.
+type Client interface {
+	Forecaster
+	LongRangeForecaster
+	RainForecaster
+	// Validate reports a misconfiguration before any request is made.
+	Validate() error
+	// Horizon returns the most days Fetch and FetchRain, and
+	// FetchLongRange, can return.
+	Horizon() (forecast, longRange int)
+}
+
 // OpenMeteoClient hits the public Open-Meteo API (no API key needed).
 type OpenMeteoClient struct {
-	Latitude   float64
-	Longitude  float64
+	Latitude  float64
+	Longitude float64
+	// HTTPClient defaults to http.DefaultClient, whose transport honours
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY. A custom client uses its own
+	// transport's proxy setting instead.
 	HTTPClient *http.Client
+	// Cache, when set, serves repeated identical requests from memory. It may be
+	// shared between clients.
+	Cache *Cache
+	// RequestTimeout, when > 0, bounds each HTTP request independently of the
+	// caller's deadline. Zero means the caller's context alone applies.
+	RequestTimeout time.Duration
+	// UserAgent identifies requests to Open-Meteo, which asks heavy users to
+	// identify themselves (default DefaultUserAgent).
+	UserAgent string
+	// Logger receives debug diagnostics; nil discards them.
+	Logger *slog.Logger
+	// PastDays prepends this many days before today to Fetch results, marked
+	// Past, for context (0-92).
+	PastDays int
+	// RainEnsemble makes FetchRain also query the ensemble API for the spread
+	// of daily precipitation (RainForecast.PrecipSpreadMM). It is a second,
+	// heavier request; if it fails the forecast is returned without spread.
+	RainEnsemble bool
+	// Models picks the weather model for forecasts, e.g. "ecmwf_ifs025",
+	// instead of Open-Meteo's default blend (empty). It is one of
+	// ForecastModels, and at most one: with several, Open-Meteo returns each
+	// variable per model under suffixed names, which the agent doesn't merge.
+	// The long-range outlook and archive keep their own models.
+	Models []string
+	// CellSelection is how Open-Meteo picks the grid cell for the
+	// coordinates: "land" (its default when empty) prefers a land cell of
+	// similar elevation, "sea" a sea cell, "nearest" the closest one.
+	CellSelection string
+	// Elevation, in metres, replaces the terrain model's elevation of the
+	// coordinates when downscaling temperatures and the like; 0 keeps the
+	// terrain model's.
+	Elevation float64
+	// Timezone, an IANA name such as "Europe/London", aligns every returned
+	// date to that zone instead of the coordinates' own ("auto", the default
+	// when empty), e.g. so a multi-location digest shares one calendar.
+	Timezone string
+	// DumpDir, when set, is a directory each fetched response body is
+	// written to as received, for debugging a forecast that looks wrong, e.g.
+	// open-meteo-forecast-20261016T073000.123Z-1234.json. Cache hits aren't
+	// written. Off by default: nothing cleans the directory up.
+	DumpDir string
+}
+
+// CellSelections are the values OpenMeteoClient.CellSelection accepts.
+var CellSelections = []string{"land", "sea", "nearest"}
+
+// ForecastModels are the values OpenMeteoClient.Models accepts.
+var ForecastModels = []string{
+	"best_match",
+	"ecmwf_ifs04", "ecmwf_ifs025", "ecmwf_aifs025",
+	"gfs_seamless", "gfs_global",
+	"icon_seamless", "icon_global", "icon_eu", "icon_d2",
+	"ukmo_seamless", "ukmo_global_deterministic_10km", "ukmo_uk_deterministic_2km",
+	"meteofrance_seamless", "gem_seamless", "jma_seamless",
+	"metno_seamless", "knmi_seamless", "dmi_seamless",
+}
+
+// DefaultUserAgent is sent when a client sets no UserAgent. The Ollama client
+// uses it too.
+const DefaultUserAgent = "test-agent"
+
+// maxPastDays is the furthest back Open-Meteo's past_days reaches.
+const maxPastDays = 92
+
+func (c *OpenMeteoClient) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+// Horizon returns MaxForecastDays and MaxLongRangeDays.
+func (c *OpenMeteoClient) Horizon() (forecast, longRange int) {
+	return MaxForecastDays, MaxLongRangeDays
+}
+
+// Validate returns an error when the coordinates are off the globe, so a typo
+// fails clearly before any request instead of with an opaque API response.
+func (c *OpenMeteoClient) Validate() error {
+	if !(c.Latitude >= -90 && c.Latitude <= 90) {
+		return fmt.Errorf("latitude %g out of range -90..90", c.Latitude)
+	}
+	if !(c.Longitude >= -180 && c.Longitude <= 180) {
+		return fmt.Errorf("longitude %g out of range -180..180", c.Longitude)
+	}
+	if c.PastDays < 0 || c.PastDays > maxPastDays {
+		return fmt.Errorf("past days %d out of range 0..%d", c.PastDays, maxPastDays)
+	}
+	if len(c.Models) > 1 {
+		return fmt.Errorf("models %q: at most one model is supported", c.Models)
+	}
+	for _, m := range c.Models {
+		if !slices.Contains(ForecastModels, m) {
+			return fmt.Errorf("unknown model %q (want one of %s)", m, strings.Join(ForecastModels, ", "))
+		}
+	}
+	if c.Timezone != "" && c.Timezone != "auto" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("timezone %q: %w", c.Timezone, err)
+		}
+	}
+	if c.CellSelection != "" && !slices.Contains(CellSelections, c.CellSelection) {
+		return fmt.Errorf("unknown cell selection %q (want one of %s)", c.CellSelection, strings.Join(CellSelections, ", "))
+	}
+	return nil
+}
+
+// setQueryOptions adds the models, cell_selection and elevation parameters to a
+// forecast endpoint query, each unless left at its default.
+func (c *OpenMeteoClient) setQueryOptions(query url.Values) {
+	if len(c.Models) > 0 {
+		query.Set("models", strings.Join(c.Models, ","))
+	}
+	if c.CellSelection != "" {
+		query.Set("cell_selection", c.CellSelection)
+	}
+	if c.Elevation != 0 {
+		query.Set("elevation", strconv.FormatFloat(c.Elevation, 'f', -1, 64))
+	}
 }
 
 const openMeteoBaseURL = "https://api.open-meteo.com/v1/forecast"
 
-// Fetch retrieves up to `days` worth of daily max wind speeds and gusts.
-func (c *OpenMeteoClient) Fetch(ctx context.Context, days int) ([]ForecastDay, error) {
-	if days < 1 {
-		return nil, errors.New("days must be >= 1")
+// MaxForecastDays is the longest horizon of Open-Meteo's forecast endpoint.
+const MaxForecastDays = 16
+
+// get performs a GET against the forecast endpoint and decodes the body into
+// each of out, consulting the cache when one is configured. Only a body that
+// decodes is cached.
+func (c *OpenMeteoClient) get(ctx context.Context, query url.Values, out ...any) error {
+	return c.getURL(ctx, openMeteoBaseURL, query, out...)
+}
+
+// getURL is get against an arbitrary Open-Meteo endpoint.
+func (c *OpenMeteoClient) getURL(ctx context.Context, endpoint string, query url.Values, out ...any) error {
+	if err := c.Validate(); err != nil {
+		return err
 	}
 
+	reqURL := endpoint + "?" + query.Encode()
+	if body, ok := c.Cache.get(reqURL); ok {
+		return decodeAll(body, out)
+	}
+
+	body, err := c.request(ctx, endpoint, reqURL)
+	if err != nil {
+		return err
+	}
+	if err := decodeAll(body, out); err != nil {
+		return err
+	}
+	c.Cache.set(reqURL, body)
+	return nil
+}
+
+// decodeAll unmarshals an Open-Meteo body into each of out.
+func decodeAll(body []byte, out []any) error {
+	for _, v := range out {
+		if err := json.Unmarshal(body, v); err != nil {
+			return fmt.Errorf("decode open-meteo response: %w", err)
+		}
+	}
+	return nil
+}
+
+// request performs the GET for getURL and returns the raw body.
+func (c *OpenMeteoClient) request(ctx context.Context, endpoint, reqURL string) ([]byte, error) {
+
 	client := c.HTTPClient
 	if client == nil {
 		client = http.DefaultClient
 	}
 
-	query := url.Values{}
-	query.Set("latitude", fmt.Sprintf("%f", c.Latitude))
-	query.Set("longitude", fmt.Sprintf("%f", c.Longitude))
-	query.Set("daily", "windspeed_10m_max,windgusts_10m_max,winddirection_10m_dominant")
-	query.Set("forecast_days", fmt.Sprintf("%d", days))
-	query.Set("timezone", "auto")
+	if c.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RequestTimeout)
+		defer cancel()
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, openMeteoBaseURL+"?"+query.Encode(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("build request: %w", err)
 	}
+	req.Header.Set("User-Agent", cmp.Or(c.UserAgent, DefaultUserAgent))
 
+	start := time.Now()
 	resp, err := client.Do(req)
+	metrics.ObserveRequest(metrics.UpstreamOpenMeteo, start)
 	if err != nil {
 		return nil, fmt.Errorf("call open-meteo: %w", err)
 	}
 	defer func() {
 		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Printf("warning: close response body: %v\n", cerr)
+			// Harmless once the body has been read
+			c.logger().Debug("close open-meteo response body", "err", cerr)
 		}
 	}()
 
@@ -84,19 +322,211 @@ func (c *OpenMeteoClient) Fetch(ctx context.Context, days int) ([]ForecastDay, e
 		return nil, fmt.Errorf("open-meteo returned %s", resp.Status)
 	}
 
-	var payload openMeteoResponse
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return nil, fmt.Errorf("decode open-meteo response: %w", err)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read open-meteo response: %w", err)
+	}
+
+	c.dump(endpoint, body)
+	return body, nil
+}
+
+// dump writes body to DumpDir, if set. Failures are only logged: the
+// response itself is fine.
+func (c *OpenMeteoClient) dump(endpoint string, body []byte) {
+	if c.DumpDir == "" {
+		return
+	}
+	// CreateTemp's suffix keeps responses in the same millisecond apart
+	pattern := fmt.Sprintf("open-meteo-%s-%s-*.json", path.Base(endpoint), time.Now().UTC().Format("20060102T150405.000Z"))
+	if err := os.MkdirAll(c.DumpDir, 0o755); err != nil {
+		c.logger().Warn("dump open-meteo response failed", "dir", c.DumpDir, "err", err)
+		return
+	}
+	f, err := os.CreateTemp(c.DumpDir, pattern)
+	if err != nil {
+		c.logger().Warn("dump open-meteo response failed", "dir", c.DumpDir, "err", err)
+		return
+	}
+	_, err = f.Write(body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		c.logger().Warn("dump open-meteo response failed", "path", f.Name(), "err", err)
+		return
+	}
+	c.logger().Debug("dumped open-meteo response", "path", f.Name(), "bytes", len(body))
+}
+
+// DayForecast is one day of wind and rain data from a single request.
+type DayForecast struct {
+	Wind ForecastDay
+	Rain RainForecast
+}
+
+// FetchAll retrieves `days` of wind and rain data in one request, preceded by
+// PastDays days (Wind.Past set). Checks on the same coordinates share the
+// request through the cache.
+func (c *OpenMeteoClient) FetchAll(ctx context.Context, days int) ([]DayForecast, error) {
+	if days < 1 || days > MaxForecastDays {
+		return nil, fmt.Errorf("days must be between 1 and %d", MaxForecastDays)
 	}
 
-	if payload.Daily == nil {
+	query := url.Values{}
+	query.Set("latitude", fmt.Sprintf("%f", c.Latitude))
+	query.Set("longitude", fmt.Sprintf("%f", c.Longitude))
+	query.Set("daily", "windspeed_10m_max,windgusts_10m_max,winddirection_10m_dominant,"+
+		"precipitation_sum,precipitation_probability_max,snowfall_sum")
+	query.Set("hourly", "precipitation_probability,precipitation,snowfall")
+	query.Set("forecast_days", fmt.Sprintf("%d", days))
+	query.Set("timezone", c.timezone())
+	if c.PastDays > 0 {
+		query.Set("past_days", fmt.Sprintf("%d", c.PastDays))
+	}
+	c.setQueryOptions(query)
+
+	// Each view picks its own fields out of the shared response
+	var wind openMeteoResponse
+	var rain rainResponse
+	if err := c.get(ctx, query, &wind, &rain); err != nil {
+		return nil, err
+	}
+	if wind.Daily == nil {
 		return nil, errors.New("open-meteo response missing daily block")
 	}
 
-	return payload.Daily.toForecastDays()
+	loc := c.location(wind.responseZone)
+	windDays, err := wind.Daily.toForecastDays(loc)
+	if err != nil {
+		return nil, err
+	}
+	rainDays, err := rain.toRainForecasts(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]DayForecast, len(windDays))
+	for i := range windDays {
+		out[i] = DayForecast{Wind: windDays[i], Rain: rainDays[i]}
+	}
+	// past_days rows come first
+	for i := range min(c.PastDays, len(out)) {
+		out[i].Wind.Past = true
+	}
+	return out, nil
+}
+
+// Fetch retrieves up to `days` worth of daily max wind speeds and gusts,
+// preceded by PastDays days marked Past.
+func (c *OpenMeteoClient) Fetch(ctx context.Context, days int) ([]ForecastDay, error) {
+	all, err := c.FetchAll(ctx, days)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ForecastDay, len(all))
+	for i, d := range all {
+		out[i] = d.Wind
+	}
+	return out, nil
+}
+
+// FetchHourly retrieves the next `hours` hours of wind speed, gusts and direction.
+func (c *OpenMeteoClient) FetchHourly(ctx context.Context, hours int) ([]HourlyWind, error) {
+	if hours < 1 {
+		return nil, errors.New("hours must be >= 1")
+	}
+
+	query := url.Values{}
+	query.Set("latitude", fmt.Sprintf("%f", c.Latitude))
+	query.Set("longitude", fmt.Sprintf("%f", c.Longitude))
+	query.Set("hourly", "wind_speed_10m,wind_direction_10m,wind_gusts_10m")
+	query.Set("forecast_hours", fmt.Sprintf("%d", hours))
+	query.Set("timezone", c.timezone())
+	c.setQueryOptions(query)
+
+	var payload struct {
+		responseZone
+		Hourly *windHourly `json:"hourly"`
+	}
+	if err := c.get(ctx, query, &payload); err != nil {
+		return nil, err
+	}
+
+	if payload.Hourly == nil {
+		return nil, errors.New("open-meteo response missing hourly block")
+	}
+
+	return payload.Hourly.toHourlyWind(c.location(payload.responseZone))
+}
+
+type windHourly struct {
+	Time      []string  `json:"time"`
+	WindSpeed []float64 `json:"wind_speed_10m"`
+	WindDir   []float64 `json:"wind_direction_10m"`
+	WindGust  []float64 `json:"wind_gusts_10m"`
+}
+
+func (h *windHourly) toHourlyWind(loc *time.Location) ([]HourlyWind, error) {
+	if len(h.Time) == 0 {
+		return nil, errors.New("no hourly data returned")
+	}
+	if len(h.Time) != len(h.WindSpeed) || len(h.Time) != len(h.WindDir) || len(h.Time) != len(h.WindGust) {
+		return nil, errors.New("open-meteo arrays differ in length")
+	}
+
+	out := make([]HourlyWind, 0, len(h.Time))
+	for idx := range h.Time {
+		t, err := time.ParseInLocation("2006-01-02T15:04", h.Time[idx], loc)
+		if err != nil {
+			return nil, fmt.Errorf("parse time %q: %w", h.Time[idx], err)
+		}
+		out = append(out, HourlyWind{
+			Time:      t,
+			WindSpeed: h.WindSpeed[idx],
+			WindGust:  h.WindGust[idx],
+			WindDir:   h.WindDir[idx],
+		})
+	}
+	return out, nil
+}
+
+// timezone is the timezone query parameter.
+func (c *OpenMeteoClient) timezone() string {
+	return cmp.Or(c.Timezone, "auto")
+}
+
+// location is the zone dates in a response are parsed in: Timezone when set,
+// otherwise the one Open-Meteo reports.
+func (c *OpenMeteoClient) location(z responseZone) *time.Location {
+	if c.Timezone != "" && c.Timezone != "auto" {
+		if loc, err := time.LoadLocation(c.Timezone); err == nil {
+			return loc
+		}
+	}
+	return z.location()
+}
+
+// responseZone is the timezone Open-Meteo reports local dates and times in.
+type responseZone struct {
+	Timezone         string `json:"timezone"`
+	TimezoneAbbr     string `json:"timezone_abbreviation"`
+	UTCOffsetSeconds int    `json:"utc_offset_seconds"`
+}
+
+// location returns the zone's IANA location, falling back to its fixed UTC
+// offset when the name is missing or unknown to the tz database.
+func (z responseZone) location() *time.Location {
+	if z.Timezone != "" {
+		if loc, err := time.LoadLocation(z.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.FixedZone(z.TimezoneAbbr, z.UTCOffsetSeconds)
 }
 
 type openMeteoResponse struct {
+	responseZone
 	Daily  *openMeteoDaily  `json:"daily"`
 	Hourly *openMeteoHourly `json:"hourly"`
 }
@@ -114,53 +544,28 @@ type openMeteoDaily struct {
 	WindDirMean  []float64 `json:"winddirection_10m_dominant"`
 }
 
-// FetchRain retrieves rain forecast with hourly morning data.
+// FetchRain retrieves rain forecast with hourly morning data, from today.
 func (c *OpenMeteoClient) FetchRain(ctx context.Context, days int) ([]RainForecast, error) {
-	if days < 1 {
-		return nil, errors.New("days must be >= 1")
-	}
-
-	client := c.HTTPClient
-	if client == nil {
-		client = http.DefaultClient
-	}
-
-	query := url.Values{}
-	query.Set("latitude", fmt.Sprintf("%f", c.Latitude))
-	query.Set("longitude", fmt.Sprintf("%f", c.Longitude))
-	query.Set("daily", "precipitation_sum,precipitation_probability_max")
-	query.Set("hourly", "precipitation_probability,precipitation")
-	query.Set("forecast_days", fmt.Sprintf("%d", days))
-	query.Set("timezone", "Europe/London")
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, openMeteoBaseURL+"?"+query.Encode(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("build request: %w", err)
-	}
-
-	resp, err := client.Do(req)
+	all, err := c.FetchAll(ctx, days)
 	if err != nil {
-		return nil, fmt.Errorf("call open-meteo: %w", err)
+		return nil, err
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Printf("warning: close response body: %v\n", cerr)
+	out := make([]RainForecast, 0, len(all))
+	for _, d := range all {
+		if !d.Wind.Past {
+			out = append(out, d.Rain)
 		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("open-meteo returned %s", resp.Status)
 	}
-
-	var payload rainResponse
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return nil, fmt.Errorf("decode open-meteo response: %w", err)
+	if c.RainEnsemble {
+		if err := c.addRainSpread(ctx, out); err != nil {
+			c.logger().Warn("rain ensemble unavailable, continuing without spread", "err", err)
+		}
 	}
-
-	return payload.toRainForecasts()
+	return out, nil
 }
 
 type rainResponse struct {
+	responseZone
 	Daily  rainDaily  `json:"daily"`
 	Hourly rainHourly `json:"hourly"`
 }
@@ -169,49 +574,90 @@ type rainDaily struct {
 	Time       []string  `json:"time"`
 	PrecipSum  []float64 `json:"precipitation_sum"`
 	PrecipProb []int     `json:"precipitation_probability_max"`
+	Snowfall   []float64 `json:"snowfall_sum"`
 }
 
 type rainHourly struct {
 	Time       []string  `json:"time"`
 	PrecipProb []int     `json:"precipitation_probability"`
 	Precip     []float64 `json:"precipitation"`
+	Snowfall   []float64 `json:"snowfall"`
+}
+
+// optionalLen reports whether every length is either 0 (array omitted) or n.
+func optionalLen(n int, lengths ...int) bool {
+	for _, l := range lengths {
+		if l != 0 && l != n {
+			return false
+		}
+	}
+	return true
 }
 
-func (r *rainResponse) toRainForecasts() ([]RainForecast, error) {
+func (r *rainResponse) toRainForecasts(loc *time.Location) ([]RainForecast, error) {
 	if len(r.Daily.Time) == 0 {
 		return nil, errors.New("no daily rain data")
 	}
+	// Amounts are optional, but any array returned must cover every day/hour
+	n, hours := len(r.Daily.Time), len(r.Hourly.Time)
+	if !optionalLen(n, len(r.Daily.PrecipSum), len(r.Daily.PrecipProb), len(r.Daily.Snowfall)) ||
+		!optionalLen(hours, len(r.Hourly.PrecipProb), len(r.Hourly.Precip), len(r.Hourly.Snowfall)) {
+		return nil, errors.New("open-meteo arrays differ in length")
+	}
 
 	out := make([]RainForecast, 0, len(r.Daily.Time))
 
 	for i, dateStr := range r.Daily.Time {
-		date, err := time.Parse("2006-01-02", dateStr)
+		date, err := time.ParseInLocation("2006-01-02", dateStr, loc)
 		if err != nil {
 			return nil, fmt.Errorf("parse date: %w", err)
 		}
 
-		rf := RainForecast{
-			Date:       date,
-			PrecipProb: r.Daily.PrecipProb[i],
-			PrecipMM:   r.Daily.PrecipSum[i],
+		rf := RainForecast{Date: date, MorningStartHour: MorningStartHour, AfternoonStartHour: AfternoonStartHour}
+		if i < len(r.Daily.PrecipProb) {
+			rf.PrecipProb = r.Daily.PrecipProb[i]
+		}
+		// Amounts are optional; callers fall back to probability alone
+		if i < len(r.Daily.PrecipSum) {
+			rf.PrecipMM = r.Daily.PrecipSum[i]
+			rf.HasPrecipMM = true
+		}
+		if i < len(r.Daily.Snowfall) {
+			rf.SnowfallCM = r.Daily.Snowfall[i]
+			rf.HasSnowfall = true
 		}
 
 		// Extract hourly data for school times
 		for j, hourStr := range r.Hourly.Time {
-			hourTime, err := time.Parse("2006-01-02T15:04", hourStr)
+			hourTime, err := time.ParseInLocation("2006-01-02T15:04", hourStr, loc)
 			if err != nil {
 				continue
 			}
 			if hourTime.Year() == date.Year() && hourTime.Month() == date.Month() && hourTime.Day() == date.Day() {
 				hour := hourTime.Hour()
-				// Morning: 6am-10am for drop-off
-				if hour >= 6 && hour <= 10 {
-					rf.MorningRainProb = append(rf.MorningRainProb, r.Hourly.PrecipProb[j])
-					rf.MorningRainMM = append(rf.MorningRainMM, r.Hourly.Precip[j])
+				// Morning: drop-off
+				if hour >= MorningStartHour && hour <= MorningEndHour {
+					if j < len(r.Hourly.PrecipProb) {
+						rf.MorningRainProb = append(rf.MorningRainProb, r.Hourly.PrecipProb[j])
+					}
+					if j < len(r.Hourly.Precip) {
+						rf.MorningRainMM = append(rf.MorningRainMM, r.Hourly.Precip[j])
+					}
+					if j < len(r.Hourly.Snowfall) {
+						rf.MorningSnowCM = append(rf.MorningSnowCM, r.Hourly.Snowfall[j])
+					}
 				}
-				// Afternoon: 15-18 for pickup (Wed 15-16, others 17-18)
-				if hour >= 15 && hour <= 18 {
-					rf.AfternoonProb = append(rf.AfternoonProb, r.Hourly.PrecipProb[j])
+				// Afternoon: pickup (Wed 15-16, others 17-18)
+				if hour >= AfternoonStartHour && hour <= AfternoonEndHour {
+					if j < len(r.Hourly.PrecipProb) {
+						rf.AfternoonProb = append(rf.AfternoonProb, r.Hourly.PrecipProb[j])
+					}
+					if j < len(r.Hourly.Precip) {
+						rf.AfternoonRainMM = append(rf.AfternoonRainMM, r.Hourly.Precip[j])
+					}
+					if j < len(r.Hourly.Snowfall) {
+						rf.AfternoonSnowCM = append(rf.AfternoonSnowCM, r.Hourly.Snowfall[j])
+					}
 				}
 			}
 		}
@@ -222,7 +668,8 @@ func (r *rainResponse) toRainForecasts() ([]RainForecast, error) {
 	return out, nil
 }
 
-func (d *openMeteoDaily) toForecastDays() ([]ForecastDay, error) {
+// toForecastDays maps the daily arrays, with dates at midnight in loc.
+func (d *openMeteoDaily) toForecastDays(loc *time.Location) ([]ForecastDay, error) {
 	if len(d.Time) == 0 {
 		return nil, errors.New("no daily data returned")
 	}
@@ -232,7 +679,7 @@ func (d *openMeteoDaily) toForecastDays() ([]ForecastDay, error) {
 
 	out := make([]ForecastDay, 0, len(d.Time))
 	for idx := range d.Time {
-		date, err := time.Parse("2006-01-02", d.Time[idx])
+		date, err := time.ParseInLocation("2006-01-02", d.Time[idx], loc)
 		if err != nil {
 			return nil, fmt.Errorf("parse date %q: %w", d.Time[idx], err)
 		}
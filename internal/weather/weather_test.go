@@ -0,0 +1,236 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// roundTripFunc is an http.RoundTripper serving requests with a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// respondWith is an HTTP client answering every request with body, as JSON.
+func respondWith(body string) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    r,
+		}, nil
+	})}
+}
+
+// tokyoForecast is a two-day FetchAll response for Tokyo, UTC+9.
+const tokyoForecast = `{
+	"timezone": "Asia/Tokyo", "timezone_abbreviation": "JST", "utc_offset_seconds": 32400,
+	"daily": {
+		"time": ["2026-10-16", "2026-10-17"],
+		"windspeed_10m_max": [10, 12], "windgusts_10m_max": [20, 24], "winddirection_10m_dominant": [90, 270],
+		"precipitation_sum": [0, 1.5], "precipitation_probability_max": [10, 60], "snowfall_sum": [0, 0]
+	},
+	"hourly": {
+		"time": ["2026-10-16T00:00", "2026-10-16T08:00", "2026-10-17T08:00"],
+		"precipitation_probability": [5, 10, 60], "precipitation": [0, 0, 0.5], "snowfall": [0, 0, 0]
+	}
+}`
+
+func TestValidateCoordinates(t *testing.T) {
+	tests := []struct {
+		lat, lon float64
+		wantErr  string // "" for valid
+	}{
+		{51.47, -0.45, ""},
+		{90, 180, ""},
+		{-90, -180, ""},
+		{0, 0, ""},
+		{90.0001, 0, "latitude"},
+		{-90.0001, 0, "latitude"},
+		{151.47, -0.45, "latitude 151.47 out of range"},
+		{0, 180.0001, "longitude"},
+		{0, -180.0001, "longitude"},
+		{51.47, -360, "longitude -360 out of range"},
+	}
+	for _, tt := range tests {
+		err := (&OpenMeteoClient{Latitude: tt.lat, Longitude: tt.lon}).Validate()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("Validate(%g, %g) = %v, want nil", tt.lat, tt.lon, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("Validate(%g, %g) = %v, want an error containing %q", tt.lat, tt.lon, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFetchRejectsBadCoordinatesBeforeRequest(t *testing.T) {
+	c := &OpenMeteoClient{
+		Latitude:  151.47,
+		Longitude: -0.45,
+		HTTPClient: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			t.Errorf("unexpected request to %s", r.URL)
+			return nil, errors.New("unexpected request")
+		})},
+	}
+	if _, err := c.Fetch(context.Background(), 7); err == nil || !strings.Contains(err.Error(), "latitude") {
+		t.Errorf("Fetch err = %v, want the latitude rejected", err)
+	}
+	if _, err := c.FetchRain(context.Background(), 7); err == nil || !strings.Contains(err.Error(), "latitude") {
+		t.Errorf("FetchRain err = %v, want the latitude rejected", err)
+	}
+}
+
+func TestFetchDatesEastOfUTC(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want *time.Location
+	}{
+		{"named zone", tokyoForecast, mustLoadLocation(t, "Asia/Tokyo")},
+		{"unknown zone falls back to the offset", strings.Replace(tokyoForecast, "Asia/Tokyo", "Asia/Nowhere", 1), time.FixedZone("JST", 9*60*60)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &OpenMeteoClient{Latitude: 35.55, Longitude: 139.78, HTTPClient: respondWith(tt.body)}
+			days, err := c.Fetch(context.Background(), 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := time.Date(2026, 10, 16, 0, 0, 0, 0, tt.want)
+			if !days[0].Date.Equal(want) {
+				t.Errorf("first date = %s, want %s", days[0].Date, want)
+			}
+			// Midnight in Tokyo is the previous afternoon in UTC
+			if wd := days[0].Date.Weekday(); wd != time.Friday {
+				t.Errorf("first weekday = %s, want Friday", wd)
+			}
+			if d := days[0].Date.UTC(); d.Day() != 15 || d.Hour() != 15 {
+				t.Errorf("first date in UTC = %s, want 15 Oct 15:00", d)
+			}
+
+			rain, err := c.FetchRain(context.Background(), 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !rain[1].Date.Equal(want.AddDate(0, 0, 1)) {
+				t.Errorf("second rain date = %s, want %s", rain[1].Date, want.AddDate(0, 0, 1))
+			}
+			if got := rain[1].MorningRainProb; len(got) != 1 || got[0] != 60 {
+				t.Errorf("second day's morning = %v, want the 08:00 local hour [60]", got)
+			}
+		})
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestDefaultClientUsesProxyFromEnvironment(t *testing.T) {
+	if os.Getenv("WEATHER_TEST_PROXY_CHILD") == "1" {
+		// The proxy refuses the tunnel, so the fetch fails once it is tried
+		c := &OpenMeteoClient{Latitude: 51.47, Longitude: -0.45}
+		if _, err := c.Fetch(context.Background(), 1); err == nil {
+			t.Fatal("want the refused tunnel's error")
+		}
+		return
+	}
+
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.Method+" "+r.Host)
+		mu.Unlock()
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer proxy.Close()
+
+	// net/http reads the proxy variables once per process, so the request is
+	// made by a fresh test binary with them set
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDefaultClientUsesProxyFromEnvironment$")
+	cmd.Env = append(os.Environ(),
+		"WEATHER_TEST_PROXY_CHILD=1",
+		"HTTPS_PROXY="+proxy.URL, "https_proxy="+proxy.URL,
+		"NO_PROXY=", "no_proxy=",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("child test failed: %v\n%s", err, out)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || got[0] != "CONNECT api.open-meteo.com:443" {
+		t.Errorf("proxy saw %q, want a tunnel to Open-Meteo", got)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent, want string
+	}{
+		{"", DefaultUserAgent},
+		{"test-agent/1.2 (ops@example.com)", "test-agent/1.2 (ops@example.com)"},
+	}
+	for _, tt := range tests {
+		var got string
+		c := &OpenMeteoClient{
+			Latitude:  35.55,
+			Longitude: 139.78,
+			UserAgent: tt.userAgent,
+			HTTPClient: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				got = r.Header.Get("User-Agent")
+				return respondWith(tokyoForecast).Transport.RoundTrip(r)
+			})},
+		}
+		if _, err := c.Fetch(context.Background(), 2); err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("UserAgent %q: sent %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestCacheSkipsUndecodableBody(t *testing.T) {
+	bodies := []string{`{"daily": "truncated`, tokyoForecast}
+	var calls int
+	c := &OpenMeteoClient{
+		Latitude:  35.55,
+		Longitude: 139.78,
+		Cache:     NewCache(time.Hour),
+		HTTPClient: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			body := bodies[min(calls, len(bodies)-1)]
+			calls++
+			return respondWith(body).Transport.RoundTrip(r)
+		})},
+	}
+	if _, err := c.Fetch(context.Background(), 2); err == nil {
+		t.Fatal("Fetch decoded a truncated body")
+	}
+	for range 2 {
+		if _, err := c.Fetch(context.Background(), 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The bad body is refetched; the good one then comes from the cache
+	if calls != 2 {
+		t.Errorf("requests = %d, want 2", calls)
+	}
+}